@@ -0,0 +1,141 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// DeploymentChangelogInput represents input for computing what a new deployment changes
+type DeploymentChangelogInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+	Environment string `json:"environment"`
+	CommitSHA   string `json:"commit_sha"`
+}
+
+// DeploymentChangelog lists the commits, authors and merged pull requests since the
+// environment's last successful deployment
+type DeploymentChangelog struct {
+	// 0 for the environment's first deployment, whose changes are empty
+	PreviousDeploymentID int64 `json:"previous_deployment_id,omitempty"`
+	githubClient.ReleaseChanges
+}
+
+// Summary is the changelog as stored in the deployment payload, nil for a nil changelog
+func (c *DeploymentChangelog) Summary() *payload.Changelog {
+	if c == nil || c.PreviousDeploymentID == 0 {
+		return nil
+	}
+	summary := &payload.Changelog{
+		PreviousDeploymentID: c.PreviousDeploymentID,
+		BaseSHA:              c.BaseSHA,
+		TotalCommits:         c.TotalCommits,
+		Authors:              c.Authors,
+		CompareURL:           c.CompareURL,
+	}
+	for _, pull := range c.PullRequests {
+		summary.PullRequests = append(summary.PullRequests, pull.Number)
+	}
+	return summary
+}
+
+// ComputeDeploymentChangelog compares the commit with the environment's last successful deployment
+// Without a GitHub App client, e.g. against the in-memory fake, it returns nil
+func (a *GitHubActivities) ComputeDeploymentChangelog(ctx context.Context, input DeploymentChangelogInput) (*DeploymentChangelog, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("ComputeDeploymentChangelog", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Str("commit", input.CommitSHA).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Computing deployment changelog")
+
+	if a.clientFactory == nil {
+		return nil, nil
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo,
+		githubClient.Read(githubClient.PermissionDeployments),
+		githubClient.Read(githubClient.PermissionContents),
+		githubClient.Read(githubClient.PermissionPullRequests)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.clientFactory.CreateClientForHost(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Finding last successful deployment")
+
+	previous, err := githubClient.LastSuccessfulDeployment(ctx, client.Repositories, input.GithubOwner, input.GithubRepo, input.Environment, time.Time{}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	changelog := &DeploymentChangelog{
+		ReleaseChanges: githubClient.ReleaseChanges{HeadSHA: input.CommitSHA},
+	}
+	if previous == nil {
+		logger.Info().
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Str("environment", input.Environment).
+			Msg("First successful deployment of environment, no changelog")
+		return changelog, nil
+	}
+	changelog.PreviousDeploymentID = previous.GetID()
+	changelog.BaseSHA = previous.GetSHA()
+
+	// A redeployment of the same commit changes nothing
+	if previous.GetSHA() != input.CommitSHA {
+		activity.RecordHeartbeat(ctx, "Comparing commits")
+
+		changes, err := githubClient.CompareReleaseChanges(ctx, client, input.GithubOwner, input.GithubRepo, previous.GetSHA(), input.CommitSHA)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("github_owner", input.GithubOwner).
+				Str("github_repo", input.GithubRepo).
+				Int64("previous_deployment_id", previous.GetID()).
+				Msg("Failed to compare with last successful deployment")
+			return nil, err
+		}
+		changelog.ReleaseChanges = *changes
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Int64("previous_deployment_id", changelog.PreviousDeploymentID).
+		Int("commits", changelog.TotalCommits).
+		Int("pull_requests", len(changelog.PullRequests)).
+		Int("authors", len(changelog.Authors)).
+		Msg("Computed deployment changelog")
+
+	return changelog, nil
+}
@@ -0,0 +1,116 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// SyncCheckRunInput represents input for mirroring a deployment as a check run
+type SyncCheckRunInput struct {
+	GithubHost   string `json:"github_host,omitempty"`
+	GithubOwner  string `json:"github_owner"`
+	GithubRepo   string `json:"github_repo"`
+	CommitSHA    string `json:"commit_sha"`
+	DeploymentID int64  `json:"deployment_id"`
+
+	// Failure details, reported as annotations when they carry a path
+	FailureDetails []githubClient.CheckAnnotation `json:"failure_details,omitempty"`
+}
+
+// SyncCheckRunResult represents the check run after the sync
+type SyncCheckRunResult struct {
+	CheckRunID int64  `json:"check_run_id"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion,omitempty"`
+	Created    bool   `json:"created"`
+}
+
+// SyncDeploymentCheckRun creates or updates the deploy/<environment> check run of a deployment
+// The summary is rebuilt from the deployment's full status timeline on every call
+func (a *GitHubActivities) SyncDeploymentCheckRun(ctx context.Context, input SyncCheckRunInput) (*SyncCheckRunResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("SyncDeploymentCheckRun", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("commit", input.CommitSHA).
+		Int64("deployment_id", input.DeploymentID).
+		Int("failure_details", len(input.FailureDetails)).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Syncing deployment check run")
+
+	if a.clientFactory == nil || !a.clientFactory.CheckRunsEnabled() {
+		logger.Debug().Msg("Deployment check runs are disabled")
+		return &SyncCheckRunResult{}, nil
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo,
+		githubClient.Read(githubClient.PermissionDeployments),
+		githubClient.Write(githubClient.PermissionChecks)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.clientFactory.CreateClientForHost(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	deployment, statuses, err := githubClient.DeploymentTimeline(ctx, client.Repositories, input.GithubOwner, input.GithubRepo, input.DeploymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	run := githubClient.NewDeploymentCheckRun(deployment, statuses, input.FailureDetails)
+	checkRunID, created, err := githubClient.UpsertDeploymentCheckRun(ctx, client, input.GithubOwner, input.GithubRepo,
+		input.CommitSHA, run, input.FailureDetails)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Int64("deployment_id", input.DeploymentID).
+			Str("check_run", run.Name).
+			Msg("Failed to sync deployment check run")
+		return nil, err
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Int64("check_run_id", checkRunID).
+		Str("check_run", run.Name).
+		Str("status", run.Status).
+		Str("conclusion", run.Conclusion).
+		Bool("created", created).
+		Msg("Successfully synced deployment check run")
+
+	return &SyncCheckRunResult{
+		CheckRunID: checkRunID,
+		Name:       run.Name,
+		Status:     run.Status,
+		Conclusion: run.Conclusion,
+		Created:    created,
+	}, nil
+}
@@ -0,0 +1,166 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// ApplyEnvironmentInput represents input for converging a repository environment on its spec
+type ApplyEnvironmentInput struct {
+	GithubHost  string                 `json:"github_host,omitempty"`
+	GithubOwner string                 `json:"github_owner"`
+	GithubRepo  string                 `json:"github_repo"`
+	Environment config.EnvironmentSpec `json:"environment"`
+	DryRun      bool                   `json:"dry_run"`
+}
+
+// ApplyEnvironmentResult represents the changes made (or detected in a dry run)
+type ApplyEnvironmentResult struct {
+	Environment string                          `json:"environment"`
+	Changed     bool                            `json:"changed"`
+	Changes     githubClient.EnvironmentChanges `json:"changes"`
+}
+
+// DeleteEnvironmentInput represents input for deleting a repository environment
+type DeleteEnvironmentInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+	Environment string `json:"environment"`
+}
+
+// ApplyGitHubEnvironment creates or updates a repository environment: required reviewers,
+// wait timer, deployment branch policies and custom protection rule apps
+func (a *GitHubActivities) ApplyGitHubEnvironment(ctx context.Context, input ApplyEnvironmentInput) (*ApplyEnvironmentResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("ApplyGitHubEnvironment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment.Name).
+		Bool("dry_run", input.DryRun).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Applying GitHub environment")
+
+	manager, err := a.environmentManager(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("Failed to create GitHub environment manager")
+		return nil, err
+	}
+
+	// Record heartbeat before API calls
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	changes, err := manager.Apply(ctx, input.GithubOwner, input.GithubRepo, input.Environment, input.DryRun)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Str("environment", input.Environment.Name).
+			Msg("Failed to apply GitHub environment")
+		return nil, err
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment.Name).
+		Bool("dry_run", input.DryRun).
+		Bool("created", changes.Created).
+		Bool("settings_updated", changes.SettingsUpdated).
+		Strs("branch_policies_added", changes.BranchPoliciesAdded).
+		Strs("branch_policies_removed", changes.BranchPoliciesRemoved).
+		Ints64("protection_rules_enabled", changes.ProtectionRulesEnabled).
+		Ints64("protection_rules_disabled", changes.ProtectionRulesDisabled).
+		Msg("Successfully applied GitHub environment")
+
+	return &ApplyEnvironmentResult{
+		Environment: input.Environment.Name,
+		Changed:     changes.Changed(),
+		Changes:     *changes,
+	}, nil
+}
+
+// DeleteGitHubEnvironment deletes a repository environment, returning false when it did not exist
+func (a *GitHubActivities) DeleteGitHubEnvironment(ctx context.Context, input DeleteEnvironmentInput) (bool, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("DeleteGitHubEnvironment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Deleting GitHub environment")
+
+	manager, err := a.environmentManager(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("Failed to create GitHub environment manager")
+		return false, err
+	}
+
+	// Record heartbeat before API call
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	deleted, err := manager.Delete(ctx, input.GithubOwner, input.GithubRepo, input.Environment)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Str("environment", input.Environment).
+			Msg("Failed to delete GitHub environment")
+		return false, err
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Bool("deleted", deleted).
+		Msg("Successfully deleted GitHub environment")
+
+	return deleted, nil
+}
+
+// environmentManager checks the administration permission and creates an environment manager
+func (a *GitHubActivities) environmentManager(ctx context.Context, hostName, owner, repo string) (*githubClient.EnvironmentManager, error) {
+	if a.clientFactory == nil {
+		return nil, fmt.Errorf("environment management requires a GitHub client factory")
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, hostName, owner, repo, githubClient.Write(githubClient.PermissionAdministration)); err != nil {
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	manager, err := a.clientFactory.EnvironmentManager(ctx, hostName, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", owner, err)
+	}
+	return manager, nil
+}
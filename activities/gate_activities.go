@@ -0,0 +1,247 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// EvaluateGateInput represents a deployment waiting on this App's protection rule
+type EvaluateGateInput struct {
+	GithubHost  string             `json:"github_host,omitempty"`
+	GithubOwner string             `json:"github_owner"`
+	GithubRepo  string             `json:"github_repo"`
+	Environment string             `json:"environment"`
+	Ref         string             `json:"ref"`
+	CommitSHA   string             `json:"commit_sha"`
+	Policy      *config.GatePolicy `json:"policy,omitempty"`
+}
+
+// GateDecision is the outcome of evaluating a gate policy
+type GateDecision struct {
+	Approved bool     `json:"approved"`
+	Reasons  []string `json:"reasons,omitempty"`
+	Comment  string   `json:"comment"`
+}
+
+// ReviewProtectionRuleInput represents input for answering a deployment protection rule callback
+type ReviewProtectionRuleInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+	Environment string `json:"environment"`
+	CallbackURL string `json:"callback_url"`
+	State       string `json:"state"`
+	Comment     string `json:"comment"`
+}
+
+// GateActivities evaluates custom deployment protection rules and reports the decision to GitHub
+type GateActivities struct {
+	github *GitHubActivities
+	config config.GateConfig
+}
+
+// NewGateActivities creates gate activities on top of the GitHub activities
+func NewGateActivities(githubActivities *GitHubActivities, cfg config.GateConfig) *GateActivities {
+	return &GateActivities{
+		github: githubActivities,
+		config: cfg,
+	}
+}
+
+// EvaluateDeploymentGate checks a pending deployment against the environment's gate policy:
+// allowed branches, freeze windows, the incident flag and upstream environment success
+func (g *GateActivities) EvaluateDeploymentGate(ctx context.Context, input EvaluateGateInput) (*GateDecision, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("EvaluateDeploymentGate", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Str("ref", input.Ref).
+		Str("commit", input.CommitSHA).
+		Bool("has_policy", input.Policy != nil).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Evaluating deployment gate")
+
+	if input.Policy == nil {
+		return &GateDecision{
+			Approved: true,
+			Comment:  fmt.Sprintf("No gate policy is configured for %s", input.Environment),
+		}, nil
+	}
+	policy := input.Policy
+
+	var reasons []string
+
+	// Allowed branches
+	if len(policy.AllowedBranches) > 0 && !refAllowed(input.Ref, policy.AllowedBranches) {
+		reasons = append(reasons, fmt.Sprintf("ref %s is not allowed to deploy to %s (allowed: %s)",
+			input.Ref, input.Environment, strings.Join(policy.AllowedBranches, ", ")))
+	}
+
+	// Freeze windows
+	now := time.Now()
+	for _, window := range policy.FreezeWindows {
+		active, err := window.Active(now)
+		if err != nil {
+			return nil, err
+		}
+		if active {
+			reasons = append(reasons, fmt.Sprintf("deploy freeze %q is active", window.Name))
+		}
+	}
+
+	// Open incidents
+	if policy.BlockOnIncident {
+		open, err := g.incidentOpen()
+		if err != nil {
+			return nil, err
+		}
+		if open {
+			reasons = append(reasons, "an incident is open")
+		}
+	}
+
+	// Upstream environments
+	if len(policy.RequireSuccessIn) > 0 {
+		activity.RecordHeartbeat(ctx, "Checking upstream environments")
+
+		if err := g.github.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Read(githubClient.PermissionDeployments)); err != nil {
+			return nil, err
+		}
+		client, err := g.github.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+		}
+
+		for _, upstream := range policy.RequireSuccessIn {
+			succeeded, err := githubClient.CommitSucceeded(ctx, client, input.GithubOwner, input.GithubRepo, input.CommitSHA, upstream)
+			if err != nil {
+				return nil, err
+			}
+			if !succeeded {
+				reasons = append(reasons, fmt.Sprintf("commit %s has no successful deployment in %s", shortSHA(input.CommitSHA), upstream))
+			}
+		}
+	}
+
+	decision := &GateDecision{Approved: len(reasons) == 0, Reasons: reasons}
+	if decision.Approved {
+		decision.Comment = fmt.Sprintf("All gate checks for %s passed", input.Environment)
+	} else {
+		decision.Comment = fmt.Sprintf("Deployment to %s rejected:\n- %s", input.Environment, strings.Join(reasons, "\n- "))
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Bool("approved", decision.Approved).
+		Strs("reasons", decision.Reasons).
+		Msg("Evaluated deployment gate")
+
+	return decision, nil
+}
+
+// ReviewDeploymentProtectionRule approves or rejects the pending deployment through its callback URL
+func (g *GateActivities) ReviewDeploymentProtectionRule(ctx context.Context, input ReviewProtectionRuleInput) error {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("ReviewDeploymentProtectionRule", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Str("state", input.State).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Reviewing deployment protection rule")
+
+	if g.github.clientFactory == nil {
+		return fmt.Errorf("reviewing protection rules requires a GitHub client factory")
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := g.github.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Write(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return err
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	err := g.github.clientFactory.ReviewProtectionRule(ctx, input.GithubHost, input.GithubOwner,
+		input.CallbackURL, input.Environment, input.State, input.Comment)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Str("environment", input.Environment).
+			Str("state", input.State).
+			Msg("Failed to review deployment protection rule")
+		return err
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Str("state", input.State).
+		Msg("Successfully reviewed deployment protection rule")
+
+	return nil
+}
+
+// incidentOpen reports whether the incident flag file exists
+func (g *GateActivities) incidentOpen() (bool, error) {
+	if g.config.IncidentFlagPath == "" {
+		return false, nil
+	}
+	_, err := os.Stat(g.config.IncidentFlagPath)
+	if err == nil {
+		return true, nil
+	}
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return false, fmt.Errorf("failed to check incident flag %s: %w", g.config.IncidentFlagPath, err)
+}
+
+// refAllowed matches a branch name (with or without refs/heads/) against glob patterns
+func refAllowed(ref string, patterns []string) bool {
+	branch := strings.TrimPrefix(ref, "refs/heads/")
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, branch); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// shortSHA abbreviates a commit SHA for messages
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
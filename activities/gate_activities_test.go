@@ -0,0 +1,105 @@
+package activities
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/github/fake"
+)
+
+func evaluateGate(t *testing.T, deployments *fake.Deployments, sha string, policy *config.GatePolicy) *GateDecision {
+	t.Helper()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	gate := NewGateActivities(NewGitHubActivitiesWithDeploymentAPI(deployments, nil), config.GateConfig{})
+	env.RegisterActivity(gate)
+
+	value, err := env.ExecuteActivity(gate.EvaluateDeploymentGate, EvaluateGateInput{
+		GithubOwner: "acme",
+		GithubRepo:  "web",
+		Environment: "production",
+		Ref:         "main",
+		CommitSHA:   sha,
+		Policy:      policy,
+	})
+	require.NoError(t, err)
+
+	var decision GateDecision
+	require.NoError(t, value.Get(&decision))
+	return &decision
+}
+
+func TestGateRequireSuccessIn(t *testing.T) {
+	deployments := fake.NewDeployments()
+	deploy(t, deployments, "aaaaaaa1", "staging", "in_progress", "success")
+
+	decision := evaluateGate(t, deployments, "aaaaaaa1", &config.GatePolicy{RequireSuccessIn: []string{"staging"}})
+	assert.True(t, decision.Approved, decision.Reasons)
+
+	decision = evaluateGate(t, deployments, "bbbbbbb2", &config.GatePolicy{RequireSuccessIn: []string{"staging"}})
+	assert.False(t, decision.Approved)
+	assert.Contains(t, decision.Reasons, "commit bbbbbbb has no successful deployment in staging")
+}
+
+func TestGateRequireSuccessInAfterNewerDeployment(t *testing.T) {
+	deployments := fake.NewDeployments()
+	staged := deploy(t, deployments, "aaaaaaa1", "staging", "success")
+
+	// A newer commit reaching staging leaves the earlier deployment inactive
+	deploy(t, deployments, "bbbbbbb2", "staging", "success")
+	require.Equal(t, []string{"success", "inactive"}, deployments.Statuses("acme", "web", staged))
+
+	decision := evaluateGate(t, deployments, "aaaaaaa1", &config.GatePolicy{RequireSuccessIn: []string{"staging"}})
+	assert.True(t, decision.Approved, decision.Reasons)
+}
+
+func TestGateRequireSuccessInBehindFailedRetries(t *testing.T) {
+	deployments := fake.NewDeployments()
+	deploy(t, deployments, "aaaaaaa1", "staging", "success")
+	for i := 0; i < 12; i++ {
+		deploy(t, deployments, "aaaaaaa1", "staging", "failure")
+	}
+
+	decision := evaluateGate(t, deployments, "aaaaaaa1", &config.GatePolicy{RequireSuccessIn: []string{"staging"}})
+	assert.True(t, decision.Approved, decision.Reasons)
+}
+
+func TestGateRequireSuccessInIgnoresOtherTasks(t *testing.T) {
+	deployments := fake.NewDeployments()
+	deployment, _, err := deployments.CreateDeployment(context.Background(), "acme", "web", &github.DeploymentRequest{
+		Ref:         github.String("aaaaaaa1"),
+		Environment: github.String("staging"),
+		Task:        github.String("deploy:migrations"),
+	})
+	require.NoError(t, err)
+	_, _, err = deployments.CreateDeploymentStatus(context.Background(), "acme", "web", deployment.GetID(), &github.DeploymentStatusRequest{State: github.String("success")})
+	require.NoError(t, err)
+
+	decision := evaluateGate(t, deployments, "aaaaaaa1", &config.GatePolicy{RequireSuccessIn: []string{"staging"}})
+	assert.False(t, decision.Approved)
+}
+
+func TestGateFreezeWindow(t *testing.T) {
+	everyDay := []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+	decision := evaluateGate(t, fake.NewDeployments(), "aaaaaaa1", &config.GatePolicy{
+		FreezeWindows: []config.FreezeWindow{{Name: "always", Days: everyDay}},
+	})
+	assert.False(t, decision.Approved)
+	assert.Contains(t, decision.Reasons, `deploy freeze "always" is active`)
+}
+
+func TestGateAllowedBranches(t *testing.T) {
+	decision := evaluateGate(t, fake.NewDeployments(), "aaaaaaa1", &config.GatePolicy{AllowedBranches: []string{"release/*"}})
+	assert.False(t, decision.Approved)
+
+	decision = evaluateGate(t, fake.NewDeployments(), "aaaaaaa1", &config.GatePolicy{AllowedBranches: []string{"main"}})
+	assert.True(t, decision.Approved, decision.Reasons)
+}
@@ -2,27 +2,41 @@ package activities
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/google/go-github/v58/github"
 	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
 
+	"github.com/imranansari/gh-deploy-wf/config"
 	githubClient "github.com/imranansari/gh-deploy-wf/github"
 	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/payload"
 )
 
 // CreateDeploymentInput represents input for creating a deployment
 type CreateDeploymentInput struct {
-	GithubOwner        string            `json:"github_owner"`
-	GithubRepo         string            `json:"github_repo"`
-	CommitSHA          string            `json:"commit_sha"`
-	Environment        string            `json:"environment"`
-	Description        string            `json:"description"`
-	IsTransient        bool              `json:"is_transient"`
-	HarnessExecutionID string            `json:"harness_execution_id"`
-	HarnessPipelineID  string            `json:"harness_pipeline_id"`
-	Payload            map[string]string `json:"payload"`
+	GithubHost         string                       `json:"github_host,omitempty"`
+	GithubOwner        string                       `json:"github_owner"`
+	GithubRepo         string                       `json:"github_repo"`
+	CommitSHA          string                       `json:"commit_sha"`
+	Environment        string                       `json:"environment"`
+	Task               string                       `json:"task,omitempty"` // deploy (default), deploy:migrations, deploy:config, deploy:rollback or deploy:<name>
+	Description        string                       `json:"description"`
+	IsTransient        bool                         `json:"is_transient"`
+	PullRequest        int                          `json:"pull_request,omitempty"`
+	PullRequests       []payload.PullRequestContext `json:"pull_requests,omitempty"` // Pull requests containing the commit
+	Changelog          *payload.Changelog           `json:"changelog,omitempty"`     // Changes since the last successful deployment
+	HarnessExecutionID string                       `json:"harness_execution_id"`
+	HarnessPipelineID  string                       `json:"harness_pipeline_id"`
+	HarnessStageID     string                       `json:"harness_stage_id,omitempty"`
+	TriggeredBy        string                       `json:"triggered_by,omitempty"` // Actor who started the deployment
+	Artifacts          []payload.Artifact           `json:"artifacts,omitempty"`
+	Services           []string                     `json:"services,omitempty"`
+	Links              map[string]string            `json:"links,omitempty"`
+	Payload            map[string]string            `json:"payload"` // Flat values, known keys like services are upgraded, the rest kept as extra
 }
 
 // CreateDeploymentResult represents the result of creating a deployment
@@ -30,21 +44,36 @@ type CreateDeploymentResult struct {
 	DeploymentID int64  `json:"deployment_id"`
 	URL          string `json:"url"`
 	Environment  string `json:"environment"`
+	Task         string `json:"task,omitempty"`
 }
 
+// AmbiguousDeploymentErrorType is the non-retryable error type when several deployments match a lookup
+// The error details carry the candidate deployment IDs
+const AmbiguousDeploymentErrorType = "AmbiguousDeploymentError"
+
 // FindDeploymentInput represents input for finding a deployment
 type FindDeploymentInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
 	GithubOwner string `json:"github_owner"`
 	GithubRepo  string `json:"github_repo"`
 	CommitSHA   string `json:"commit_sha"`
 	Environment string `json:"environment"`
+	
+	// Optional filters to tell redeployments of the same commit apart
+	Task               string            `json:"task,omitempty"`
+	CreatorAppID       int64             `json:"creator_app_id,omitempty"` // Only deployments created by this App (the worker's own)
+	HarnessExecutionID string            `json:"harness_execution_id,omitempty"`
+	Correlation        map[string]string `json:"correlation,omitempty"` // Payload values that must match
 }
 
 // UpdateDeploymentStatusInput represents input for updating deployment status
 type UpdateDeploymentStatusInput struct {
+	GithubHost     string `json:"github_host,omitempty"`
 	GithubOwner    string `json:"github_owner"`
 	GithubRepo     string `json:"github_repo"`
 	DeploymentID   int64  `json:"deployment_id"`
+	Environment    string `json:"environment,omitempty"` // Selects the deployment policy, empty applies none
+	Task           string `json:"task,omitempty"`        // Task of the deployment, only application tasks deactivate earlier deployments
 	State          string `json:"state"`
 	Description    string `json:"description"`
 	LogURL         string `json:"log_url"`
@@ -54,13 +83,36 @@ type UpdateDeploymentStatusInput struct {
 // GitHubActivities contains GitHub-related activities
 type GitHubActivities struct {
 	clientFactory *githubClient.ClientFactory
+	deployments   githubClient.DeploymentClientProvider
+	// Installation permission preflight (nil skips the check)
+	permissions githubClient.PermissionChecker
+	// Deployment policies from environments.yaml (nil applies the defaults)
+	environments *config.EnvironmentsSpec
 }
 
 // NewGitHubActivities creates a new instance of GitHub activities
-func NewGitHubActivities(clientFactory *githubClient.ClientFactory) *GitHubActivities {
+// environments may be nil, deployments then use the default policy
+func NewGitHubActivities(clientFactory *githubClient.ClientFactory, environments *config.EnvironmentsSpec) *GitHubActivities {
 	return &GitHubActivities{
 		clientFactory: clientFactory,
+		deployments:   clientFactory,
+		permissions:   clientFactory,
+		environments:  environments,
+	}
+}
+
+// NewGitHubActivitiesWithDeploymentAPI creates GitHub activities whose deployment
+// calls go through the given provider, e.g. the in-memory fake from github/fake
+// The provider's permissions are checked when it also implements githubClient.PermissionChecker
+func NewGitHubActivitiesWithDeploymentAPI(deployments githubClient.DeploymentClientProvider, environments *config.EnvironmentsSpec) *GitHubActivities {
+	activities := &GitHubActivities{
+		deployments:  deployments,
+		environments: environments,
 	}
+	if checker, ok := deployments.(githubClient.PermissionChecker); ok {
+		activities.permissions = checker
+	}
+	return activities
 }
 
 // CreateGitHubDeployment creates a new deployment in GitHub
@@ -69,19 +121,38 @@ func (a *GitHubActivities) CreateGitHubDeployment(ctx context.Context, input Cre
 	logger := logging.ActivityLogger("CreateGitHubDeployment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
 	
 	logger.Info().
+		Str("github_host", input.GithubHost).
 		Str("github_owner", input.GithubOwner).
 		Str("github_repo", input.GithubRepo).
 		Str("commit", input.CommitSHA).
 		Str("environment", input.Environment).
+		Str("task", config.TaskOrDefault(input.Task)).
 		Str("activity_id", activityInfo.ActivityID).
 		Int32("attempt", activityInfo.Attempt).
 		Msg("Creating GitHub deployment")
 	
+	// An invalid task never succeeds, reject it before any GitHub call
+	if err := config.ValidateTask(input.Task); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "ValidationError", err)
+	}
+	
 	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+	
+	// Fail fast when the installation lacks a permission instead of retrying a 403
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Write(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+	
 	activity.RecordHeartbeat(ctx, "Creating GitHub client")
 	
 	// Create GitHub client for the organization
-	client, err := a.clientFactory.CreateClientForOrg(ctx, input.GithubOwner)
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -92,42 +163,37 @@ func (a *GitHubActivities) CreateGitHubDeployment(ctx context.Context, input Cre
 	}
 	
 	// Prepare deployment payload
-	payload := map[string]interface{}{
-		"triggered_by": "temporal-workflow",
-		"created_at":   time.Now().UTC().Format(time.RFC3339),
-	}
-	
-	// Add Harness metadata if provided
-	if input.HarnessExecutionID != "" {
-		payload["harness_execution_id"] = input.HarnessExecutionID
-	}
-	if input.HarnessPipelineID != "" {
-		payload["harness_pipeline_id"] = input.HarnessPipelineID
+	deploymentPayload, err := buildDeploymentPayload(input, time.Now())
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("Invalid deployment payload")
+		return nil, temporal.NewNonRetryableApplicationError(err.Error(), "ValidationError", err)
 	}
 	
-	// Add custom payload if provided
-	for k, v := range input.Payload {
-		payload[k] = v
-	}
+	policy := a.deploymentPolicy(input.GithubHost, input.GithubOwner, input.GithubRepo, input.Environment)
+	requiredContexts := policy.RequiredContextsOrNone()
 	
 	// Create deployment request
 	deploymentRequest := &github.DeploymentRequest{
 		Ref:                   github.String(input.CommitSHA),
-		Task:                  github.String("deploy"),
+		Task:                  github.String(config.TaskOrDefault(input.Task)),
 		Environment:           github.String(input.Environment),
-		Description:           github.String(input.Description),
-		TransientEnvironment:  github.Bool(input.IsTransient),
-		ProductionEnvironment: github.Bool(input.Environment == "production"), // TODO: Use config.EnvironmentProduction
-		RequiredContexts:      &[]string{}, // Skip status checks for external deployments
-		AutoMerge:             github.Bool(false),
-		Payload:               payload,
+		Description:           github.String(truncateDescription(policy.DescriptionOr(input.Description), 140)),
+		TransientEnvironment:  github.Bool(policy.IsTransient(input.IsTransient)),
+		ProductionEnvironment: github.Bool(policy.IsProduction(input.Environment)),
+		RequiredContexts:      &requiredContexts, // Empty skips status checks for external deployments
+		AutoMerge:             github.Bool(policy.AutoMergeOrDefault()),
+		Payload:               deploymentPayload,
 	}
 	
 	// Record heartbeat before API call
 	activity.RecordHeartbeat(ctx, "Calling GitHub API")
 	
 	// Create deployment
-	deployment, response, err := client.Repositories.CreateDeployment(ctx, input.GithubOwner, input.GithubRepo, deploymentRequest)
+	deployment, response, err := client.CreateDeployment(ctx, input.GithubOwner, input.GithubRepo, deploymentRequest)
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -135,8 +201,7 @@ func (a *GitHubActivities) CreateGitHubDeployment(ctx context.Context, input Cre
 			Str("github_repo", input.GithubRepo).
 			Str("commit", input.CommitSHA).
 			Str("environment", input.Environment).
-			Int("http_status", response.StatusCode).
-			Str("response_status", response.Status).
+			Int("http_status", responseStatusCode(response)).
 			Msg("Failed to create GitHub deployment")
 		return nil, fmt.Errorf("failed to create deployment for %s/%s@%s in %s environment: %w", 
 			input.GithubOwner, input.GithubRepo, input.CommitSHA, input.Environment, err)
@@ -146,6 +211,7 @@ func (a *GitHubActivities) CreateGitHubDeployment(ctx context.Context, input Cre
 		DeploymentID: deployment.GetID(),
 		URL:          deployment.GetURL(),
 		Environment:  deployment.GetEnvironment(),
+		Task:         deployment.GetTask(),
 	}
 	
 	logger.Info().
@@ -162,12 +228,54 @@ func (a *GitHubActivities) CreateGitHubDeployment(ctx context.Context, input Cre
 	return result, nil
 }
 
+// buildDeploymentPayload assembles and validates the schema payload of a new deployment
+func buildDeploymentPayload(input CreateDeploymentInput, now time.Time) (*payload.Payload, error) {
+	p := payload.New("temporal-workflow", now, input.Payload)
+	p.Trigger.Actor = input.TriggeredBy
+	p.PullRequests = input.PullRequests
+	p.Changelog = input.Changelog
+	p.Artifacts = input.Artifacts
+	if len(input.Services) > 0 {
+		p.Services = input.Services
+	}
+	if len(input.Links) > 0 {
+		p.Links = input.Links
+	}
+	
+	// Add Harness metadata if provided
+	if input.HarnessExecutionID != "" || input.HarnessPipelineID != "" || input.HarnessStageID != "" {
+		if p.Harness == nil {
+			p.Harness = &payload.Harness{}
+		}
+		if input.HarnessExecutionID != "" {
+			p.Harness.ExecutionID = input.HarnessExecutionID
+		}
+		if input.HarnessPipelineID != "" {
+			p.Harness.PipelineID = input.HarnessPipelineID
+		}
+		if input.HarnessStageID != "" {
+			p.Harness.StageID = input.HarnessStageID
+		}
+	}
+	
+	// Transient deployments remember their pull request so they are torn down when it closes
+	if input.PullRequest > 0 {
+		p.PullRequest = input.PullRequest
+	}
+	
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid payload for %s/%s@%s: %w", input.GithubOwner, input.GithubRepo, input.CommitSHA, err)
+	}
+	return p, nil
+}
+
 // UpdateGitHubDeploymentStatus updates the status of a deployment
 func (a *GitHubActivities) UpdateGitHubDeploymentStatus(ctx context.Context, input UpdateDeploymentStatusInput) error {
 	activityInfo := activity.GetInfo(ctx)
 	logger := logging.ActivityLogger("UpdateGitHubDeploymentStatus", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
 	
 	logger.Info().
+		Str("github_host", input.GithubHost).
 		Str("github_owner", input.GithubOwner).
 		Str("github_repo", input.GithubRepo).
 		Int64("deployment_id", input.DeploymentID).
@@ -179,10 +287,23 @@ func (a *GitHubActivities) UpdateGitHubDeploymentStatus(ctx context.Context, inp
 		Msg("Updating GitHub deployment status")
 	
 	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+	
+	// Fail fast when the installation lacks a permission instead of retrying a 403
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Write(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Int64("deployment_id", input.DeploymentID).
+			Msg("GitHub App installation permission check failed")
+		return err
+	}
+	
 	activity.RecordHeartbeat(ctx, "Creating GitHub client")
 	
 	// Create GitHub client for the organization
-	client, err := a.clientFactory.CreateClientForOrg(ctx, input.GithubOwner)
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -193,11 +314,19 @@ func (a *GitHubActivities) UpdateGitHubDeploymentStatus(ctx context.Context, inp
 		return fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
 	}
 	
+	// Only application deployments replace earlier ones, and only when the policy lets them
+	policy := a.deploymentPolicy(input.GithubHost, input.GithubOwner, input.GithubRepo, input.Environment)
+	autoInactive := config.IsApplicationTask(input.Task) && policy.AutoInactiveOrDefault()
+	description := input.Description
+	if description == "" {
+		description = policy.StatusDescription(input.State, "")
+	}
+	
 	// Create status request
 	statusRequest := &github.DeploymentStatusRequest{
 		State:          github.String(input.State),
-		Description:    github.String(truncateDescription(input.Description, 140)),
-		AutoInactive:   github.Bool(true), // Automatically mark previous deployments as inactive
+		Description:    github.String(truncateDescription(description, 140)),
+		AutoInactive:   github.Bool(autoInactive),
 	}
 	
 	// Add URLs if provided
@@ -212,7 +341,7 @@ func (a *GitHubActivities) UpdateGitHubDeploymentStatus(ctx context.Context, inp
 	activity.RecordHeartbeat(ctx, "Calling GitHub API")
 	
 	// Update deployment status
-	status, response, err := client.Repositories.CreateDeploymentStatus(ctx, input.GithubOwner, input.GithubRepo, input.DeploymentID, statusRequest)
+	status, response, err := client.CreateDeploymentStatus(ctx, input.GithubOwner, input.GithubRepo, input.DeploymentID, statusRequest)
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -220,8 +349,7 @@ func (a *GitHubActivities) UpdateGitHubDeploymentStatus(ctx context.Context, inp
 			Str("github_repo", input.GithubRepo).
 			Int64("deployment_id", input.DeploymentID).
 			Str("state", input.State).
-			Int("http_status", response.StatusCode).
-			Str("response_status", response.Status).
+			Int("http_status", responseStatusCode(response)).
 			Msg("Failed to update GitHub deployment status")
 		return fmt.Errorf("failed to update deployment %d status to %s for %s/%s: %w", 
 			input.DeploymentID, input.State, input.GithubOwner, input.GithubRepo, err)
@@ -246,6 +374,7 @@ func (a *GitHubActivities) FindGitHubDeployment(ctx context.Context, input FindD
 	logger := logging.ActivityLogger("FindGitHubDeployment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
 	
 	logger.Info().
+		Str("github_host", input.GithubHost).
 		Str("github_owner", input.GithubOwner).
 		Str("github_repo", input.GithubRepo).
 		Str("commit", input.CommitSHA).
@@ -255,10 +384,22 @@ func (a *GitHubActivities) FindGitHubDeployment(ctx context.Context, input FindD
 		Msg("Finding GitHub deployment")
 	
 	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+	
+	// Fail fast when the installation lacks a permission instead of retrying a 403
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Read(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return 0, err
+	}
+	
 	activity.RecordHeartbeat(ctx, "Creating GitHub client")
 	
 	// Create GitHub client for the organization
-	client, err := a.clientFactory.CreateClientForOrg(ctx, input.GithubOwner)
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
 	if err != nil {
 		logger.Error().
 			Err(err).
@@ -268,53 +409,81 @@ func (a *GitHubActivities) FindGitHubDeployment(ctx context.Context, input FindD
 		return 0, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
 	}
 	
-	// List deployments with filters
-	deployments, _, err := client.Repositories.ListDeployments(ctx, input.GithubOwner, input.GithubRepo, &github.DeploymentsListOptions{
+	query := githubClient.DeploymentQuery{
 		SHA:         input.CommitSHA,
 		Environment: input.Environment,
-		ListOptions: github.ListOptions{
-			PerPage: 10, // Only need recent deployments
-		},
-	})
-	if err != nil {
-		logger.Error().
-			Err(err).
-			Str("github_owner", input.GithubOwner).
-			Str("github_repo", input.GithubRepo).
-			Str("commit", input.CommitSHA).
-			Str("environment", input.Environment).
-			Msg("Failed to list GitHub deployments")
-		return 0, fmt.Errorf("failed to list deployments for %s/%s@%s in %s environment: %w", 
-			input.GithubOwner, input.GithubRepo, input.CommitSHA, input.Environment, err)
+		Task:        input.Task,
+		Correlation: make(map[string]string),
+	}
+	for k, v := range input.Correlation {
+		query.Correlation[k] = v
+	}
+	if input.HarnessExecutionID != "" {
+		query.Correlation["harness_execution_id"] = input.HarnessExecutionID
 	}
 	
-	if len(deployments) == 0 {
+	if input.CreatorAppID != 0 {
+		resolver, ok := a.deployments.(githubClient.AppLoginResolver)
+		if !ok {
+			return 0, temporal.NewNonRetryableApplicationError(
+				"filtering by creator App requires a GitHub client factory", "ValidationError", nil)
+		}
+		login, err := resolver.AppLogin(ctx, input.GithubHost, input.GithubOwner, input.CreatorAppID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to resolve creator App %d: %w", input.CreatorAppID, err)
+		}
+		query.CreatorLogin = login
+	}
+	
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+	
+	deployment, err := githubClient.FindDeployment(ctx, client, input.GithubOwner, input.GithubRepo, query)
+	if err != nil {
+		var ambiguous *githubClient.AmbiguousDeploymentError
+		if errors.As(err, &ambiguous) {
+			logger.Error().
+				Str("github_owner", input.GithubOwner).
+				Str("github_repo", input.GithubRepo).
+				Str("commit", input.CommitSHA).
+				Str("environment", input.Environment).
+				Ints64("candidates", ambiguous.Candidates).
+				Msg("Several deployments match, refusing to guess")
+			// Retrying won't disambiguate, the caller has to send a correlation key
+			return 0, temporal.NewNonRetryableApplicationError(ambiguous.Error(), AmbiguousDeploymentErrorType, ambiguous, ambiguous.Candidates)
+		}
 		logger.Error().
+			Err(err).
 			Str("github_owner", input.GithubOwner).
 			Str("github_repo", input.GithubRepo).
 			Str("commit", input.CommitSHA).
 			Str("environment", input.Environment).
-			Msg("No deployment found matching criteria")
-		return 0, fmt.Errorf("no deployment found for %s/%s@%s in %s environment", 
-			input.GithubOwner, input.GithubRepo, input.CommitSHA, input.Environment)
+			Msg("Failed to find GitHub deployment")
+		return 0, err
 	}
 	
-	// Return the most recent deployment (first in list)
-	deploymentID := deployments[0].GetID()
+	deploymentID := deployment.GetID()
 	
 	logger.Info().
 		Int64("deployment_id", deploymentID).
-		Int("total_found", len(deployments)).
+		Str("query", query.String()).
 		Str("github_owner", input.GithubOwner).
 		Str("github_repo", input.GithubRepo).
 		Str("commit", input.CommitSHA).
 		Str("environment", input.Environment).
-		Time("deployment_created_at", deployments[0].GetCreatedAt().Time).
+		Time("deployment_created_at", deployment.GetCreatedAt().Time).
 		Msg("Successfully found GitHub deployment")
 	
 	return deploymentID, nil
 }
 
+// responseStatusCode returns the HTTP status of a GitHub response, or 0 when the request never completed
+func responseStatusCode(response *github.Response) int {
+	if response == nil || response.Response == nil {
+		return 0
+	}
+	return response.StatusCode
+}
+
 // truncateDescription ensures description doesn't exceed GitHub's limit
 func truncateDescription(desc string, maxLen int) string {
 	if len(desc) <= maxLen {
@@ -0,0 +1,137 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/imranansari/gh-deploy-wf/github/fake"
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// deploy creates a deployment of sha to an environment in the fake and reports the states in order
+func deploy(t *testing.T, deployments *fake.Deployments, sha, environment string, states ...string) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	deployment, _, err := deployments.CreateDeployment(ctx, "acme", "web", &github.DeploymentRequest{
+		Ref:         github.String(sha),
+		Environment: github.String(environment),
+	})
+	require.NoError(t, err)
+	for _, state := range states {
+		_, _, err := deployments.CreateDeploymentStatus(ctx, "acme", "web", deployment.GetID(), &github.DeploymentStatusRequest{State: github.String(state)})
+		require.NoError(t, err)
+	}
+	return deployment.GetID()
+}
+
+func createDeployment(t *testing.T, deployments *fake.Deployments, input CreateDeploymentInput) (*CreateDeploymentResult, error) {
+	t.Helper()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	githubActivities := NewGitHubActivitiesWithDeploymentAPI(deployments, nil)
+	env.RegisterActivity(githubActivities)
+
+	value, err := env.ExecuteActivity(githubActivities.CreateGitHubDeployment, input)
+	if err != nil {
+		return nil, err
+	}
+	var result CreateDeploymentResult
+	require.NoError(t, value.Get(&result))
+	return &result, nil
+}
+
+func TestCreateDeploymentTask(t *testing.T) {
+	deployments := fake.NewDeployments()
+
+	// No deployment policy matches without environments.yaml
+	result, err := createDeployment(t, deployments, CreateDeploymentInput{
+		GithubOwner: "acme",
+		GithubRepo:  "web",
+		CommitSHA:   "aaaaaaa1",
+		Environment: "staging",
+		Task:        "deploy:migrations",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "deploy:migrations", result.Task)
+}
+
+func TestCreateDeploymentRejectsInvalidTaskFirst(t *testing.T) {
+	deployments := fake.NewDeployments()
+	// The installation is missing every permission, the task is still reported
+	deployments.Permissions = map[string]string{}
+
+	_, err := createDeployment(t, deployments, CreateDeploymentInput{
+		GithubOwner: "acme",
+		GithubRepo:  "web",
+		CommitSHA:   "aaaaaaa1",
+		Environment: "staging",
+		Task:        "migrate",
+	})
+	require.Error(t, err)
+
+	var applicationErr *temporal.ApplicationError
+	require.True(t, errors.As(err, &applicationErr), err)
+	assert.Equal(t, "ValidationError", applicationErr.Type())
+	assert.Contains(t, applicationErr.Error(), `invalid deployment task "migrate"`)
+}
+
+func TestRedeployWithoutPolicy(t *testing.T) {
+	deployments := fake.NewDeployments()
+	source := deploy(t, deployments, "aaaaaaa1", "staging", "success")
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	githubActivities := NewGitHubActivitiesWithDeploymentAPI(deployments, nil)
+	env.RegisterActivity(githubActivities)
+
+	value, err := env.ExecuteActivity(githubActivities.RedeployGitHubDeployment, RedeployDeploymentInput{
+		GithubOwner:  "acme",
+		GithubRepo:   "web",
+		DeploymentID: source,
+	})
+	require.NoError(t, err)
+
+	var result CreateDeploymentResult
+	require.NoError(t, value.Get(&result))
+	assert.Equal(t, "staging", result.Environment)
+	assert.Equal(t, RedeployTask, result.Task)
+}
+
+func TestRedeployTargetsSourceCommit(t *testing.T) {
+	deployments := fake.NewDeployments()
+	deployments.Refs = map[string]string{"main": "aaaaaaa1"}
+	source := deploy(t, deployments, "main", "staging", "success")
+	deployments.Refs["main"] = "bbbbbbb2"
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	githubActivities := NewGitHubActivitiesWithDeploymentAPI(deployments, nil)
+	env.RegisterActivity(githubActivities)
+
+	value, err := env.ExecuteActivity(githubActivities.RedeployGitHubDeployment, RedeployDeploymentInput{
+		GithubOwner:  "acme",
+		GithubRepo:   "web",
+		DeploymentID: source,
+	})
+	require.NoError(t, err)
+
+	var result CreateDeploymentResult
+	require.NoError(t, value.Get(&result))
+	redeployed, _, err := deployments.GetDeployment(context.Background(), "acme", "web", result.DeploymentID)
+	require.NoError(t, err)
+	assert.Equal(t, "aaaaaaa1", redeployed.GetSHA())
+
+	decoded, err := payload.Decode(redeployed.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, "main", decoded.RedeployRef)
+	assert.Equal(t, source, decoded.RedeployOf)
+}
@@ -0,0 +1,57 @@
+package activities
+
+import (
+	"context"
+
+	"go.temporal.io/sdk/activity"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// GetDeploymentPolicyInput selects the repository environment whose deployment policy is returned
+type GetDeploymentPolicyInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner,omitempty"`
+	GithubRepo  string `json:"github_repo,omitempty"`
+	Environment string `json:"environment"`
+}
+
+// hostName returns the registered name of the host serving the owner, the name host-scoped configuration matches
+// Workflows usually leave the host empty to route by owner, it's kept as is without a client factory
+func (a *GitHubActivities) hostName(host, owner string) string {
+	if a.clientFactory == nil {
+		return host
+	}
+	name, err := a.clientFactory.ResolveHost(host, owner)
+	if err != nil {
+		return host
+	}
+	return name
+}
+
+// deploymentPolicy returns the policy of a repository's environment, nil applies the defaults
+func (a *GitHubActivities) deploymentPolicy(host, owner, repo, environment string) *config.DeploymentPolicy {
+	if a.environments == nil || environment == "" {
+		return nil
+	}
+	return a.environments.DeploymentPolicyFor(a.hostName(host, owner), owner, repo, environment)
+}
+
+// GetDeploymentPolicy returns the deployment policy of an environment, nil when none matches
+// Workflows read it once for the settings they apply themselves, e.g. retries and status descriptions
+func (a *GitHubActivities) GetDeploymentPolicy(ctx context.Context, input GetDeploymentPolicyInput) (*config.DeploymentPolicy, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("GetDeploymentPolicy", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	policy := a.deploymentPolicy(input.GithubHost, input.GithubOwner, input.GithubRepo, input.Environment)
+
+	logger.Debug().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Bool("matched", policy != nil).
+		Msg("Resolved deployment policy")
+
+	return policy, nil
+}
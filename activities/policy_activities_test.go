@@ -0,0 +1,56 @@
+package activities
+
+import (
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+)
+
+// routedFactory serves acme from ghes-east and everything else from dotcom, signing through a sidecar so no keys are read
+func routedFactory(t *testing.T) *githubClient.ClientFactory {
+	t.Helper()
+	sidecar := config.SignerConfig{Type: config.SignerSidecar, URL: "http://127.0.0.1:0"}
+	factory, err := githubClient.NewClientFactory(config.GitHubConfig{Hosts: []config.GitHubHostConfig{
+		{Name: "dotcom", Default: true, Signer: sidecar},
+		{Name: "ghes-east", Owners: []string{"acme"}, Signer: sidecar},
+	}}, config.SecretsConfig{}, zerolog.Nop())
+	require.NoError(t, err)
+	return factory
+}
+
+func TestGetDeploymentPolicyMatchesOverridesOnResolvedHost(t *testing.T) {
+	spec := (&config.EnvironmentsSpec{}).WithOverrides(config.Overrides{
+		{GithubHost: "ghes-east", DeploymentPolicy: config.DeploymentPolicy{Description: "East rollout"}},
+	})
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	githubActivities := NewGitHubActivities(routedFactory(t), spec)
+	env.RegisterActivity(githubActivities)
+
+	// Workflows leave the host empty, acme is routed to ghes-east
+	value, err := env.ExecuteActivity(githubActivities.GetDeploymentPolicy, GetDeploymentPolicyInput{
+		GithubOwner: "acme",
+		GithubRepo:  "web",
+		Environment: "staging",
+	})
+	require.NoError(t, err)
+	var policy *config.DeploymentPolicy
+	require.NoError(t, value.Get(&policy))
+	require.NotNil(t, policy)
+	assert.Equal(t, "East rollout", policy.Description)
+
+	value, err = env.ExecuteActivity(githubActivities.GetDeploymentPolicy, GetDeploymentPolicyInput{
+		GithubOwner: "globex",
+		GithubRepo:  "web",
+		Environment: "staging",
+	})
+	require.NoError(t, err)
+	assert.False(t, value.HasValue(), "globex is served by dotcom, the override doesn't apply")
+}
@@ -0,0 +1,120 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// UpdatePRCommentInput represents input for refreshing the sticky deployment comment
+type UpdatePRCommentInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+	CommitSHA   string `json:"commit_sha"`
+}
+
+// UpdatePRCommentResult lists the pull requests whose comment was created or edited
+type UpdatePRCommentResult struct {
+	PullRequests []int   `json:"pull_requests"`
+	CommentIDs   []int64 `json:"comment_ids"`
+}
+
+// UpdatePullRequestDeploymentComment keeps a single bot comment with the deployment status
+// table on every open pull request containing the commit, edited in place on each change
+func (a *GitHubActivities) UpdatePullRequestDeploymentComment(ctx context.Context, input UpdatePRCommentInput) (*UpdatePRCommentResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("UpdatePullRequestDeploymentComment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("commit", input.CommitSHA).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Updating pull request deployment comment")
+
+	result := &UpdatePRCommentResult{}
+	if a.clientFactory == nil || !a.clientFactory.PRCommentsEnabled() {
+		logger.Debug().Msg("Pull request deployment comments are disabled")
+		return result, nil
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo,
+		githubClient.Read(githubClient.PermissionDeployments),
+		githubClient.Write(githubClient.PermissionPullRequests)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.clientFactory.CreateClientForHost(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	pulls, err := githubClient.OpenPullRequestsForCommit(ctx, client, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+	if err != nil {
+		return nil, err
+	}
+	if len(pulls) == 0 {
+		logger.Debug().
+			Str("commit", input.CommitSHA).
+			Msg("Commit does not belong to an open pull request")
+		return result, nil
+	}
+
+	deployments, err := githubClient.LatestDeployments(ctx, client.Repositories, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+	if err != nil {
+		return nil, err
+	}
+	body := githubClient.RenderDeploymentComment(input.CommitSHA, deployments)
+
+	// The sticky comment is the one this App wrote
+	author, err := a.clientFactory.AppLogin(ctx, input.GithubHost, input.GithubOwner, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, number := range pulls {
+		commentID, created, err := githubClient.UpsertStickyComment(ctx, client, input.GithubOwner, input.GithubRepo,
+			number, author, githubClient.DeploymentCommentMarker, body)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("github_owner", input.GithubOwner).
+				Str("github_repo", input.GithubRepo).
+				Int("pull_request", number).
+				Msg("Failed to update pull request deployment comment")
+			return nil, err
+		}
+		result.PullRequests = append(result.PullRequests, number)
+		result.CommentIDs = append(result.CommentIDs, commentID)
+
+		logger.Info().
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Int("pull_request", number).
+			Int64("comment_id", commentID).
+			Bool("created", created).
+			Int("environments", len(deployments)).
+			Msg("Successfully updated pull request deployment comment")
+	}
+
+	return result, nil
+}
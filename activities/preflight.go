@@ -0,0 +1,33 @@
+package activities
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/temporal"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+)
+
+// AuthenticationErrorType is the non-retryable error type for credential and permission failures
+const AuthenticationErrorType = "AuthenticationError"
+
+// preflight checks the installation's permissions and repository selection before any GitHub call
+// A missing permission fails the activity with a non-retryable AuthenticationError naming it
+func (a *GitHubActivities) preflight(ctx context.Context, hostName, owner, repo string, required ...githubClient.Permission) error {
+	if a.permissions == nil {
+		return nil
+	}
+
+	err := a.permissions.CheckPermissions(ctx, hostName, owner, repo, required...)
+	if err == nil {
+		return nil
+	}
+
+	var permissionErr *githubClient.PermissionError
+	if errors.As(err, &permissionErr) {
+		return temporal.NewNonRetryableApplicationError(permissionErr.Error(), AuthenticationErrorType, permissionErr)
+	}
+	return fmt.Errorf("failed to check GitHub App permissions for %s/%s: %w", owner, repo, err)
+}
@@ -0,0 +1,82 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// ResolvePullRequestsInput represents input for looking up the pull requests of a commit
+type ResolvePullRequestsInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+	CommitSHA   string `json:"commit_sha"`
+}
+
+// ResolveCommitPullRequests returns the pull requests containing the commit, open ones first
+// Without a GitHub App client, e.g. against the in-memory fake, it returns none
+func (a *GitHubActivities) ResolveCommitPullRequests(ctx context.Context, input ResolvePullRequestsInput) ([]payload.PullRequestContext, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("ResolveCommitPullRequests", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("commit", input.CommitSHA).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Resolving pull requests of commit")
+
+	if a.clientFactory == nil {
+		return nil, nil
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo,
+		githubClient.Read(githubClient.PermissionPullRequests)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.clientFactory.CreateClientForHost(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	pulls, err := githubClient.CommitPullRequests(ctx, client, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Str("commit", input.CommitSHA).
+			Msg("Failed to resolve pull requests of commit")
+		return nil, err
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("commit", input.CommitSHA).
+		Int("pull_requests", len(pulls)).
+		Msg("Resolved pull requests of commit")
+
+	return pulls, nil
+}
@@ -0,0 +1,334 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// RedeployTask is the task of application deployments copied from an earlier deployment
+// Copies of other tasks, e.g. migrations, keep their task
+const RedeployTask = config.TaskRedeploy
+
+// GetDeploymentInput selects a deployment by ID, or the newest one in an environment when the ID is 0
+type GetDeploymentInput struct {
+	GithubHost   string `json:"github_host,omitempty"`
+	GithubOwner  string `json:"github_owner"`
+	GithubRepo   string `json:"github_repo"`
+	DeploymentID int64  `json:"deployment_id,omitempty"`
+	Environment  string `json:"environment,omitempty"`
+}
+
+// DeploymentSnapshot is a deployment with the statuses needed to re-post or copy it
+type DeploymentSnapshot struct {
+	DeploymentID int64  `json:"deployment_id"`
+	SHA          string `json:"sha"`
+	Ref          string `json:"ref"`
+	Environment  string `json:"environment"`
+	Task         string `json:"task"`
+	Description  string `json:"description,omitempty"`
+
+	// State of the newest status, pending when there is none
+	LatestState string `json:"latest_state"`
+
+	// Newest status that isn't inactive, nil when there is none
+	LastActiveStatus *githubClient.DeploymentStatusSummary `json:"last_active_status,omitempty"`
+}
+
+// RedeployDeploymentInput represents input for copying a deployment
+type RedeployDeploymentInput struct {
+	GithubHost   string `json:"github_host,omitempty"`
+	GithubOwner  string `json:"github_owner"`
+	GithubRepo   string `json:"github_repo"`
+	DeploymentID int64  `json:"deployment_id"`
+	Description  string `json:"description,omitempty"`
+}
+
+// MarkInactiveInput represents input for decommissioning a deployment
+type MarkInactiveInput struct {
+	GithubHost   string `json:"github_host,omitempty"`
+	GithubOwner  string `json:"github_owner"`
+	GithubRepo   string `json:"github_repo"`
+	DeploymentID int64  `json:"deployment_id"`
+	Description  string `json:"description,omitempty"`
+}
+
+// GetGitHubDeployment reads a deployment and its last active status
+func (a *GitHubActivities) GetGitHubDeployment(ctx context.Context, input GetDeploymentInput) (*DeploymentSnapshot, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("GetGitHubDeployment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Str("environment", input.Environment).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Getting GitHub deployment")
+
+	if input.DeploymentID == 0 && input.Environment == "" {
+		return nil, temporal.NewNonRetryableApplicationError("a deployment ID or an environment is required", "ValidationError", nil)
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Read(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	var deployment *github.Deployment
+	if input.DeploymentID != 0 {
+		deployment, _, err = client.GetDeployment(ctx, input.GithubOwner, input.GithubRepo, input.DeploymentID)
+		if err != nil {
+			err = fmt.Errorf("failed to get deployment %d: %w", input.DeploymentID, err)
+		}
+	} else {
+		deployment, err = githubClient.LatestDeployment(ctx, client, input.GithubOwner, input.GithubRepo, input.Environment)
+	}
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("Failed to get GitHub deployment")
+		return nil, err
+	}
+
+	latest, active, err := githubClient.LastActiveStatus(ctx, client, input.GithubOwner, input.GithubRepo, deployment.GetID())
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &DeploymentSnapshot{
+		DeploymentID: deployment.GetID(),
+		SHA:          deployment.GetSHA(),
+		Ref:          deployment.GetRef(),
+		Environment:  deployment.GetEnvironment(),
+		Task:         deployment.GetTask(),
+		Description:  deployment.GetDescription(),
+		LatestState:  "pending",
+	}
+	if latest != nil {
+		snapshot.LatestState = latest.GetState()
+	}
+	if active != nil {
+		snapshot.LastActiveStatus = &githubClient.DeploymentStatusSummary{
+			State:          active.GetState(),
+			Description:    active.GetDescription(),
+			EnvironmentURL: active.GetEnvironmentURL(),
+			LogURL:         active.GetLogURL(),
+			Creator:        active.GetCreator().GetLogin(),
+			CreatedAt:      active.GetCreatedAt().Time,
+		}
+	}
+
+	logger.Info().
+		Int64("deployment_id", snapshot.DeploymentID).
+		Str("environment", snapshot.Environment).
+		Str("commit", snapshot.SHA).
+		Str("latest_state", snapshot.LatestState).
+		Bool("has_active_status", snapshot.LastActiveStatus != nil).
+		Msg("Successfully got GitHub deployment")
+
+	return snapshot, nil
+}
+
+// RedeployGitHubDeployment creates a fresh deployment of the same ref, environment and payload
+// The copy has task deploy:redeploy and records its source under redeploy_of in the payload
+func (a *GitHubActivities) RedeployGitHubDeployment(ctx context.Context, input RedeployDeploymentInput) (*CreateDeploymentResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("RedeployGitHubDeployment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Redeploying GitHub deployment")
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Write(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	source, _, err := client.GetDeployment(ctx, input.GithubOwner, input.GithubRepo, input.DeploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment %d: %w", input.DeploymentID, err)
+	}
+
+	// Legacy payloads are upgraded so the copy is written in the current schema
+	deploymentPayload, err := payload.Decode(source.Payload)
+	if err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("deployment %d has an unreadable payload", input.DeploymentID), "ValidationError", err)
+	}
+	deploymentPayload.SchemaVersion = payload.SchemaVersion
+	deploymentPayload.RedeployOf = source.GetID()
+	deploymentPayload.RedeployRef = source.GetRef()
+	deploymentPayload.Trigger = payload.Trigger{Source: "temporal-workflow", CreatedAt: time.Now().UTC()}
+	if err := deploymentPayload.Validate(); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("deployment %d payload can't be copied: %v", input.DeploymentID, err), "ValidationError", err)
+	}
+
+	description := input.Description
+	if description == "" {
+		description = fmt.Sprintf("Redeploy of deployment %d", source.GetID())
+	}
+
+	task := RedeployTask
+	if !config.IsApplicationTask(source.GetTask()) {
+		task = source.GetTask()
+	}
+
+	// The API doesn't return the transient and production flags, apply the environment's deployment policy
+	// like CreateGitHubDeployment does, a pull request's deployment stays transient
+	policy := a.deploymentPolicy(input.GithubHost, input.GithubOwner, input.GithubRepo, source.GetEnvironment())
+	requiredContexts := policy.RequiredContextsOrNone()
+
+	// Ref is the source's commit, a branch may have moved since; the payload keeps the original ref
+	deployment, response, err := client.CreateDeployment(ctx, input.GithubOwner, input.GithubRepo, &github.DeploymentRequest{
+		Ref:                   github.String(source.GetSHA()),
+		Task:                  github.String(task),
+		Environment:           github.String(source.GetEnvironment()),
+		Description:           github.String(description),
+		TransientEnvironment:  github.Bool(policy.IsTransient(githubClient.PullRequestOf(source) > 0)),
+		ProductionEnvironment: github.Bool(policy.IsProduction(source.GetEnvironment())),
+		RequiredContexts:      &requiredContexts, // Empty skips status checks for external deployments
+		AutoMerge:             github.Bool(policy.AutoMergeOrDefault()),
+		Payload:               deploymentPayload,
+	})
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Int64("source_deployment_id", source.GetID()).
+			Int("http_status", responseStatusCode(response)).
+			Msg("Failed to redeploy GitHub deployment")
+		return nil, fmt.Errorf("failed to redeploy deployment %d for %s/%s: %w", source.GetID(), input.GithubOwner, input.GithubRepo, err)
+	}
+
+	logger.Info().
+		Int64("deployment_id", deployment.GetID()).
+		Int64("source_deployment_id", source.GetID()).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("ref", source.GetRef()).
+		Str("sha", source.GetSHA()).
+		Str("environment", deployment.GetEnvironment()).
+		Msg("Successfully redeployed GitHub deployment")
+
+	return &CreateDeploymentResult{
+		DeploymentID: deployment.GetID(),
+		URL:          deployment.GetURL(),
+		Environment:  deployment.GetEnvironment(),
+		Task:         deployment.GetTask(),
+	}, nil
+}
+
+// MarkGitHubDeploymentInactive decommissions a deployment by posting an inactive status
+// Returns false when the deployment already was inactive, so the activity can be repeated safely
+func (a *GitHubActivities) MarkGitHubDeploymentInactive(ctx context.Context, input MarkInactiveInput) (bool, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("MarkGitHubDeploymentInactive", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Marking GitHub deployment inactive")
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Write(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return false, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return false, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	description := input.Description
+	if description == "" {
+		description = "Decommissioned"
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	changed, err := githubClient.DeactivateDeployment(ctx, client, input.GithubOwner, input.GithubRepo, input.DeploymentID, truncateDescription(description, 140))
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Int64("deployment_id", input.DeploymentID).
+			Msg("Failed to mark GitHub deployment inactive")
+		return false, err
+	}
+
+	logger.Info().
+		Int64("deployment_id", input.DeploymentID).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Bool("changed", changed).
+		Msg("Successfully marked GitHub deployment inactive")
+
+	return changed, nil
+}
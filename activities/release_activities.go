@@ -0,0 +1,202 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v58/github"
+	"go.temporal.io/sdk/activity"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// CreateReleaseInput represents a deployment that just reached success
+type CreateReleaseInput struct {
+	GithubHost   string `json:"github_host,omitempty"`
+	GithubOwner  string `json:"github_owner"`
+	GithubRepo   string `json:"github_repo"`
+	DeploymentID int64  `json:"deployment_id"`
+
+	// Harness execution link for the release notes
+	LogURL string `json:"log_url,omitempty"`
+}
+
+// CreateReleaseResult describes the tag and release of a deployment
+type CreateReleaseResult struct {
+	// Why nothing was released, e.g. no policy for the repository
+	Skipped string `json:"skipped,omitempty"`
+
+	Tag            string `json:"tag,omitempty"`
+	TagCreated     bool   `json:"tag_created"`
+	ReleaseID      int64  `json:"release_id,omitempty"`
+	ReleaseURL     string `json:"release_url,omitempty"`
+	ReleaseCreated bool   `json:"release_created"`
+
+	PreviousDeploymentID int64 `json:"previous_deployment_id,omitempty"`
+	Commits              int   `json:"commits"`
+	PullRequests         int   `json:"pull_requests"`
+}
+
+// ReleaseActivities tags and releases successful deployments per the release policies in environments.yaml
+type ReleaseActivities struct {
+	github       *GitHubActivities
+	environments *config.EnvironmentsSpec
+}
+
+// NewReleaseActivities creates release activities on top of the GitHub activities
+// A nil spec disables releases
+func NewReleaseActivities(githubActivities *GitHubActivities, environments *config.EnvironmentsSpec) *ReleaseActivities {
+	if environments == nil {
+		environments = &config.EnvironmentsSpec{}
+	}
+	return &ReleaseActivities{
+		github:       githubActivities,
+		environments: environments,
+	}
+}
+
+// CreateDeploymentRelease creates an annotated tag and a GitHub Release on the deployed commit
+// Notes list the commits and merged pull requests since the previous successful deployment to the environment
+// An existing tag on the same commit and an existing release are reused, so retries are safe
+func (a *ReleaseActivities) CreateDeploymentRelease(ctx context.Context, input CreateReleaseInput) (*CreateReleaseResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("CreateDeploymentRelease", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Creating deployment release")
+
+	policy := a.environments.ReleasePolicy(a.github.hostName(input.GithubHost, input.GithubOwner), input.GithubOwner, input.GithubRepo)
+	if policy == nil || a.github.clientFactory == nil {
+		logger.Debug().Msg("No release policy for repository")
+		return &CreateReleaseResult{Skipped: "no release policy for repository"}, nil
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.github.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo,
+		githubClient.Read(githubClient.PermissionDeployments),
+		githubClient.Read(githubClient.PermissionPullRequests),
+		githubClient.Write(githubClient.PermissionContents)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.github.clientFactory.CreateClientForHost(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Reading deployment")
+
+	deployment, _, err := client.Repositories.GetDeployment(ctx, input.GithubOwner, input.GithubRepo, input.DeploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment %d: %w", input.DeploymentID, err)
+	}
+	if deployment.GetEnvironment() != policy.ReleaseEnvironment() {
+		return &CreateReleaseResult{
+			Skipped: fmt.Sprintf("releases are only created for %s deployments", policy.ReleaseEnvironment()),
+		}, nil
+	}
+	if !config.IsApplicationTask(deployment.GetTask()) {
+		return &CreateReleaseResult{
+			Skipped: fmt.Sprintf("releases are not created for %s deployments", deployment.GetTask()),
+		}, nil
+	}
+
+	// The tag is named after the deployment, not the wall clock, so retries render the same name
+	tag, err := githubClient.ReleaseTagName(policy.TagTemplateOrDefault(),
+		githubClient.NewReleaseTagData(deployment, deployment.GetCreatedAt().Time))
+	if err != nil {
+		return nil, err
+	}
+	result := &CreateReleaseResult{Tag: tag}
+
+	activity.RecordHeartbeat(ctx, "Finding previous successful deployment")
+
+	previous, err := githubClient.PreviousSuccessfulDeployment(ctx, client.Repositories, input.GithubOwner, input.GithubRepo, deployment)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes *githubClient.ReleaseChanges
+	if previous != nil {
+		result.PreviousDeploymentID = previous.GetID()
+
+		activity.RecordHeartbeat(ctx, "Comparing commits")
+
+		changes, err = githubClient.CompareReleaseChanges(ctx, client, input.GithubOwner, input.GithubRepo, previous.GetSHA(), deployment.GetSHA())
+		if err != nil {
+			return nil, err
+		}
+		result.Commits = len(changes.Commits)
+		result.PullRequests = len(changes.PullRequests)
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating tag")
+
+	message := fmt.Sprintf("Deployed to %s as deployment %d", deployment.GetEnvironment(), deployment.GetID())
+	result.TagCreated, err = githubClient.EnsureReleaseTag(ctx, client, input.GithubOwner, input.GithubRepo, tag, deployment.GetSHA(), message)
+	if err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Str("tag", tag).
+			Msg("Failed to create release tag")
+		return nil, err
+	}
+
+	if !policy.TagOnly {
+		activity.RecordHeartbeat(ctx, "Creating release")
+
+		release, created, err := githubClient.EnsureRelease(ctx, client, input.GithubOwner, input.GithubRepo, &github.RepositoryRelease{
+			TagName:    github.String(tag),
+			Name:       github.String(tag),
+			Body:       github.String(githubClient.RenderReleaseNotes(deployment, input.LogURL, previous, changes)),
+			Draft:      github.Bool(policy.Draft),
+			Prerelease: github.Bool(policy.Prerelease),
+		})
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("github_owner", input.GithubOwner).
+				Str("github_repo", input.GithubRepo).
+				Str("tag", tag).
+				Msg("Failed to create release")
+			return nil, err
+		}
+		result.ReleaseID = release.GetID()
+		result.ReleaseURL = release.GetHTMLURL()
+		result.ReleaseCreated = created
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Int64("previous_deployment_id", result.PreviousDeploymentID).
+		Str("tag", tag).
+		Bool("tag_created", result.TagCreated).
+		Str("release_url", result.ReleaseURL).
+		Bool("release_created", result.ReleaseCreated).
+		Int("commits", result.Commits).
+		Int("pull_requests", result.PullRequests).
+		Msg("Successfully released deployment")
+
+	return result, nil
+}
@@ -0,0 +1,357 @@
+package activities
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// TeardownEventType is the CloudEvents type emitted after a pull request environment is torn down
+const TeardownEventType = "com.github.deployment.environment.teardown"
+
+// TeardownDeploymentsInput represents input for deactivating a pull request's deployments
+type TeardownDeploymentsInput struct {
+	GithubHost        string `json:"github_host,omitempty"`
+	GithubOwner       string `json:"github_owner"`
+	GithubRepo        string `json:"github_repo"`
+	PullRequest       int    `json:"pull_request"`
+	DeleteDeployments bool   `json:"delete_deployments"`
+}
+
+// TeardownDeploymentsResult lists what was torn down
+type TeardownDeploymentsResult struct {
+	Deactivated  []int64  `json:"deactivated"`
+	Deleted      []int64  `json:"deleted,omitempty"`
+	Environments []string `json:"environments"`
+}
+
+// FindClosedPullRequestsInput represents input for scanning a repository for stale transient deployments
+type FindClosedPullRequestsInput struct {
+	GithubHost  string `json:"github_host,omitempty"`
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+
+	// Also return closed pull requests whose pr-<n> environment is left, e.g. after a failed delete
+	IncludeEnvironments bool `json:"include_environments,omitempty"`
+}
+
+// ClosedPullRequest is a closed pull request that still has active deployments or a pr-<n> environment
+type ClosedPullRequest struct {
+	Number int  `json:"number"`
+	Merged bool `json:"merged"`
+}
+
+// TeardownEvent is the data of the teardown CloudEvent sent to the infrastructure
+type TeardownEvent struct {
+	GithubHost    string   `json:"github_host,omitempty"`
+	GithubOwner   string   `json:"github_owner"`
+	GithubRepo    string   `json:"github_repo"`
+	PullRequest   int      `json:"pull_request"`
+	Merged        bool     `json:"merged"`
+	Environments  []string `json:"environments"`
+	DeploymentIDs []int64  `json:"deployment_ids"`
+}
+
+// EmitTeardownEventInput represents input for emitting the teardown event
+type EmitTeardownEventInput struct {
+	// Stable event ID so retries and redeliveries can be deduplicated by the receiver
+	EventID string        `json:"event_id"`
+	Event   TeardownEvent `json:"event"`
+}
+
+// TeardownPullRequestDeployments marks every deployment of a pull request inactive and optionally deletes them
+// Deployments that are already inactive are left alone, so the activity can be repeated safely
+func (a *GitHubActivities) TeardownPullRequestDeployments(ctx context.Context, input TeardownDeploymentsInput) (*TeardownDeploymentsResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("TeardownPullRequestDeployments", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int("pull_request", input.PullRequest).
+		Bool("delete_deployments", input.DeleteDeployments).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Tearing down pull request deployments")
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Write(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Listing pull request deployments")
+
+	deployments, err := githubClient.PullRequestDeployments(ctx, client, input.GithubOwner, input.GithubRepo, input.PullRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &TeardownDeploymentsResult{}
+	environments := make(map[string]bool)
+	description := fmt.Sprintf("Pull request #%d closed", input.PullRequest)
+	// Oldest first, the scan treats a pull request whose newest deployment is inactive as torn down
+	for i := len(deployments) - 1; i >= 0; i-- {
+		deployment := deployments[i]
+		activity.RecordHeartbeat(ctx, deployment.GetID())
+
+		deactivated, err := githubClient.DeactivateDeployment(ctx, client, input.GithubOwner, input.GithubRepo, deployment.GetID(), description)
+		if err != nil {
+			logger.Error().
+				Err(err).
+				Str("github_owner", input.GithubOwner).
+				Str("github_repo", input.GithubRepo).
+				Int64("deployment_id", deployment.GetID()).
+				Msg("Failed to deactivate deployment")
+			return nil, err
+		}
+		if deactivated {
+			result.Deactivated = append(result.Deactivated, deployment.GetID())
+		}
+		if !environments[deployment.GetEnvironment()] {
+			environments[deployment.GetEnvironment()] = true
+			result.Environments = append(result.Environments, deployment.GetEnvironment())
+		}
+
+		if input.DeleteDeployments {
+			if _, err := client.DeleteDeployment(ctx, input.GithubOwner, input.GithubRepo, deployment.GetID()); err != nil {
+				return nil, fmt.Errorf("failed to delete deployment %d: %w", deployment.GetID(), err)
+			}
+			result.Deleted = append(result.Deleted, deployment.GetID())
+		}
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int("pull_request", input.PullRequest).
+		Int("deployments", len(deployments)).
+		Int("deactivated", len(result.Deactivated)).
+		Int("deleted", len(result.Deleted)).
+		Strs("environments", result.Environments).
+		Msg("Successfully tore down pull request deployments")
+
+	return result, nil
+}
+
+// FindClosedPullRequests returns the closed pull requests of a repository whose deployments are still active
+// It backs the periodic scan that catches pull_request webhooks that were missed
+func (a *GitHubActivities) FindClosedPullRequests(ctx context.Context, input FindClosedPullRequestsInput) ([]ClosedPullRequest, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("FindClosedPullRequests", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Bool("include_environments", input.IncludeEnvironments).
+		Msg("Scanning for closed pull requests with active deployments")
+
+	if a.clientFactory == nil {
+		return nil, fmt.Errorf("scanning pull requests requires a GitHub client factory")
+	}
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	required := []githubClient.Permission{
+		githubClient.Read(githubClient.PermissionDeployments),
+		githubClient.Read(githubClient.PermissionPullRequests),
+	}
+	if input.IncludeEnvironments {
+		required = append(required, githubClient.Read(githubClient.PermissionEnvironments))
+	}
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, required...); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.clientFactory.CreateClientForHost(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Listing deployments")
+
+	numbers, err := githubClient.ActivePullRequests(ctx, client.Repositories, input.GithubOwner, input.GithubRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.IncludeEnvironments {
+		activity.RecordHeartbeat(ctx, "Listing environments")
+
+		leftover, err := githubClient.NewEnvironmentManager(client).PullRequestEnvironments(ctx, input.GithubOwner, input.GithubRepo)
+		if err != nil {
+			return nil, err
+		}
+		numbers = appendMissing(numbers, leftover...)
+	}
+
+	var closed []ClosedPullRequest
+	for _, number := range numbers {
+		activity.RecordHeartbeat(ctx, number)
+
+		pull, _, err := client.PullRequests.Get(ctx, input.GithubOwner, input.GithubRepo, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pull request %s/%s#%d: %w", input.GithubOwner, input.GithubRepo, number, err)
+		}
+		if pull.GetState() == "closed" {
+			closed = append(closed, ClosedPullRequest{Number: number, Merged: pull.GetMerged()})
+		}
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int("active_pull_requests", len(numbers)).
+		Int("closed_pull_requests", len(closed)).
+		Msg("Scanned for closed pull requests")
+
+	return closed, nil
+}
+
+// appendMissing appends the numbers not in the list yet
+func appendMissing(numbers []int, more ...int) []int {
+	seen := make(map[int]bool, len(numbers))
+	for _, number := range numbers {
+		seen[number] = true
+	}
+	for _, number := range more {
+		if !seen[number] {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+	return numbers
+}
+
+// TeardownActivities notifies the infrastructure about torn down pull request environments
+type TeardownActivities struct {
+	config     config.TeardownConfig
+	overrides  config.Overrides // Per-organization and repository event sinks
+	github     *GitHubActivities
+	httpClient *http.Client
+}
+
+// NewTeardownActivities creates the teardown event activities
+// github resolves the host overrides are matched against, nil matches the event's host as sent
+func NewTeardownActivities(cfg config.TeardownConfig, overrides config.Overrides, github *GitHubActivities) *TeardownActivities {
+	return &TeardownActivities{
+		config:     cfg,
+		overrides:  overrides,
+		github:     github,
+		httpClient: &http.Client{Timeout: cfg.EventTimeout},
+	}
+}
+
+// cloudEvent is a CloudEvents 1.0 event in structured JSON mode
+type cloudEvent struct {
+	SpecVersion     string        `json:"specversion"`
+	ID              string        `json:"id"`
+	Source          string        `json:"source"`
+	Type            string        `json:"type"`
+	Subject         string        `json:"subject"`
+	Time            string        `json:"time"`
+	DataContentType string        `json:"datacontenttype"`
+	Data            TeardownEvent `json:"data"`
+}
+
+// EmitTeardownEvent posts the teardown CloudEvent to TEARDOWN_EVENT_SINK_URL, a no-op when it is unset
+// An override in the configuration file can send a repository's events elsewhere
+func (t *TeardownActivities) EmitTeardownEvent(ctx context.Context, input EmitTeardownEventInput) error {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("EmitTeardownEvent", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	logger.Info().
+		Str("event_id", input.EventID).
+		Str("github_owner", input.Event.GithubOwner).
+		Str("github_repo", input.Event.GithubRepo).
+		Int("pull_request", input.Event.PullRequest).
+		Strs("environments", input.Event.Environments).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Emitting teardown event")
+
+	host := input.Event.GithubHost
+	if t.github != nil {
+		host = t.github.hostName(host, input.Event.GithubOwner)
+	}
+	sinkURL := t.overrides.TeardownEventSinkURL(host, input.Event.GithubOwner, input.Event.GithubRepo, t.config.EventSinkURL)
+	if sinkURL == "" {
+		logger.Debug().Msg("No teardown event sink configured")
+		return nil
+	}
+
+	event := cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              input.EventID,
+		Source:          t.config.EventSource,
+		Type:            TeardownEventType,
+		Subject:         fmt.Sprintf("%s/%s#%d", input.Event.GithubOwner, input.Event.GithubRepo, input.Event.PullRequest),
+		Time:            time.Now().UTC().Format(time.RFC3339),
+		DataContentType: "application/json",
+		Data:            input.Event,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode teardown event: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sinkURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create teardown event request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+
+	// Record heartbeat before the call
+	activity.RecordHeartbeat(ctx, "Posting teardown event")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post teardown event to %s: %w", sinkURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("teardown event sink %s responded with %s", sinkURL, resp.Status)
+	}
+
+	logger.Info().
+		Str("event_id", input.EventID).
+		Int("http_status", resp.StatusCode).
+		Msg("Successfully emitted teardown event")
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package activities
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// RenderDeploymentURLsInput represents the URLs an event sent for a deployment
+type RenderDeploymentURLsInput struct {
+	GithubHost     string `json:"github_host,omitempty"`
+	GithubOwner    string `json:"github_owner"`
+	GithubRepo     string `json:"github_repo"`
+	Environment    string `json:"environment"`
+	DeploymentID   int64  `json:"deployment_id"`
+	LogURL         string `json:"log_url,omitempty"`
+	EnvironmentURL string `json:"environment_url,omitempty"`
+}
+
+// DeploymentURLs are a deployment's log and environment URLs
+type DeploymentURLs struct {
+	LogURL         string `json:"log_url,omitempty"`
+	EnvironmentURL string `json:"environment_url,omitempty"`
+}
+
+// RenderDeploymentURLs fills in the URLs the event omitted from the templates in environments.yaml
+// URLs the event sent are kept, and the deployment is only read when a template has to be rendered
+func (a *GitHubActivities) RenderDeploymentURLs(ctx context.Context, input RenderDeploymentURLsInput) (*DeploymentURLs, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("RenderDeploymentURLs", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	urls := &DeploymentURLs{LogURL: input.LogURL, EnvironmentURL: input.EnvironmentURL}
+	if a.environments == nil {
+		return urls, nil
+	}
+
+	templates := a.environments.URLTemplates(a.hostName(input.GithubHost, input.GithubOwner), input.GithubOwner, input.GithubRepo, input.Environment)
+	if urls.LogURL != "" {
+		templates.LogURL = ""
+	}
+	if urls.EnvironmentURL != "" {
+		templates.EnvironmentURL = ""
+	}
+	if templates.IsEmpty() {
+		return urls, nil
+	}
+
+	logger.Info().
+		Str("github_host", input.GithubHost).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Int64("deployment_id", input.DeploymentID).
+		Bool("log_url_template", templates.LogURL != "").
+		Bool("environment_url_template", templates.EnvironmentURL != "").
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Rendering deployment URLs")
+
+	// Record heartbeat
+	activity.RecordHeartbeat(ctx, "Checking GitHub App permissions")
+
+	if err := a.preflight(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, githubClient.Read(githubClient.PermissionDeployments)); err != nil {
+		logger.Error().
+			Err(err).
+			Str("github_owner", input.GithubOwner).
+			Str("github_repo", input.GithubRepo).
+			Msg("GitHub App installation permission check failed")
+		return nil, err
+	}
+
+	activity.RecordHeartbeat(ctx, "Creating GitHub client")
+
+	client, err := a.deployments.DeploymentClient(ctx, input.GithubHost, input.GithubOwner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GitHub client for organization %s: %w", input.GithubOwner, err)
+	}
+
+	activity.RecordHeartbeat(ctx, "Calling GitHub API")
+
+	deployment, _, err := client.GetDeployment(ctx, input.GithubOwner, input.GithubRepo, input.DeploymentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get deployment %d: %w", input.DeploymentID, err)
+	}
+
+	data := githubClient.NewDeploymentURLData(input.GithubHost, input.GithubOwner, input.GithubRepo, deployment)
+	if templates.LogURL != "" {
+		if urls.LogURL, err = githubClient.RenderDeploymentURL(templates.LogURL, data); err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("log_url: %v", err), "ValidationError", err)
+		}
+	}
+	if templates.EnvironmentURL != "" {
+		if urls.EnvironmentURL, err = githubClient.RenderDeploymentURL(templates.EnvironmentURL, data); err != nil {
+			return nil, temporal.NewNonRetryableApplicationError(fmt.Sprintf("environment_url: %v", err), "ValidationError", err)
+		}
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int64("deployment_id", input.DeploymentID).
+		Str("log_url", urls.LogURL).
+		Str("environment_url", urls.EnvironmentURL).
+		Msg("Rendered deployment URLs")
+
+	return urls, nil
+}
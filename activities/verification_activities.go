@@ -0,0 +1,286 @@
+package activities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// Limits of a single verification request
+const (
+	verificationRequestTimeout = 10 * time.Second
+	verificationMaxBody        = 1 << 20
+
+	// Well below the workflow's heartbeat timeout
+	verificationHeartbeatInterval = 15 * time.Second
+)
+
+// VerifyDeploymentInput represents a deployment about to be marked successful
+type VerifyDeploymentInput struct {
+	GithubOwner    string `json:"github_owner"`
+	GithubRepo     string `json:"github_repo"`
+	Environment    string `json:"environment"`
+	EnvironmentURL string `json:"environment_url"`
+	CommitSHA      string `json:"commit_sha"`
+
+	// Checks to run instead of the environment's verification in environments.yaml
+	Policy *config.VerificationPolicy `json:"policy,omitempty"`
+}
+
+// VerifyDeploymentResult is the outcome of the post-deploy checks
+type VerifyDeploymentResult struct {
+	// Why nothing was checked, e.g. no verification configured
+	Skipped string `json:"skipped,omitempty"`
+
+	Passed bool `json:"passed"`
+	Rounds int  `json:"rounds"`
+
+	// Failed checks of the last round, empty when every check passed
+	Output string `json:"output,omitempty"`
+}
+
+// VerificationActivities runs HTTP checks against a deployed environment
+type VerificationActivities struct {
+	environments *config.EnvironmentsSpec
+	httpClient   *http.Client
+}
+
+// NewVerificationActivities creates the verification activities
+// A nil spec leaves only the policies passed with each request
+func NewVerificationActivities(environments *config.EnvironmentsSpec) *VerificationActivities {
+	if environments == nil {
+		environments = &config.EnvironmentsSpec{}
+	}
+	return &VerificationActivities{
+		environments: environments,
+		httpClient:   &http.Client{Timeout: verificationRequestTimeout},
+	}
+}
+
+// VerifyDeployment polls the environment URL until every check passes or the policy's timeout expires
+// Failing checks are not an error, the result reports them so the workflow can mark the deployment failed
+func (v *VerificationActivities) VerifyDeployment(ctx context.Context, input VerifyDeploymentInput) (*VerifyDeploymentResult, error) {
+	activityInfo := activity.GetInfo(ctx)
+	logger := logging.ActivityLogger("VerifyDeployment", activityInfo.WorkflowExecution.ID, activityInfo.WorkflowExecution.RunID)
+
+	policy := input.Policy
+	if policy == nil {
+		if environment := v.environments.Environment(input.Environment); environment != nil {
+			policy = environment.Verification
+		}
+	}
+
+	logger.Info().
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", input.Environment).
+		Str("environment_url", input.EnvironmentURL).
+		Str("commit", input.CommitSHA).
+		Bool("has_policy", policy != nil).
+		Str("activity_id", activityInfo.ActivityID).
+		Int32("attempt", activityInfo.Attempt).
+		Msg("Verifying deployment")
+
+	if policy == nil {
+		return &VerifyDeploymentResult{Skipped: fmt.Sprintf("no verification configured for %s", input.Environment)}, nil
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid verification policy: %v", err), "ValidationError", err)
+	}
+	if input.EnvironmentURL == "" {
+		return &VerifyDeploymentResult{Output: "no environment URL to verify"}, nil
+	}
+
+	deadline := time.Now().Add(policy.TimeoutOrDefault())
+	result := &VerifyDeploymentResult{}
+	for {
+		result.Rounds++
+
+		var failures []string
+		for i, check := range policy.Checks {
+			activity.RecordHeartbeat(ctx, fmt.Sprintf("Round %d, check %d of %d", result.Rounds, i+1, len(policy.Checks)))
+			if err := v.runCheck(ctx, input.EnvironmentURL, input.CommitSHA, check); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", checkName(check), err))
+			}
+		}
+
+		if len(failures) == 0 {
+			result.Passed = true
+			result.Output = ""
+			logger.Info().
+				Str("environment_url", input.EnvironmentURL).
+				Int("rounds", result.Rounds).
+				Msg("Deployment verification passed")
+			return result, nil
+		}
+		result.Output = strings.Join(failures, "; ")
+
+		logger.Warn().
+			Str("environment_url", input.EnvironmentURL).
+			Int("round", result.Rounds).
+			Str("output", result.Output).
+			Msg("Deployment verification failed, retrying")
+
+		wait := policy.IntervalOrDefault()
+		if remaining := time.Until(deadline); remaining < wait {
+			if remaining <= 0 {
+				break
+			}
+			wait = remaining
+		}
+		if err := waitWithHeartbeat(ctx, wait, fmt.Sprintf("Waiting %s after round %d", wait, result.Rounds)); err != nil {
+			return nil, err
+		}
+	}
+
+	logger.Error().
+		Str("environment_url", input.EnvironmentURL).
+		Int("rounds", result.Rounds).
+		Str("output", result.Output).
+		Msg("Deployment verification failed")
+
+	return result, nil
+}
+
+// waitWithHeartbeat pauses between rounds, heartbeating so a long interval doesn't time the activity out
+func waitWithHeartbeat(ctx context.Context, wait time.Duration, details string) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	ticker := time.NewTicker(verificationHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			activity.RecordHeartbeat(ctx, details)
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// runCheck performs one GET and returns why it failed, nil when it passed
+func (v *VerificationActivities) runCheck(ctx context.Context, baseURL, sha string, check config.HTTPCheck) error {
+	url := strings.TrimSuffix(baseURL, "/") + check.Path
+	if check.Path == "" {
+		url = baseURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, verificationMaxBody))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if !statusExpected(resp.StatusCode, check.ExpectStatus) {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if check.Equals == "" && !check.ExpectSHA {
+		return nil
+	}
+
+	value := strings.TrimSpace(string(body))
+	if check.JSONField != "" {
+		var document interface{}
+		if err := json.Unmarshal(body, &document); err != nil {
+			return fmt.Errorf("response is not JSON")
+		}
+		field, ok := jsonField(document, check.JSONField)
+		if !ok {
+			return fmt.Errorf("%s missing from response", check.JSONField)
+		}
+		value = field
+	}
+
+	if check.ExpectSHA && !sameCommit(value, sha) {
+		return fmt.Errorf("reports %s, expected %s", truncateDescription(value, 40), shortSHA(sha))
+	}
+	if check.Equals != "" && value != check.Equals {
+		return fmt.Errorf("got %q, expected %q", truncateDescription(value, 40), check.Equals)
+	}
+	return nil
+}
+
+// checkName labels a check in the verification output
+func checkName(check config.HTTPCheck) string {
+	if check.Name != "" {
+		return check.Name
+	}
+	if check.Path == "" {
+		return "GET /"
+	}
+	return "GET " + check.Path
+}
+
+// statusExpected accepts any 2xx when no codes are listed
+func statusExpected(code int, expected []int) bool {
+	if len(expected) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, want := range expected {
+		if code == want {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonField walks a dotted path through objects and arrays, e.g. build.commit or services.0.version
+func jsonField(document interface{}, path string) (string, bool) {
+	current := document
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return "", false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(node) {
+				return "", false
+			}
+			current = node[index]
+		default:
+			return "", false
+		}
+	}
+	if s, ok := current.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(current), true
+}
+
+// sameCommit compares full or abbreviated (7+ characters) commit SHAs
+func sameCommit(reported, sha string) bool {
+	reported = strings.ToLower(strings.TrimSpace(reported))
+	sha = strings.ToLower(sha)
+	if len(reported) < 7 || sha == "" {
+		return false
+	}
+	return strings.HasPrefix(sha, reported) || strings.HasPrefix(reported, sha)
+}
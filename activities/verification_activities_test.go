@@ -0,0 +1,121 @@
+package activities
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+func verify(t *testing.T, input VerifyDeploymentInput) (*VerifyDeploymentResult, error) {
+	t.Helper()
+
+	var suite testsuite.WorkflowTestSuite
+	env := suite.NewTestActivityEnvironment()
+	verification := NewVerificationActivities(nil)
+	env.RegisterActivity(verification)
+
+	value, err := env.ExecuteActivity(verification.VerifyDeployment, input)
+	if err != nil {
+		return nil, err
+	}
+	var result VerifyDeploymentResult
+	require.NoError(t, value.Get(&result))
+	return &result, nil
+}
+
+// versionServer reports the deployed commit once ready requests have been answered with 503
+func versionServer(t *testing.T, sha string, ready int32) *httptest.Server {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"build": map[string]string{"commit": sha}})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestVerifyDeploymentRetriesUntilPassing(t *testing.T) {
+	server := versionServer(t, "0123456789abcdef", 2)
+
+	result, err := verify(t, VerifyDeploymentInput{
+		Environment:    "staging",
+		EnvironmentURL: server.URL,
+		CommitSHA:      "0123456789abcdef",
+		Policy: &config.VerificationPolicy{
+			Timeout:  10 * time.Second,
+			Interval: 10 * time.Millisecond,
+			Checks:   []config.HTTPCheck{{Path: "/version", JSONField: "build.commit", ExpectSHA: true}},
+		},
+	})
+	require.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.Equal(t, 3, result.Rounds)
+}
+
+func TestVerifyDeploymentReportsWrongCommit(t *testing.T) {
+	server := versionServer(t, "fedcba9876543210", 0)
+
+	result, err := verify(t, VerifyDeploymentInput{
+		Environment:    "staging",
+		EnvironmentURL: server.URL,
+		CommitSHA:      "0123456789abcdef",
+		Policy: &config.VerificationPolicy{
+			Timeout:  50 * time.Millisecond,
+			Interval: 10 * time.Millisecond,
+			Checks:   []config.HTTPCheck{{Path: "/version", JSONField: "build.commit", ExpectSHA: true}},
+		},
+	})
+	require.NoError(t, err)
+	assert.False(t, result.Passed)
+	assert.Contains(t, result.Output, "expected 0123456")
+}
+
+func TestVerifyDeploymentRejectsLongInterval(t *testing.T) {
+	_, err := verify(t, VerifyDeploymentInput{
+		Environment:    "staging",
+		EnvironmentURL: "http://staging.example.com",
+		Policy: &config.VerificationPolicy{
+			Interval: config.MaxVerificationInterval + time.Minute,
+			Checks:   []config.HTTPCheck{{Path: "/healthz"}},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "verification interval must be at most")
+}
+
+func TestVerifyDeploymentDurationStrings(t *testing.T) {
+	server := versionServer(t, "0123456789abcdef", 1)
+
+	// Workflow input written by hand uses duration strings
+	var input VerifyDeploymentInput
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"environment": "staging",
+		"environment_url": "`+server.URL+`",
+		"commit_sha": "0123456789abcdef",
+		"policy": {"timeout": "10s", "interval": "10ms", "checks": [{"path": "/version", "json_field": "build.commit", "expect_sha": true}]}
+	}`), &input))
+	assert.Equal(t, 10*time.Millisecond, input.Policy.Interval)
+
+	result, err := verify(t, input)
+	require.NoError(t, err)
+	assert.True(t, result.Passed)
+	assert.Equal(t, 2, result.Rounds)
+}
+
+func TestVerifyDeploymentSkippedWithoutPolicy(t *testing.T) {
+	result, err := verify(t, VerifyDeploymentInput{Environment: "staging", EnvironmentURL: "http://staging.example.com"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, result.Skipped)
+}
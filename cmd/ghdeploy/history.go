@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/logging"
+)
+
+// runHistory exports deployments with their full status timelines
+//
+//	ghdeploy history -org acme -env production -since 2026-07-01 -until 2026-09-30 -format csv -out q3.csv
+func runHistory(args []string) error {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	repos := flags.String("repo", "", "comma-separated owner/name repositories to export")
+	org := flags.String("org", "", "export every repository the App can access in this organization")
+	hostName := flags.String("host", "", "registered GitHub host name, empty routes by owner")
+	environment := flags.String("env", "", "only export deployments to this environment")
+	since := flags.String("since", "", "only deployments created at or after this date (YYYY-MM-DD or RFC 3339)")
+	until := flags.String("until", "", "only deployments created before the end of this date (YYYY-MM-DD or RFC 3339)")
+	format := flags.String("format", "json", "output format: json, csv or ndjson")
+	out := flags.String("out", "", "output file, stdout when empty")
+	options := config.BindFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if (*repos == "") == (*org == "") {
+		return fmt.Errorf("exactly one of -repo or -org is required")
+	}
+
+	query := githubClient.HistoryQuery{Environment: *environment}
+	var err error
+	if query.Since, err = parseDate(*since, false); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if query.Until, err = parseDate(*until, true); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	output := io.Writer(os.Stdout)
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer file.Close()
+		output = file
+	}
+
+	writer, err := newHistoryWriter(*format, output)
+	if err != nil {
+		return err
+	}
+
+	// Load configuration
+	cfg, err := config.LoadWithOptions(*options)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Logs go to stderr so stdout only carries the export
+	logging.InitLoggerTo(os.Stderr, cfg.App.LogLevel, cfg.App.LogFormat)
+	logger := logging.GitHubLogger().With().Str("component", "ghdeploy").Logger()
+
+	factory, err := githubClient.NewClientFactory(cfg.GitHub, cfg.Secrets, logger)
+	if err != nil {
+		return fmt.Errorf("failed to create GitHub client factory: %w", err)
+	}
+	retrier := factory.RateLimitRetrier()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if *org != "" {
+		client, err := factory.CreateClientForHost(ctx, *hostName, *org)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub client for organization %s: %w", *org, err)
+		}
+		query.Repositories, err = githubClient.InstallationRepositories(ctx, client, retrier)
+		if err != nil {
+			return err
+		}
+
+		// One GraphQL query per batch of repositories reads their latest deployment,
+		// so only repositories deployed in the range are exported through the REST API
+		bulk := githubClient.BulkDeploymentQuery{Repositories: query.Repositories}
+		if *environment != "" {
+			bulk.Environments = []string{*environment}
+		}
+		latest, err := factory.FetchDeployments(ctx, *hostName, bulk)
+		if err != nil {
+			return err
+		}
+		installed := len(query.Repositories)
+		query.Repositories = githubClient.DeployedRepositories(latest, query.Since)
+		logger.Info().
+			Str("org", *org).
+			Int("repositories", installed).
+			Int("deployed", len(query.Repositories)).
+			Msg("Selected repositories with deployments to export")
+	} else {
+		for _, name := range strings.Split(*repos, ",") {
+			owner, repo, found := strings.Cut(strings.TrimSpace(name), "/")
+			if !found || owner == "" || repo == "" {
+				return fmt.Errorf("repository %q must be owner/name", name)
+			}
+			query.Repositories = append(query.Repositories, githubClient.RepositoryRef{Owner: owner, Name: repo})
+		}
+	}
+
+	exported := 0
+	for _, repo := range query.Repositories {
+		client, err := factory.DeploymentClient(ctx, *hostName, repo.Owner)
+		if err != nil {
+			return fmt.Errorf("failed to create GitHub client for organization %s: %w", repo.Owner, err)
+		}
+
+		count := 0
+		err = githubClient.ExportHistory(ctx, client, retrier, repo, query, func(record githubClient.HistoryRecord) error {
+			count++
+			return writer.Write(record)
+		})
+		if err != nil {
+			return err
+		}
+		exported += count
+
+		logger.Info().
+			Str("repository", repo.String()).
+			Int("deployments", count).
+			Msg("Exported deployment history")
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to write %s output: %w", *format, err)
+	}
+
+	logger.Info().
+		Int("repositories", len(query.Repositories)).
+		Int("deployments", exported).
+		Str("format", *format).
+		Msg("Deployment history export completed")
+
+	return nil
+}
+
+// parseDate accepts RFC 3339 or YYYY-MM-DD (UTC), endOfDay moves a plain date to the next midnight
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither YYYY-MM-DD nor RFC 3339", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// historyWriter serializes exported records as they are read
+type historyWriter interface {
+	Write(record githubClient.HistoryRecord) error
+	Close() error
+}
+
+func newHistoryWriter(format string, out io.Writer) (historyWriter, error) {
+	switch format {
+	case "json":
+		return &jsonHistoryWriter{out: out}, nil
+	case "ndjson":
+		return &ndjsonHistoryWriter{encoder: json.NewEncoder(out)}, nil
+	case "csv":
+		return newCSVHistoryWriter(out), nil
+	default:
+		return nil, fmt.Errorf("unknown format %q (valid: json, csv, ndjson)", format)
+	}
+}
+
+// jsonHistoryWriter streams a single JSON array
+type jsonHistoryWriter struct {
+	out     io.Writer
+	written int
+}
+
+func (w *jsonHistoryWriter) Write(record githubClient.HistoryRecord) error {
+	data, err := json.MarshalIndent(record, "  ", "  ")
+	if err != nil {
+		return err
+	}
+	separator := ",\n  "
+	if w.written == 0 {
+		separator = "[\n  "
+	}
+	w.written++
+	if _, err := io.WriteString(w.out, separator); err != nil {
+		return err
+	}
+	_, err = w.out.Write(data)
+	return err
+}
+
+func (w *jsonHistoryWriter) Close() error {
+	closing := "\n]\n"
+	if w.written == 0 {
+		closing = "[]\n"
+	}
+	_, err := io.WriteString(w.out, closing)
+	return err
+}
+
+// ndjsonHistoryWriter writes one deployment per line
+type ndjsonHistoryWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonHistoryWriter) Write(record githubClient.HistoryRecord) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *ndjsonHistoryWriter) Close() error {
+	return nil
+}
+
+// csvHistoryWriter writes one row per status, repeating the deployment columns
+// Deployments without statuses get a single row with empty status columns
+type csvHistoryWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+var csvHistoryHeader = []string{
+	"repository", "deployment_id", "environment", "task", "sha", "ref", "deployment_creator",
+	"deployment_created_at", "deployment_description", "harness_execution_id",
+	"status_state", "status_description", "status_creator", "status_created_at",
+	"environment_url", "log_url",
+}
+
+func newCSVHistoryWriter(out io.Writer) *csvHistoryWriter {
+	return &csvHistoryWriter{writer: csv.NewWriter(out)}
+}
+
+func (w *csvHistoryWriter) Write(record githubClient.HistoryRecord) error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHistoryHeader); err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	var executionID string
+	if record.Payload != nil {
+		executionID = record.Payload.ExecutionID()
+	}
+	deployment := []string{
+		record.Repository,
+		strconv.FormatInt(record.ID, 10),
+		record.Environment,
+		record.Task,
+		record.SHA,
+		record.Ref,
+		record.Creator,
+		record.CreatedAt.UTC().Format(time.RFC3339),
+		record.Description,
+		executionID,
+	}
+
+	if len(record.Statuses) == 0 {
+		return w.writer.Write(append(deployment, make([]string, 6)...))
+	}
+	for _, status := range record.Statuses {
+		row := append(append([]string{}, deployment...),
+			status.State,
+			status.Description,
+			status.Creator,
+			status.CreatedAt.UTC().Format(time.RFC3339),
+			status.EnvironmentURL,
+			status.LogURL,
+		)
+		if err := w.writer.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *csvHistoryWriter) Close() error {
+	if !w.headerWritten {
+		if err := w.writer.Write(csvHistoryHeader); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
@@ -0,0 +1,42 @@
+// Command ghdeploy is the operator CLI for GitHub deployments tracked by the workflows
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// commands maps subcommand names to their entry points
+var commands = map[string]func(args []string) error{
+	"history":  runHistory,
+	"redeploy": runRedeploy,
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `Usage: ghdeploy <command> [flags]
+
+Commands:
+  history   Export deployments and their status timelines as JSON, CSV or NDJSON
+  redeploy  Re-post the last status of, copy or mark inactive an existing deployment
+
+Run "ghdeploy <command> -h" for the flags of a command.`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command, exists := commands[os.Args[1]]
+	if !exists {
+		fmt.Fprintf(os.Stderr, "ghdeploy: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := command(os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "ghdeploy %s: %v\n", os.Args[1], err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.temporal.io/sdk/client"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/workflows"
+)
+
+// runRedeploy starts RedeployWorkflow for an existing deployment and prints its result
+//
+//	ghdeploy redeploy -repo acme/api -env production -action remark
+//	ghdeploy redeploy -repo acme/api -deployment 123456 -action inactive -description "Replaced by blue/green cutover"
+func runRedeploy(args []string) error {
+	flags := flag.NewFlagSet("redeploy", flag.ContinueOnError)
+	repoName := flags.String("repo", "", "owner/name of the repository")
+	hostName := flags.String("host", "", "registered GitHub host name, empty routes by owner")
+	deploymentID := flags.Int64("deployment", 0, "deployment ID, 0 selects the newest deployment to -env")
+	environment := flags.String("env", "", "environment whose newest deployment is selected when -deployment is not set")
+	action := flags.String("action", workflows.RedeployActionRemark, "remark, redeploy or inactive")
+	state := flags.String("state", "", "status posted on a redeployment, defaults to the source's last active state")
+	description := flags.String("description", "", "status description, defaults to the source's or a generated one")
+	wait := flags.Bool("wait", true, "wait for the workflow and print its result")
+	options := config.BindFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	owner, repo, found := strings.Cut(*repoName, "/")
+	if !found || owner == "" || repo == "" {
+		return fmt.Errorf("-repo must be owner/name")
+	}
+	if *deploymentID == 0 && *environment == "" {
+		return fmt.Errorf("one of -deployment or -env is required")
+	}
+
+	// Load configuration
+	cfg, err := config.LoadWithOptions(*options)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// Logs go to stderr so stdout only carries the result
+	logging.InitLoggerTo(os.Stderr, cfg.App.LogLevel, cfg.App.LogFormat)
+	logger := logging.GitHubLogger().With().Str("component", "ghdeploy").Logger()
+
+	// Create Temporal client
+	temporalClient, err := client.Dial(client.Options{
+		HostPort:  cfg.Temporal.HostPort,
+		Namespace: cfg.Temporal.Namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create Temporal client: %w", err)
+	}
+	defer temporalClient.Close()
+
+	input := workflows.RedeployWorkflowInput{
+		GithubHost:   *hostName,
+		GithubOwner:  owner,
+		GithubRepo:   repo,
+		DeploymentID: *deploymentID,
+		Environment:  *environment,
+		Action:       *action,
+		State:        *state,
+		Description:  *description,
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("redeploy-%s-%s-%s", owner, repo, time.Now().Format("20060102-150405")),
+		TaskQueue: cfg.Temporal.TaskQueue,
+	}
+
+	ctx := context.Background()
+	workflowRun, err := temporalClient.ExecuteWorkflow(ctx, workflowOptions, workflows.RedeployWorkflow, input)
+	if err != nil {
+		return fmt.Errorf("failed to start redeploy workflow: %w", err)
+	}
+
+	logger.Info().
+		Str("workflow_id", workflowRun.GetID()).
+		Str("run_id", workflowRun.GetRunID()).
+		Str("repository", *repoName).
+		Int64("deployment_id", *deploymentID).
+		Str("environment", *environment).
+		Str("action", *action).
+		Msg("Redeploy workflow started")
+
+	if !*wait {
+		return nil
+	}
+
+	var result workflows.RedeployWorkflowResult
+	if err := workflowRun.Get(ctx, &result); err != nil {
+		return fmt.Errorf("redeploy workflow failed: %w", err)
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
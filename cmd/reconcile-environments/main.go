@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"go.temporal.io/sdk/client"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/workflows"
+)
+
+func main() {
+	options := config.BindFlags(flag.CommandLine)
+	specPath := flag.String("file", "", "declarative environments file (default GITHUB_ENVIRONMENTS_FILE)")
+	dryRun := flag.Bool("dry-run", false, "report drift without changing any repository")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.LoadWithOptions(*options)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *specPath == "" {
+		*specPath = cfg.GitHub.EnvironmentsFile
+	}
+
+	// Initialize logger
+	logging.InitLogger(cfg.App.LogLevel, cfg.App.LogFormat)
+	logger := logging.GitHubLogger().With().Str("component", "reconcile-environments").Logger()
+
+	spec, err := config.LoadEnvironmentsSpec(*specPath)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load environments file")
+	}
+
+	// Create Temporal client
+	temporalClient, err := client.Dial(client.Options{
+		HostPort:  cfg.Temporal.HostPort,
+		Namespace: cfg.Temporal.Namespace,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create Temporal client")
+	}
+	defer temporalClient.Close()
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        "reconcile-environments-" + time.Now().Format("20060102-150405"),
+		TaskQueue: cfg.Temporal.TaskQueue,
+	}
+
+	workflowRun, err := temporalClient.ExecuteWorkflow(
+		context.Background(),
+		workflowOptions,
+		workflows.ReconcileEnvironmentsWorkflow,
+		workflows.ReconcileEnvironmentsInput{Spec: *spec, DryRun: *dryRun},
+	)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to start environment reconcile workflow")
+	}
+
+	logger.Info().
+		Str("workflow_id", workflowRun.GetID()).
+		Str("run_id", workflowRun.GetRunID()).
+		Str("file", *specPath).
+		Bool("dry_run", *dryRun).
+		Msg("Environment reconcile workflow started")
+
+	var result workflows.ReconcileEnvironmentsResult
+	if err := workflowRun.Get(context.Background(), &result); err != nil {
+		logger.Fatal().Err(err).Msg("Environment reconcile workflow failed")
+	}
+
+	for _, outcome := range result.Outcomes {
+		if outcome.Error != "" {
+			logger.Error().
+				Str("error", outcome.Error).
+				Str("github_owner", outcome.GithubOwner).
+				Str("github_repo", outcome.GithubRepo).
+				Str("environment", outcome.Environment).
+				Msg("Failed to reconcile environment")
+			continue
+		}
+		logger.Info().
+			Bool("changed", outcome.Result.Changed).
+			Interface("changes", outcome.Result.Changes).
+			Str("github_owner", outcome.GithubOwner).
+			Str("github_repo", outcome.GithubRepo).
+			Str("environment", outcome.Environment).
+			Msg("Environment reconciled")
+	}
+
+	logger.Info().
+		Int("applied", result.Applied).
+		Int("changed", result.Changed).
+		Int("failed", result.Failed).
+		Bool("dry_run", result.DryRun).
+		Msg("Environment reconcile completed")
+}
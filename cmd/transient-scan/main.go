@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"time"
+
+	"go.temporal.io/sdk/client"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/workflows"
+)
+
+func main() {
+	options := config.BindFlags(flag.CommandLine)
+	specPath := flag.String("file", "", "environments file listing the repositories to scan (default GITHUB_ENVIRONMENTS_FILE)")
+	schedule := flag.String("schedule", "", "Temporal cron schedule of the scan (default TEARDOWN_SCAN_SCHEDULE)")
+	once := flag.Bool("once", false, "run a single scan and wait for its result instead of scheduling it")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.LoadWithOptions(*options)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	if *specPath == "" {
+		*specPath = cfg.GitHub.EnvironmentsFile
+	}
+	if *schedule == "" {
+		*schedule = cfg.Teardown.ScanSchedule
+	}
+
+	// Initialize logger
+	logging.InitLogger(cfg.App.LogLevel, cfg.App.LogFormat)
+	logger := logging.GitHubLogger().With().Str("component", "transient-scan").Logger()
+
+	spec, err := config.LoadEnvironmentsSpec(*specPath)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to load environments file")
+	}
+	if len(spec.Repositories) == 0 {
+		logger.Fatal().Str("file", *specPath).Msg("Environments file lists no repositories to scan")
+	}
+
+	// Create Temporal client
+	temporalClient, err := client.Dial(client.Options{
+		HostPort:  cfg.Temporal.HostPort,
+		Namespace: cfg.Temporal.Namespace,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create Temporal client")
+	}
+	defer temporalClient.Close()
+
+	input := workflows.TransientEnvironmentScanInput{
+		Repositories:      spec.Repositories,
+		DeleteDeployments: cfg.Teardown.DeleteDeployments,
+		DeleteEnvironment: cfg.Teardown.DeleteEnvironments,
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:        "transient-environment-scan",
+		TaskQueue: cfg.Temporal.TaskQueue,
+	}
+	if *once {
+		workflowOptions.ID = "transient-environment-scan-" + time.Now().Format("20060102-150405")
+	} else {
+		workflowOptions.CronSchedule = *schedule
+	}
+
+	workflowRun, err := temporalClient.ExecuteWorkflow(context.Background(), workflowOptions, workflows.TransientEnvironmentScanWorkflow, input)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to start transient environment scan workflow")
+	}
+
+	logger.Info().
+		Str("workflow_id", workflowRun.GetID()).
+		Str("run_id", workflowRun.GetRunID()).
+		Str("file", *specPath).
+		Str("schedule", workflowOptions.CronSchedule).
+		Int("repository_targets", len(spec.Repositories)).
+		Msg("Transient environment scan workflow started")
+
+	if !*once {
+		return
+	}
+
+	var result workflows.TransientEnvironmentScanResult
+	if err := workflowRun.Get(context.Background(), &result); err != nil {
+		logger.Fatal().Err(err).Msg("Transient environment scan workflow failed")
+	}
+
+	logger.Info().
+		Int("scanned", result.Scanned).
+		Strs("torn_down", result.TornDown).
+		Strs("failed", result.Failed).
+		Msg("Transient environment scan completed")
+}
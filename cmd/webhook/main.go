@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.temporal.io/sdk/client"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/logging"
+	"github.com/imranansari/gh-deploy-wf/webhook"
+)
+
+func main() {
+	// Load configuration, -config and -set take precedence over the environment
+	options := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.LoadWithOptions(*options)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize logger
+	logging.InitLogger(cfg.App.LogLevel, cfg.App.LogFormat)
+	logger := logging.GitHubLogger().With().Str("component", "webhook").Logger()
+
+	if len(cfg.Secrets.WebhookSecret) == 0 {
+		logger.Fatal().Msg("WEBHOOK_SECRET_PATH is required to verify webhook signatures")
+	}
+
+	// Gate policies come from environments.yaml, without it protection rules can't be evaluated
+	environments, err := config.LoadEnvironmentsSpec(cfg.GitHub.EnvironmentsFile)
+	if err != nil {
+		logger.Fatal().
+			Err(err).
+			Str("file", cfg.GitHub.EnvironmentsFile).
+			Msg("Failed to load environments file, deployment gates would approve every request")
+	}
+
+	// Create Temporal client
+	temporalClient, err := client.Dial(client.Options{
+		HostPort:  cfg.Temporal.HostPort,
+		Namespace: cfg.Temporal.Namespace,
+	})
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create Temporal client")
+	}
+	defer temporalClient.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Webhook.Path, webhook.NewHandler(cfg, environments, temporalClient, logger))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Webhook.Port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		logger.Info().
+			Str("addr", server.Addr).
+			Str("path", cfg.Webhook.Path).
+			Msg("Serving GitHub webhooks")
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal().Err(err).Msg("Webhook server failed")
+		}
+	}()
+
+	// Wait for termination signal
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	sig := <-sigChan
+	logger.Info().Str("signal", sig.String()).Msg("Received termination signal")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Error().Err(err).Msg("Webhook server shutdown failed")
+	}
+
+	logger.Info().Msg("Webhook server stopped gracefully")
+}
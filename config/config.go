@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/caarlos0/env/v10"
@@ -22,6 +24,21 @@ type Config struct {
 	// Application Configuration
 	App AppConfig `envPrefix:"APP_"`
 	
+	// GitHub webhook receiver Configuration
+	Webhook WebhookConfig `envPrefix:"WEBHOOK_"`
+	
+	// Deployment protection rule (gate) Configuration
+	Gate GateConfig `envPrefix:"GATE_"`
+	
+	// Transient pull request environment teardown
+	Teardown TeardownConfig `envPrefix:"TEARDOWN_"`
+	
+	// Directory holding the secret files, e.g. the GitHub App private keys
+	SecretsPath string `env:"SECRETS_PATH" envDefault:".private"`
+	
+	// Per-organization, repository and environment overrides (configuration file only)
+	Overrides Overrides
+	
 	// Secrets (loaded from files)
 	Secrets SecretsConfig
 }
@@ -49,7 +66,47 @@ type GitHubConfig struct {
 	// Leave empty to use GitHub.com (temporary - remove when switching to enterprise-only)
 	EnterpriseURL  string          `env:"ENTERPRISE_URL"`
 	
+	// App private key location for the single-host configuration: a file, a
+	// directory of *.pem files or a glob. All matching keys are active, so a
+	// key is rotated by mounting the new file before removing the old one
+	PrivateKeyPath string          `env:"PRIVATE_KEY_PATH"`
+	
+	// How often key locations are re-read to pick up rotated keys (0 disables)
+	KeyReloadInterval time.Duration `env:"KEY_RELOAD_INTERVAL" envDefault:"30s"`
+	
+	// How long installation permissions and repository selection are cached
+	PermissionCacheTTL time.Duration `env:"PERMISSION_CACHE_TTL" envDefault:"10m"`
+	
+	// Declarative environment settings applied by the reconcile workflow
+	EnvironmentsFile string `env:"ENVIRONMENTS_FILE" envDefault:"environments.yaml"`
+	
+	// Keep a sticky deployment status comment on open pull requests
+	PRCommentsEnabled bool `env:"PR_COMMENTS_ENABLED" envDefault:"true"`
+	
+	// Mirror every deployment as a deploy/<environment> check run on the commit
+	CheckRunsEnabled bool `env:"CHECK_RUNS_ENABLED" envDefault:"true"`
+	
+	// Host registry for serving several GitHub instances from one worker
+	// Set GITHUB_HOSTS to a comma-separated list of host names and configure
+	// each one with GITHUB_HOST_<NAME>_* variables (see hosts.go)
+	// Leave empty to use the single host described by APP_ID/ENTERPRISE_URL
+	HostNames      []string        `env:"HOSTS" envSeparator:","`
+	
+	// Resolved host registry (populated by Load)
+	Hosts          []GitHubHostConfig
+	
 	RateLimit      RateLimitConfig `envPrefix:"RATE_LIMIT_"`
+	
+	// Conditional request (ETag/Last-Modified) cache for GET calls
+	Cache          CacheConfig     `envPrefix:"CACHE_"`
+	
+	// Network options for the single-host configuration
+	// Registered hosts use GITHUB_HOST_<NAME>_TRANSPORT_* instead
+	Transport      TransportConfig `envPrefix:"TRANSPORT_"`
+	
+	// App JWT signer for the single-host configuration
+	// Registered hosts use GITHUB_HOST_<NAME>_SIGNER_* instead
+	Signer         SignerConfig    `envPrefix:"SIGNER_"`
 }
 
 type RateLimitConfig struct {
@@ -59,6 +116,32 @@ type RateLimitConfig struct {
 	BackoffMultiplier float64       `env:"BACKOFF_MULTIPLIER" envDefault:"2.0"`
 }
 
+type CacheConfig struct {
+	Enabled    bool  `env:"ENABLED" envDefault:"true"`
+	MaxEntries int   `env:"MAX_ENTRIES" envDefault:"1000"`
+	MaxBytes   int64 `env:"MAX_BYTES" envDefault:"16777216"` // 16 MiB of cached bodies
+}
+
+// TransportConfig holds network options for reaching a GitHub instance
+// Certificates and keys are read through the secrets package
+type TransportConfig struct {
+	// PEM files with additional trusted CAs, appended to the system pool
+	CABundleFiles []string `env:"CA_BUNDLE_FILES" envSeparator:","`
+	
+	// Proxy for API requests, defaults to HTTPS_PROXY/HTTP_PROXY from the environment
+	ProxyURL string `env:"PROXY_URL"`
+	// Comma-separated hosts, domains and CIDRs that bypass ProxyURL
+	NoProxy  string `env:"NO_PROXY"`
+	
+	// Client certificate and key for mutual TLS
+	ClientCertPath string `env:"CLIENT_CERT_PATH"`
+	ClientKeyPath  string `env:"CLIENT_KEY_PATH"`
+	
+	RequestTimeout      time.Duration `env:"REQUEST_TIMEOUT" envDefault:"30s"`
+	DialTimeout         time.Duration `env:"DIAL_TIMEOUT" envDefault:"10s"`
+	TLSHandshakeTimeout time.Duration `env:"TLS_HANDSHAKE_TIMEOUT" envDefault:"10s"`
+}
+
 type AppConfig struct {
 	Environment    string `env:"ENVIRONMENT" envDefault:"development"`
 	LogLevel       string `env:"LOG_LEVEL" envDefault:"info"`
@@ -68,23 +151,140 @@ type AppConfig struct {
 	HealthPort     int    `env:"HEALTH_PORT" envDefault:"8080"`
 }
 
+// WebhookConfig configures the GitHub webhook receiver (cmd/webhook)
+type WebhookConfig struct {
+	Port int    `env:"PORT" envDefault:"8081"`
+	Path string `env:"PATH" envDefault:"/webhook"`
+	
+	// File holding the webhook secret used to verify X-Hub-Signature-256
+	SecretPath string `env:"SECRET_PATH"`
+}
+
+// GateConfig holds inputs to the deployment protection rule policy shared by all environments
+// Per-environment rules live in the gate section of environments.yaml
+type GateConfig struct {
+	// An existing file at this path means an incident is open
+	IncidentFlagPath string `env:"INCIDENT_FLAG_PATH"`
+}
+
+// TeardownConfig controls the cleanup of transient pull request deployments once the PR closes
+type TeardownConfig struct {
+	// Delete the deployments after marking them inactive
+	DeleteDeployments bool `env:"DELETE_DEPLOYMENTS" envDefault:"false"`
+	
+	// Delete the pr-<n> GitHub environment (needs administration: write)
+	DeleteEnvironments bool `env:"DELETE_ENVIRONMENTS" envDefault:"true"`
+	
+	// CloudEvents endpoint notified after a teardown, empty disables the event
+	EventSinkURL string        `env:"EVENT_SINK_URL"`
+	EventSource  string        `env:"EVENT_SOURCE" envDefault:"gh-deploy-wf"`
+	EventTimeout time.Duration `env:"EVENT_TIMEOUT" envDefault:"10s"`
+	
+	// Temporal cron schedule of the periodic scan for closed pull requests (cmd/transient-scan)
+	ScanSchedule string `env:"SCAN_SCHEDULE" envDefault:"@hourly"`
+}
+
 type SecretsConfig struct {
 	GitHubPrivateKey []byte
+	
+	// GitHub App private keys by host name, then by file path
+	GitHubHostKeys map[string]map[string][]byte
+	
+	// CA bundles and client certificates by host name
+	GitHubHostTLS map[string]TLSMaterial
+	
+	// Signing sidecar bearer tokens by host name
+	GitHubSignerTokens map[string][]byte
+	
+	// Webhook secret shared with the GitHub App
+	WebhookSecret []byte
+}
+
+// JWT signer types
+const (
+	// SignerPEM signs with private key files loaded into the worker (default)
+	SignerPEM = "pem"
+	// SignerSidecar signs through an HTTP signing sidecar holding the key
+	SignerSidecar = "sidecar"
+)
+
+// SignerConfig selects how GitHub App JWTs are signed
+// Only the pem signer reads the App private key into process memory
+type SignerConfig struct {
+	Type string `env:"TYPE" envDefault:"pem"`
+	
+	// Sidecar base URL, the worker POSTs to <url>/v1/sign
+	URL string `env:"URL"`
+	// Optional bearer token file for the sidecar
+	TokenPath string `env:"TOKEN_PATH"`
+	// Key identifier logged with each signature (the sidecar may report its own)
+	KeyID string `env:"KEY_ID"`
+	
+	Timeout time.Duration `env:"TIMEOUT" envDefault:"5s"`
+}
+
+// UsesPrivateKey reports whether the signer needs the App private key files
+func (s SignerConfig) UsesPrivateKey() bool {
+	return s.Type == "" || s.Type == SignerPEM
+}
+
+// TLSMaterial holds the certificate files referenced by a TransportConfig
+type TLSMaterial struct {
+	CABundles  [][]byte
+	ClientCert []byte
+	ClientKey  []byte
 }
 
 // Load loads configuration from environment variables and files
+// The configuration file is read from CONFIG_FILE when it is set
 func Load() (*Config, error) {
+	return LoadWithOptions(LoadOptions{})
+}
+
+// LoadWithOptions loads configuration from the defaults, the configuration file,
+// environment variables and command-line settings, later ones taking precedence
+func LoadWithOptions(options LoadOptions) (*Config, error) {
 	// Load .env file if exists (for local development)
 	if err := godotenv.Load(); err != nil {
 		// Ignore error, use environment variables if no .env file
 	}
 
-	cfg := &Config{}
+	environment := env.ToMap(os.Environ())
+	
+	// Optional configuration file below the environment variables
+	configFile := options.File
+	if configFile == "" {
+		configFile = environment[ConfigFileEnv]
+	}
+	file := &ConfigFile{}
+	if configFile != "" {
+		var err error
+		if file, err = LoadConfigFile(configFile); err != nil {
+			return nil, err
+		}
+	}
+	
+	// Command-line settings above the environment variables
+	flags, err := flagSettings(options.Settings)
+	if err != nil {
+		return nil, fmt.Errorf("invalid command-line settings: %w", err)
+	}
+	environment = layeredEnvironment(file.Settings, environment, flags)
+	
+	cfg := &Config{Overrides: file.Overrides}
 	
-	// Parse environment variables using caarlos0/env
-	if err := env.Parse(cfg); err != nil {
+	// Parse environment variables using caarlos0/env, defaults fill in what no layer sets
+	if err := env.ParseWithOptions(cfg, env.Options{Environment: environment}); err != nil {
 		return nil, fmt.Errorf("failed to parse environment variables: %w", err)
 	}
+	if err := checkHostSettings(cfg.GitHub.HostNames, file.Settings, flags); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	
+	// Resolve the GitHub host registry
+	if err := loadHosts(cfg, environment); err != nil {
+		return nil, fmt.Errorf("failed to load GitHub hosts: %w", err)
+	}
 	
 	// Load secrets from files
 	if err := loadSecrets(cfg); err != nil {
@@ -102,34 +302,159 @@ func Load() (*Config, error) {
 // loadSecrets loads secrets from files
 func loadSecrets(cfg *Config) error {
 	// Get secrets base path
-	secretsPath := getEnv("SECRETS_PATH", ".private")
+	secretsPath := cfg.SecretsPath
 	
-	// GitHub App private key (streamcommander)
-	privateKeyPath := fmt.Sprintf("%s/streamcommander.2025-07-12.private-key.pem", secretsPath)
+	cfg.Secrets.GitHubHostKeys = make(map[string]map[string][]byte)
+	cfg.Secrets.GitHubHostTLS = make(map[string]TLSMaterial)
+	cfg.Secrets.GitHubSignerTokens = make(map[string][]byte)
 	
-	privateKey, err := secrets.LoadFromFile(privateKeyPath)
-	if err != nil {
-		return fmt.Errorf("failed to load GitHub App private key: %w", err)
+	// Network certificates for every host
+	for _, host := range cfg.GitHub.Hosts {
+		material, err := loadTLSMaterial(host.Transport)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS material for GitHub host %s: %w", host.Name, err)
+		}
+		cfg.Secrets.GitHubHostTLS[host.Name] = material
+	}
+	
+	// GitHub App private keys: every key matched by the host's key location is
+	// loaded so keys can be rotated by adding a file next to the old one
+	for i := range cfg.GitHub.Hosts {
+		host := &cfg.GitHub.Hosts[i]
+		
+		// Remote signers keep the key out of the worker
+		if !host.Signer.UsesPrivateKey() {
+			if host.Signer.TokenPath != "" {
+				token, err := secrets.LoadFromFile(host.Signer.TokenPath)
+				if err != nil {
+					return fmt.Errorf("failed to load signer token for GitHub host %s: %w", host.Name, err)
+				}
+				cfg.Secrets.GitHubSignerTokens[host.Name] = token
+			}
+			continue
+		}
+		
+		if host.PrivateKeyPath == "" {
+			host.PrivateKeyPath = defaultPrivateKeyPath(cfg, host.Name, secretsPath)
+		}
+		
+		keys, err := secrets.LoadFromGlob(host.PrivateKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load GitHub App private key for host %s: %w", host.Name, err)
+		}
+		cfg.Secrets.GitHubHostKeys[host.Name] = keys
+	}
+	
+	// Webhook secret, only needed by the webhook receiver
+	if cfg.Webhook.SecretPath != "" {
+		secret, err := secrets.LoadFromFile(cfg.Webhook.SecretPath)
+		if err != nil {
+			return fmt.Errorf("failed to load webhook secret: %w", err)
+		}
+		cfg.Secrets.WebhookSecret = []byte(strings.TrimSpace(string(secret)))
+	}
+	
+	// Legacy single-host key (newest matching file)
+	if keys := cfg.Secrets.GitHubHostKeys[DefaultGitHubHost]; len(keys) > 0 {
+		paths := make([]string, 0, len(keys))
+		for path := range keys {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		cfg.Secrets.GitHubPrivateKey = keys[paths[len(paths)-1]]
 	}
-	cfg.Secrets.GitHubPrivateKey = privateKey
 	
 	return nil
 }
 
-// Helper function for simple environment variable parsing
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// defaultPrivateKeyPath returns the key location used when none is configured
+func defaultPrivateKeyPath(cfg *Config, hostName, secretsPath string) string {
+	if len(cfg.GitHub.HostNames) > 0 {
+		return fmt.Sprintf("%s/%s.private-key.pem", secretsPath, hostName)
+	}
+	if cfg.GitHub.PrivateKeyPath != "" {
+		return cfg.GitHub.PrivateKeyPath
+	}
+	// GitHub names downloaded keys <app-slug>.<date>.private-key.pem (streamcommander)
+	return fmt.Sprintf("%s/streamcommander.*.private-key.pem", secretsPath)
+}
+
+// loadTLSMaterial reads the CA bundles and client certificate of a transport
+func loadTLSMaterial(transport TransportConfig) (TLSMaterial, error) {
+	var material TLSMaterial
+	
+	for _, path := range transport.CABundleFiles {
+		bundle, err := secrets.LoadFromFile(path)
+		if err != nil {
+			return material, fmt.Errorf("failed to load CA bundle: %w", err)
+		}
+		material.CABundles = append(material.CABundles, bundle)
+	}
+	
+	if transport.ClientCertPath == "" && transport.ClientKeyPath == "" {
+		return material, nil
+	}
+	if transport.ClientCertPath == "" || transport.ClientKeyPath == "" {
+		return material, fmt.Errorf("client certificate and key paths must be set together")
+	}
+	
+	cert, err := secrets.LoadFromFile(transport.ClientCertPath)
+	if err != nil {
+		return material, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+	key, err := secrets.LoadFromFile(transport.ClientKeyPath)
+	if err != nil {
+		return material, fmt.Errorf("failed to load client key: %w", err)
 	}
-	return defaultValue
+	material.ClientCert = cert
+	material.ClientKey = key
+	
+	return material, nil
 }
 
 func validateConfig(cfg *Config) error {
+	if err := validateCache(cfg.GitHub.Cache); err != nil {
+		return err
+	}
+	if len(cfg.GitHub.HostNames) > 0 {
+		return validateHosts(cfg)
+	}
 	if cfg.GitHub.AppID == 0 {
 		return fmt.Errorf("GitHub App ID is required")
 	}
-	if len(cfg.Secrets.GitHubPrivateKey) == 0 {
+	if err := validateSigner(cfg.GitHub.Signer); err != nil {
+		return err
+	}
+	if cfg.GitHub.Signer.UsesPrivateKey() && len(cfg.Secrets.GitHubPrivateKey) == 0 {
 		return fmt.Errorf("GitHub App private key is required")
 	}
 	return nil
+}
+
+// validateCache rejects bounds that would evict every response as soon as it is stored
+func validateCache(cache CacheConfig) error {
+	if !cache.Enabled {
+		return nil
+	}
+	if cache.MaxEntries <= 0 {
+		return fmt.Errorf("GITHUB_CACHE_MAX_ENTRIES must be greater than 0, set GITHUB_CACHE_ENABLED=false to disable the cache")
+	}
+	if cache.MaxBytes <= 0 {
+		return fmt.Errorf("GITHUB_CACHE_MAX_BYTES must be greater than 0, set GITHUB_CACHE_ENABLED=false to disable the cache")
+	}
+	return nil
+}
+
+func validateSigner(signer SignerConfig) error {
+	switch signer.Type {
+	case "", SignerPEM:
+		return nil
+	case SignerSidecar:
+		if signer.URL == "" {
+			return fmt.Errorf("signer URL is required for the sidecar signer")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown signer type %q (expected %s or %s)", signer.Type, SignerPEM, SignerSidecar)
+	}
 }
\ No newline at end of file
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSigner(t *testing.T) {
+	assert.NoError(t, validateSigner(SignerConfig{}))
+	assert.NoError(t, validateSigner(SignerConfig{Type: SignerPEM}))
+	assert.NoError(t, validateSigner(SignerConfig{Type: SignerSidecar, URL: "http://localhost:8200"}))
+
+	assert.Error(t, validateSigner(SignerConfig{Type: SignerSidecar}))
+	assert.Error(t, validateSigner(SignerConfig{Type: "kms"}))
+	assert.Error(t, validateSigner(SignerConfig{Type: "external"}))
+}
+
+func TestValidateCache(t *testing.T) {
+	assert.NoError(t, validateCache(CacheConfig{Enabled: true, MaxEntries: 1000, MaxBytes: 1 << 24}))
+	assert.NoError(t, validateCache(CacheConfig{Enabled: false}))
+
+	assert.ErrorContains(t, validateCache(CacheConfig{Enabled: true, MaxBytes: 1 << 24}), "GITHUB_CACHE_MAX_ENTRIES")
+	assert.ErrorContains(t, validateCache(CacheConfig{Enabled: true, MaxEntries: 1000, MaxBytes: -1}), "GITHUB_CACHE_MAX_BYTES")
+}
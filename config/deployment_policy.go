@@ -0,0 +1,263 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// DeploymentPolicy sets how deployments to matching environments are created and reported
+//
+//	deployment_policies:
+//	  - environment: production
+//	    required_contexts: [ci/build]
+//	    description: Production rollout
+//	    status_descriptions:
+//	      success: Live in production
+//	    retry:
+//	      max_attempts: 5
+//	      max_interval: 1m
+//	  - environment: "pr-*"
+//	    transient: true
+//	    urls:
+//	      environment_url: "https://{{.Environment}}.{{.Repo}}.preview.example.com"
+type DeploymentPolicy struct {
+	// Environment name or path.Match pattern, e.g. pr-*
+	Environment string `yaml:"environment" json:"environment"`
+
+	// Marks deployments as production, defaults to the production environment only
+	Production *bool `yaml:"production,omitempty" json:"production,omitempty"`
+
+	// Marks every deployment transient (true) or persistent (false), unset lets the workflow input decide
+	Transient *bool `yaml:"transient,omitempty" json:"transient,omitempty"`
+
+	// Commit status contexts GitHub requires to pass before creating the deployment, empty skips the check
+	RequiredContexts []string `yaml:"required_contexts,omitempty" json:"required_contexts,omitempty"`
+
+	// Merge the default branch into the ref before deploying, defaults to false
+	AutoMerge *bool `yaml:"auto_merge,omitempty" json:"auto_merge,omitempty"`
+
+	// Mark earlier deployments inactive on success, defaults to true; auxiliary tasks never do
+	AutoInactive *bool `yaml:"auto_inactive,omitempty" json:"auto_inactive,omitempty"`
+
+	// Deployment description when the workflow input has none
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+
+	// Status descriptions by state, replacing the ones the workflows generate, e.g. success: Live
+	StatusDescriptions map[string]string `yaml:"status_descriptions,omitempty" json:"status_descriptions,omitempty"`
+
+	// Activity retries of the deployment workflows, nil keeps the built-in settings
+	Retry *RetrySettings `yaml:"retry,omitempty" json:"retry,omitempty"`
+
+	// Log and environment URL templates used when the event sends no URLs
+	URLs *URLTemplates `yaml:"urls,omitempty" json:"urls,omitempty"`
+}
+
+// RetrySettings override the retry policy of the deployment workflows' GitHub calls, zero keeps a setting
+type RetrySettings struct {
+	MaxAttempts        int32         `yaml:"max_attempts,omitempty" json:"max_attempts,omitempty"`
+	InitialInterval    time.Duration `yaml:"initial_interval,omitempty" json:"initial_interval,omitempty"`
+	MaxInterval        time.Duration `yaml:"max_interval,omitempty" json:"max_interval,omitempty"`
+	BackoffCoefficient float64       `yaml:"backoff_coefficient,omitempty" json:"backoff_coefficient,omitempty"`
+}
+
+// Deployment states a status description can be set for
+var deploymentStates = map[string]bool{
+	"pending": true, "queued": true, "in_progress": true, "success": true,
+	"failure": true, "error": true, "inactive": true,
+}
+
+// IsProduction reports whether deployments to the environment are production deployments
+func (p *DeploymentPolicy) IsProduction(environment string) bool {
+	if p != nil && p.Production != nil {
+		return *p.Production
+	}
+	return environment == EnvironmentProduction
+}
+
+// IsTransient reports whether a deployment is transient, requested is the workflow input's flag
+func (p *DeploymentPolicy) IsTransient(requested bool) bool {
+	if p != nil && p.Transient != nil {
+		return *p.Transient
+	}
+	return requested
+}
+
+// RequiredContextsOrNone returns the required contexts, an empty list skips GitHub's status check
+func (p *DeploymentPolicy) RequiredContextsOrNone() []string {
+	if p == nil || len(p.RequiredContexts) == 0 {
+		return []string{}
+	}
+	return p.RequiredContexts
+}
+
+// AutoMergeOrDefault reports whether GitHub merges the default branch into the ref, off by default
+func (p *DeploymentPolicy) AutoMergeOrDefault() bool {
+	return p != nil && p.AutoMerge != nil && *p.AutoMerge
+}
+
+// AutoInactiveOrDefault reports whether a successful application deployment deactivates earlier ones
+func (p *DeploymentPolicy) AutoInactiveOrDefault() bool {
+	if p != nil && p.AutoInactive != nil {
+		return *p.AutoInactive
+	}
+	return true
+}
+
+// DescriptionOr returns description, the policy's description when it is empty
+func (p *DeploymentPolicy) DescriptionOr(description string) string {
+	if description == "" && p != nil {
+		return p.Description
+	}
+	return description
+}
+
+// StatusDescription returns the policy's description for the state, generated when it has none
+func (p *DeploymentPolicy) StatusDescription(state, generated string) string {
+	if p != nil {
+		if description := p.StatusDescriptions[state]; description != "" {
+			return description
+		}
+	}
+	return generated
+}
+
+func (p *DeploymentPolicy) validate() error {
+	if p.Environment == "" {
+		return fmt.Errorf("every deployment_policies entry needs an environment")
+	}
+	if _, err := path.Match(p.Environment, ""); err != nil {
+		return fmt.Errorf("deployment policy %s: invalid environment pattern: %w", p.Environment, err)
+	}
+	if err := p.validateSettings(); err != nil {
+		return fmt.Errorf("deployment policy %s: %w", p.Environment, err)
+	}
+	return nil
+}
+
+// validateSettings checks everything but the environment, shared with the configuration file's overrides
+func (p *DeploymentPolicy) validateSettings() error {
+	for _, required := range p.RequiredContexts {
+		if required == "" {
+			return fmt.Errorf("required_contexts entries must not be empty")
+		}
+	}
+	for state := range p.StatusDescriptions {
+		if !deploymentStates[state] {
+			return fmt.Errorf("status_descriptions has unknown state %q", state)
+		}
+	}
+	if p.URLs != nil {
+		if err := p.URLs.validate(); err != nil {
+			return err
+		}
+	}
+	if retry := p.Retry; retry != nil {
+		if retry.MaxAttempts < 0 || retry.InitialInterval < 0 || retry.MaxInterval < 0 {
+			return fmt.Errorf("retry settings must not be negative")
+		}
+		if retry.BackoffCoefficient != 0 && retry.BackoffCoefficient < 1 {
+			return fmt.Errorf("retry backoff_coefficient must be at least 1")
+		}
+		if retry.InitialInterval > 0 && retry.MaxInterval > 0 && retry.MaxInterval < retry.InitialInterval {
+			return fmt.Errorf("retry max_interval must not be below initial_interval")
+		}
+	}
+	return nil
+}
+
+// merge applies the settings an override sets on top of the policy
+// Every setting the override sets replaces the policy's, status descriptions and retry settings key by key
+func (p *DeploymentPolicy) merge(override *DeploymentPolicy) {
+	if override.Production != nil {
+		p.Production = override.Production
+	}
+	if override.Transient != nil {
+		p.Transient = override.Transient
+	}
+	if override.RequiredContexts != nil {
+		p.RequiredContexts = override.RequiredContexts
+	}
+	if override.AutoMerge != nil {
+		p.AutoMerge = override.AutoMerge
+	}
+	if override.AutoInactive != nil {
+		p.AutoInactive = override.AutoInactive
+	}
+	if override.Description != "" {
+		p.Description = override.Description
+	}
+	if len(override.StatusDescriptions) > 0 {
+		descriptions := make(map[string]string, len(p.StatusDescriptions)+len(override.StatusDescriptions))
+		for state, description := range p.StatusDescriptions {
+			descriptions[state] = description
+		}
+		for state, description := range override.StatusDescriptions {
+			descriptions[state] = description
+		}
+		p.StatusDescriptions = descriptions
+	}
+	if override.Retry != nil {
+		retry := RetrySettings{}
+		if p.Retry != nil {
+			retry = *p.Retry
+		}
+		if override.Retry.MaxAttempts > 0 {
+			retry.MaxAttempts = override.Retry.MaxAttempts
+		}
+		if override.Retry.InitialInterval > 0 {
+			retry.InitialInterval = override.Retry.InitialInterval
+		}
+		if override.Retry.MaxInterval > 0 {
+			retry.MaxInterval = override.Retry.MaxInterval
+		}
+		if override.Retry.BackoffCoefficient > 0 {
+			retry.BackoffCoefficient = override.Retry.BackoffCoefficient
+		}
+		p.Retry = &retry
+	}
+	if override.URLs != nil {
+		urls := URLTemplates{}
+		if p.URLs != nil {
+			urls = *p.URLs
+		}
+		urls.merge(*override.URLs)
+		p.URLs = &urls
+	}
+}
+
+// DeploymentPolicyFor returns the policy of a repository's environment with the configuration
+// file's overrides applied, nil when neither environments.yaml nor an override sets one
+func (s *EnvironmentsSpec) DeploymentPolicyFor(host, owner, repo, environment string) *DeploymentPolicy {
+	base := s.DeploymentPolicy(environment)
+	overrides := s.overrides.Matching(host, owner, repo, environment)
+	if len(overrides) == 0 {
+		return base
+	}
+
+	policy := &DeploymentPolicy{}
+	if base != nil {
+		*policy = *base
+	}
+	for i := range overrides {
+		policy.merge(&overrides[i].DeploymentPolicy)
+	}
+	policy.Environment = environment
+	return policy
+}
+
+// DeploymentPolicy returns the policy of an environment, nil when none matches
+// An entry naming the environment wins over patterns, patterns match in file order
+func (s *EnvironmentsSpec) DeploymentPolicy(environment string) *DeploymentPolicy {
+	for i := range s.DeploymentPolicies {
+		if s.DeploymentPolicies[i].Environment == environment {
+			return &s.DeploymentPolicies[i]
+		}
+	}
+	for i := range s.DeploymentPolicies {
+		if matched, _ := path.Match(s.DeploymentPolicies[i].Environment, environment); matched {
+			return &s.DeploymentPolicies[i]
+		}
+	}
+	return nil
+}
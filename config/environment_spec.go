@@ -0,0 +1,604 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limits enforced by the GitHub Environments API
+const (
+	MaxEnvironmentWaitTimer = 43200 // minutes (30 days)
+	MaxEnvironmentReviewers = 6
+)
+
+// EnvironmentsSpec is the declarative GitHub environment settings in environments.yaml
+// The reconcile workflow applies every environment to every listed repository
+//
+//	repositories:
+//	  - owner: acme
+//	    repos: [api, web]
+//	environments:
+//	  - name: production
+//	    wait_timer: 10
+//	    reviewers:
+//	      teams: [release-managers]
+//	    branch_policy:
+//	      branches: [main, "release/*"]
+type EnvironmentsSpec struct {
+	Repositories []RepositoryTarget `yaml:"repositories" json:"repositories"`
+	Environments []EnvironmentSpec  `yaml:"environments" json:"environments"`
+
+	// How deployments are created per environment or environment pattern, see DeploymentPolicy
+	DeploymentPolicies []DeploymentPolicy `yaml:"deployment_policies,omitempty" json:"deployment_policies,omitempty"`
+
+	// Per-organization, repository and environment overrides from the configuration file
+	overrides Overrides
+}
+
+// WithOverrides returns the spec with the configuration file's overrides applied on top of its
+// deployment policies and URL templates, an empty spec when s is nil
+func (s *EnvironmentsSpec) WithOverrides(overrides Overrides) *EnvironmentsSpec {
+	spec := &EnvironmentsSpec{}
+	if s != nil {
+		*spec = *s
+	}
+	spec.overrides = overrides
+	return spec
+}
+
+// RepositoryTarget selects repositories of one owner to reconcile
+type RepositoryTarget struct {
+	// Registered host name, empty routes by owner
+	GithubHost string   `yaml:"github_host,omitempty" json:"github_host,omitempty"`
+	Owner      string   `yaml:"owner" json:"owner"`
+	Repos      []string `yaml:"repos" json:"repos"`
+
+	// Environments limits the environments applied to these repositories, empty applies all
+	Environments []string `yaml:"environments,omitempty" json:"environments,omitempty"`
+
+	// Tag and release every successful deployment to an environment, nil disables releases
+	Release *ReleasePolicy `yaml:"release,omitempty" json:"release,omitempty"`
+
+	// Log and environment URL templates of these repositories, taking precedence over the deployment policy's
+	URLs *URLTemplates `yaml:"urls,omitempty" json:"urls,omitempty"`
+}
+
+// onHost reports whether the entry applies to the resolved host name, an entry without github_host routes by owner
+func (t *RepositoryTarget) onHost(host string) bool {
+	return t.GithubHost == "" || t.GithubHost == host
+}
+
+// DefaultReleaseTagTemplate names release tags when the policy sets none, e.g. production-2026.10.18-1234
+const DefaultReleaseTagTemplate = "{{.Environment}}-{{.Date}}-{{.DeploymentID}}"
+
+// ReleasePolicy creates an annotated tag and a GitHub Release on the deployed commit
+//
+//	release:
+//	  environment: production
+//	  tag_template: "v{{.Date}}.{{.DeploymentID}}"
+type ReleasePolicy struct {
+	// Environment whose successful deployments are released, defaults to production
+	Environment string `yaml:"environment,omitempty" json:"environment,omitempty"`
+
+	// text/template for the tag name with .Environment, .DeploymentID, .SHA, .ShortSHA and .Date (YYYY.MM.DD)
+	TagTemplate string `yaml:"tag_template,omitempty" json:"tag_template,omitempty"`
+
+	// Only create the tag, no GitHub Release
+	TagOnly bool `yaml:"tag_only,omitempty" json:"tag_only,omitempty"`
+
+	Draft      bool `yaml:"draft,omitempty" json:"draft,omitempty"`
+	Prerelease bool `yaml:"prerelease,omitempty" json:"prerelease,omitempty"`
+}
+
+// ReleaseEnvironment returns the environment the policy releases
+func (p *ReleasePolicy) ReleaseEnvironment() string {
+	if p.Environment == "" {
+		return EnvironmentProduction
+	}
+	return p.Environment
+}
+
+// TagTemplateOrDefault returns the tag template, DefaultReleaseTagTemplate when unset
+func (p *ReleasePolicy) TagTemplateOrDefault() string {
+	if p.TagTemplate == "" {
+		return DefaultReleaseTagTemplate
+	}
+	return p.TagTemplate
+}
+
+func (p *ReleasePolicy) validate() error {
+	if !IsValidEnvironment(p.ReleaseEnvironment()) {
+		return fmt.Errorf("release environment %q is unknown (valid: %v)", p.Environment, ValidEnvironments())
+	}
+	if _, err := template.New("tag").Option("missingkey=error").Parse(p.TagTemplateOrDefault()); err != nil {
+		return fmt.Errorf("release tag_template: %w", err)
+	}
+	return nil
+}
+
+// EnvironmentSpec is the desired protection configuration of one GitHub environment
+type EnvironmentSpec struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Minutes to wait before a deployment can proceed
+	WaitTimer int `yaml:"wait_timer,omitempty" json:"wait_timer,omitempty"`
+
+	// Required reviewers by user login and team slug (the team must belong to the owner)
+	Reviewers         ReviewersSpec `yaml:"reviewers,omitempty" json:"reviewers,omitempty"`
+	PreventSelfReview bool          `yaml:"prevent_self_review,omitempty" json:"prevent_self_review,omitempty"`
+
+	// Whether administrators can bypass the protection rules, GitHub defaults to true
+	CanAdminsBypass *bool `yaml:"can_admins_bypass,omitempty" json:"can_admins_bypass,omitempty"`
+
+	// Which branches and tags may deploy, nil allows any ref
+	BranchPolicy *BranchPolicySpec `yaml:"branch_policy,omitempty" json:"branch_policy,omitempty"`
+
+	// GitHub App IDs enabled as custom deployment protection rules
+	ProtectionRuleApps []int64 `yaml:"protection_rule_apps,omitempty" json:"protection_rule_apps,omitempty"`
+
+	// Policy this App enforces when it is a custom protection rule on the environment
+	Gate *GatePolicy `yaml:"gate,omitempty" json:"gate,omitempty"`
+
+	// HTTP checks against the environment URL before a deployment is marked successful
+	Verification *VerificationPolicy `yaml:"verification,omitempty" json:"verification,omitempty"`
+}
+
+// Verification defaults and limits
+const (
+	DefaultVerificationTimeout  = 5 * time.Minute
+	DefaultVerificationInterval = 10 * time.Second
+	MaxVerificationTimeout      = 30 * time.Minute
+	MaxVerificationInterval     = 5 * time.Minute
+)
+
+// VerificationPolicy polls the environment URL until every check passes or the timeout expires
+//
+//	verification:
+//	  timeout: 5m
+//	  checks:
+//	    - path: /healthz
+//	    - path: /version
+//	      json_field: commit
+//	      expect_sha: true
+//
+// In workflow input timeout and interval are duration strings such as "5m", or nanoseconds
+type VerificationPolicy struct {
+	// How long to keep retrying, defaults to DefaultVerificationTimeout
+	Timeout time.Duration `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// Pause between rounds of checks, defaults to DefaultVerificationInterval, at most MaxVerificationInterval
+	Interval time.Duration `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	Checks []HTTPCheck `yaml:"checks" json:"checks"`
+}
+
+// HTTPCheck is one GET request against the environment URL
+type HTTPCheck struct {
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	// Path appended to the environment URL, empty requests the URL itself
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Accepted status codes, defaults to any 2xx
+	ExpectStatus []int `yaml:"expect_status,omitempty" json:"expect_status,omitempty"`
+
+	// Dotted path into a JSON body, e.g. build.commit, empty checks the raw body
+	JSONField string `yaml:"json_field,omitempty" json:"json_field,omitempty"`
+
+	// The field (or body) must equal this value
+	Equals string `yaml:"equals,omitempty" json:"equals,omitempty"`
+
+	// The field (or body) must report the deployed commit, short SHAs of 7+ characters match
+	ExpectSHA bool `yaml:"expect_sha,omitempty" json:"expect_sha,omitempty"`
+}
+
+// UnmarshalJSON accepts timeout and interval as duration strings, e.g. "5m", or as nanoseconds
+func (p *VerificationPolicy) UnmarshalJSON(data []byte) error {
+	type plain VerificationPolicy
+	var policy struct {
+		plain
+		Timeout  json.RawMessage `json:"timeout,omitempty"`
+		Interval json.RawMessage `json:"interval,omitempty"`
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return err
+	}
+
+	var err error
+	if policy.plain.Timeout, err = jsonDuration(policy.Timeout); err != nil {
+		return fmt.Errorf("verification timeout: %w", err)
+	}
+	if policy.plain.Interval, err = jsonDuration(policy.Interval); err != nil {
+		return fmt.Errorf("verification interval: %w", err)
+	}
+	*p = VerificationPolicy(policy.plain)
+	return nil
+}
+
+// jsonDuration decodes a duration string or a number of nanoseconds, empty is zero
+func jsonDuration(data json.RawMessage) (time.Duration, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return 0, nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return time.ParseDuration(text)
+	}
+	var nanoseconds int64
+	if err := json.Unmarshal(data, &nanoseconds); err != nil {
+		return 0, fmt.Errorf("expected a duration such as \"5m\", got %s", data)
+	}
+	return time.Duration(nanoseconds), nil
+}
+
+// TimeoutOrDefault returns the verification deadline
+func (p *VerificationPolicy) TimeoutOrDefault() time.Duration {
+	if p.Timeout <= 0 {
+		return DefaultVerificationTimeout
+	}
+	return p.Timeout
+}
+
+// IntervalOrDefault returns the pause between rounds of checks
+func (p *VerificationPolicy) IntervalOrDefault() time.Duration {
+	if p.Interval <= 0 {
+		return DefaultVerificationInterval
+	}
+	return p.Interval
+}
+
+// Validate checks the policy limits and that every check asserts something sensible
+func (p *VerificationPolicy) Validate() error {
+	if p.Timeout > MaxVerificationTimeout {
+		return fmt.Errorf("verification timeout must be at most %s", MaxVerificationTimeout)
+	}
+	if p.Interval > MaxVerificationInterval {
+		return fmt.Errorf("verification interval must be at most %s", MaxVerificationInterval)
+	}
+	if len(p.Checks) == 0 {
+		return fmt.Errorf("verification needs at least one check")
+	}
+	for i, check := range p.Checks {
+		if check.Path != "" && !strings.HasPrefix(check.Path, "/") {
+			return fmt.Errorf("verification check %d: path %q must start with /", i+1, check.Path)
+		}
+		if check.ExpectSHA && check.Equals != "" {
+			return fmt.Errorf("verification check %d: equals and expect_sha are mutually exclusive", i+1)
+		}
+		for _, code := range check.ExpectStatus {
+			if code < 100 || code > 599 {
+				return fmt.Errorf("verification check %d: invalid status code %d", i+1, code)
+			}
+		}
+	}
+	return nil
+}
+
+// GatePolicy decides whether a pending deployment_protection_rule is approved
+type GatePolicy struct {
+	// Deployments are rejected while any window is active
+	FreezeWindows []FreezeWindow `yaml:"freeze_windows,omitempty" json:"freeze_windows,omitempty"`
+
+	// Environments that must have a successful deployment of the same commit
+	RequireSuccessIn []string `yaml:"require_success_in,omitempty" json:"require_success_in,omitempty"`
+
+	// Reject while the incident flag (GATE_INCIDENT_FLAG_PATH) is raised
+	BlockOnIncident bool `yaml:"block_on_incident,omitempty" json:"block_on_incident,omitempty"`
+
+	// Ref patterns allowed to deploy, e.g. main or release/*, empty allows any ref
+	AllowedBranches []string `yaml:"allowed_branches,omitempty" json:"allowed_branches,omitempty"`
+}
+
+// FreezeWindow is either a one-off period (start/end) or a weekly recurring one (days/from/to)
+type FreezeWindow struct {
+	Name string `yaml:"name" json:"name"`
+
+	// One-off window, RFC 3339 timestamps
+	Start time.Time `yaml:"start,omitempty" json:"start,omitempty"`
+	End   time.Time `yaml:"end,omitempty" json:"end,omitempty"`
+
+	// Weekly window, e.g. days [fri, sat, sun] from "18:00" to "23:59" in Europe/Berlin
+	// A window from "22:00" to "06:00" starts on the listed days and ends the next morning
+	Days     []string `yaml:"days,omitempty" json:"days,omitempty"`
+	From     string   `yaml:"from,omitempty" json:"from,omitempty"`
+	To       string   `yaml:"to,omitempty" json:"to,omitempty"`
+	Timezone string   `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+}
+
+// Recurring reports whether the window repeats weekly
+func (w FreezeWindow) Recurring() bool {
+	return len(w.Days) > 0
+}
+
+// Active reports whether the window covers t
+func (w FreezeWindow) Active(t time.Time) (bool, error) {
+	if !w.Recurring() {
+		return !t.Before(w.Start) && t.Before(w.End), nil
+	}
+
+	location := time.UTC
+	if w.Timezone != "" {
+		loaded, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false, fmt.Errorf("freeze window %s: %w", w.Name, err)
+		}
+		location = loaded
+	}
+	local := t.In(location)
+
+	from, to, err := w.clockRange()
+	if err != nil {
+		return false, err
+	}
+	clock := local.Hour()*60 + local.Minute()
+
+	if from < to {
+		return w.onDay(local.Weekday()) && clock >= from && clock < to, nil
+	}
+	// Crossing midnight: the evening of a listed day or the morning after it
+	return (w.onDay(local.Weekday()) && clock >= from) ||
+		(w.onDay((local.Weekday()+6)%7) && clock < to), nil
+}
+
+// onDay reports whether the window is listed for the weekday
+func (w FreezeWindow) onDay(weekday time.Weekday) bool {
+	day := strings.ToLower(weekday.String()[:3])
+	for _, d := range w.Days {
+		if strings.HasPrefix(strings.ToLower(d), day) {
+			return true
+		}
+	}
+	return false
+}
+
+const minutesPerDay = 24 * 60
+
+// clockRange returns the window's from and to in minutes since midnight, the whole day by default
+func (w FreezeWindow) clockRange() (from, to int, err error) {
+	from, to = 0, minutesPerDay
+	if w.From != "" {
+		if from, err = minutesOfDay(w.From); err != nil {
+			return 0, 0, fmt.Errorf("freeze window %s: %w", w.Name, err)
+		}
+	}
+	if w.To != "" {
+		if to, err = minutesOfDay(w.To); err != nil {
+			return 0, 0, fmt.Errorf("freeze window %s: %w", w.Name, err)
+		}
+	}
+	return from, to, nil
+}
+
+// minutesOfDay parses H:MM or HH:MM (00:00 to 24:00) into minutes since midnight
+func minutesOfDay(clock string) (int, error) {
+	if clock == "24:00" {
+		return minutesPerDay, nil
+	}
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("times must be HH:MM, got %q", clock)
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+// validWeekday accepts English weekday names and their three letter abbreviations
+func validWeekday(day string) bool {
+	day = strings.ToLower(day)
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		name := strings.ToLower(weekday.String())
+		if day == name || day == name[:3] {
+			return true
+		}
+	}
+	return false
+}
+
+func (w FreezeWindow) validate() error {
+	if !w.Recurring() {
+		if w.Start.IsZero() || w.End.IsZero() || !w.End.After(w.Start) {
+			return fmt.Errorf("freeze window %s needs days or a start before its end", w.Name)
+		}
+		return nil
+	}
+	for _, d := range w.Days {
+		if !validWeekday(d) {
+			return fmt.Errorf("freeze window %s: unknown day %q", w.Name, d)
+		}
+	}
+	from, to, err := w.clockRange()
+	if err != nil {
+		return err
+	}
+	if from == to || from == minutesPerDay {
+		return fmt.Errorf("freeze window %s: from and to must differ and from must be before 24:00", w.Name)
+	}
+	if w.Timezone != "" {
+		if _, err := time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("freeze window %s: %w", w.Name, err)
+		}
+	}
+	return nil
+}
+
+// ReviewersSpec lists required reviewers for an environment
+type ReviewersSpec struct {
+	Users []string `yaml:"users,omitempty" json:"users,omitempty"`
+	Teams []string `yaml:"teams,omitempty" json:"teams,omitempty"`
+}
+
+// Count returns the total number of reviewers
+func (r ReviewersSpec) Count() int {
+	return len(r.Users) + len(r.Teams)
+}
+
+// BranchPolicySpec restricts deployments either to protected branches or to name patterns
+type BranchPolicySpec struct {
+	ProtectedBranches bool `yaml:"protected_branches,omitempty" json:"protected_branches,omitempty"`
+
+	// fnmatch patterns, e.g. main or release/*
+	Branches []string `yaml:"branches,omitempty" json:"branches,omitempty"`
+	Tags     []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// CustomPolicies reports whether the policy uses branch and tag name patterns
+func (p *BranchPolicySpec) CustomPolicies() bool {
+	return len(p.Branches) > 0 || len(p.Tags) > 0
+}
+
+// Environment returns the spec of the named environment, nil when it is not declared
+func (s *EnvironmentsSpec) Environment(name string) *EnvironmentSpec {
+	for i := range s.Environments {
+		if s.Environments[i].Name == name {
+			return &s.Environments[i]
+		}
+	}
+	return nil
+}
+
+// EnvironmentsFor returns the environments that apply to a repository target
+func (s *EnvironmentsSpec) EnvironmentsFor(target RepositoryTarget) []EnvironmentSpec {
+	if len(target.Environments) == 0 {
+		return s.Environments
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range target.Environments {
+		selected[name] = true
+	}
+	var environments []EnvironmentSpec
+	for _, environment := range s.Environments {
+		if selected[environment.Name] {
+			environments = append(environments, environment)
+		}
+	}
+	return environments
+}
+
+// ReleasePolicy returns the release policy of a repository, nil when it has none
+// host is the resolved host name, entries without a github_host match every host
+func (s *EnvironmentsSpec) ReleasePolicy(host, owner, repo string) *ReleasePolicy {
+	for _, target := range s.Repositories {
+		if target.Release == nil || !target.onHost(host) || !strings.EqualFold(target.Owner, owner) {
+			continue
+		}
+		for _, name := range target.Repos {
+			if strings.EqualFold(name, repo) {
+				return target.Release
+			}
+		}
+	}
+	return nil
+}
+
+// LoadEnvironmentsSpec reads and validates an environments.yaml file
+// Unknown keys are rejected so typos don't silently drop a protection rule
+func LoadEnvironmentsSpec(path string) (*EnvironmentsSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read environments file %s: %w", path, err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var spec EnvironmentsSpec
+	if err := decoder.Decode(&spec); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse environments file %s: %w", path, err)
+	}
+
+	if err := spec.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid environments file %s: %w", path, err)
+	}
+
+	return &spec, nil
+}
+
+// Validate checks the spec against the environment names and GitHub API limits
+func (s *EnvironmentsSpec) Validate() error {
+	names := make(map[string]bool)
+	for _, environment := range s.Environments {
+		if !IsValidEnvironment(environment.Name) {
+			return fmt.Errorf("unknown environment %q (valid: %v)", environment.Name, ValidEnvironments())
+		}
+		if names[environment.Name] {
+			return fmt.Errorf("environment %s is defined more than once", environment.Name)
+		}
+		names[environment.Name] = true
+
+		if environment.WaitTimer < 0 || environment.WaitTimer > MaxEnvironmentWaitTimer {
+			return fmt.Errorf("environment %s: wait_timer must be between 0 and %d minutes", environment.Name, MaxEnvironmentWaitTimer)
+		}
+		if environment.Reviewers.Count() > MaxEnvironmentReviewers {
+			return fmt.Errorf("environment %s: at most %d reviewers are allowed", environment.Name, MaxEnvironmentReviewers)
+		}
+		if gate := environment.Gate; gate != nil {
+			for _, window := range gate.FreezeWindows {
+				if err := window.validate(); err != nil {
+					return fmt.Errorf("environment %s: %w", environment.Name, err)
+				}
+			}
+			for _, upstream := range gate.RequireSuccessIn {
+				if !IsValidEnvironment(upstream) {
+					return fmt.Errorf("environment %s: gate requires unknown environment %q", environment.Name, upstream)
+				}
+			}
+		}
+		if verification := environment.Verification; verification != nil {
+			if err := verification.Validate(); err != nil {
+				return fmt.Errorf("environment %s: %w", environment.Name, err)
+			}
+		}
+		if policy := environment.BranchPolicy; policy != nil {
+			if policy.ProtectedBranches && policy.CustomPolicies() {
+				return fmt.Errorf("environment %s: branch_policy cannot combine protected_branches with branches or tags", environment.Name)
+			}
+			if !policy.ProtectedBranches && !policy.CustomPolicies() {
+				return fmt.Errorf("environment %s: branch_policy needs protected_branches or branch/tag patterns", environment.Name)
+			}
+		}
+	}
+
+	for _, target := range s.Repositories {
+		if target.Owner == "" || len(target.Repos) == 0 {
+			return fmt.Errorf("every repositories entry needs an owner and at least one repo")
+		}
+		for _, name := range target.Environments {
+			if !names[name] {
+				return fmt.Errorf("repositories entry for %s references undefined environment %s", target.Owner, name)
+			}
+		}
+		if target.Release != nil {
+			if err := target.Release.validate(); err != nil {
+				return fmt.Errorf("repositories entry for %s: %w", target.Owner, err)
+			}
+		}
+		if target.URLs != nil {
+			if err := target.URLs.validate(); err != nil {
+				return fmt.Errorf("repositories entry for %s: %w", target.Owner, err)
+			}
+		}
+	}
+
+	policies := make(map[string]bool)
+	for i := range s.DeploymentPolicies {
+		policy := &s.DeploymentPolicies[i]
+		if err := policy.validate(); err != nil {
+			return err
+		}
+		if policies[policy.Environment] {
+			return fmt.Errorf("deployment policy for %s is defined more than once", policy.Environment)
+		}
+		policies[policy.Environment] = true
+	}
+
+	return nil
+}
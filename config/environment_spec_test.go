@@ -0,0 +1,121 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreezeWindowActive(t *testing.T) {
+	// 2026-10-16 is a Friday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name   string
+		window FreezeWindow
+		at     time.Time
+		active bool
+	}{
+		{"inside", FreezeWindow{Days: []string{"fri"}, From: "18:00", To: "23:59"}, at(16, 20, 0), true},
+		{"before", FreezeWindow{Days: []string{"fri"}, From: "18:00", To: "23:59"}, at(16, 17, 59), false},
+		{"other day", FreezeWindow{Days: []string{"thu"}, From: "18:00", To: "23:59"}, at(16, 20, 0), false},
+		{"single digit hour", FreezeWindow{Days: []string{"friday"}, From: "9:00", To: "17:00"}, at(16, 10, 30), true},
+		{"single digit hour before", FreezeWindow{Days: []string{"friday"}, From: "9:00", To: "17:00"}, at(16, 8, 30), false},
+		{"whole day", FreezeWindow{Days: []string{"fri"}}, at(16, 0, 0), true},
+		{"until midnight", FreezeWindow{Days: []string{"fri"}, From: "22:00", To: "24:00"}, at(16, 23, 59), true},
+		{"crossing midnight evening", FreezeWindow{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at(16, 23, 0), true},
+		{"crossing midnight next morning", FreezeWindow{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at(17, 5, 59), true},
+		{"crossing midnight ended", FreezeWindow{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at(17, 6, 0), false},
+		{"crossing midnight morning of listed day", FreezeWindow{Days: []string{"fri"}, From: "22:00", To: "06:00"}, at(16, 5, 0), false},
+		{"crossing midnight into the week", FreezeWindow{Days: []string{"sun"}, From: "22:00", To: "06:00"}, at(19, 1, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.window.validate())
+			active, err := tt.window.Active(tt.at)
+			require.NoError(t, err)
+			assert.Equal(t, tt.active, active)
+		})
+	}
+}
+
+func TestFreezeWindowTimezone(t *testing.T) {
+	window := FreezeWindow{Days: []string{"fri"}, From: "18:00", To: "20:00", Timezone: "Europe/Berlin"}
+	require.NoError(t, window.validate())
+
+	// 17:00 UTC is 19:00 in Berlin during summer time
+	active, err := window.Active(time.Date(2026, 7, 17, 17, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.True(t, active)
+}
+
+func TestFreezeWindowValidate(t *testing.T) {
+	for name, window := range map[string]FreezeWindow{
+		"bad time":       {Name: "bad", Days: []string{"fri"}, From: "25:00"},
+		"bad day":        {Name: "bad", Days: []string{"someday"}},
+		"empty window":   {Name: "bad", Days: []string{"fri"}, From: "10:00", To: "10:00"},
+		"from midnight":  {Name: "bad", Days: []string{"fri"}, From: "24:00", To: "06:00"},
+		"bad time zone":  {Name: "bad", Days: []string{"fri"}, Timezone: "Mars/Olympus"},
+		"no time period": {Name: "bad"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Error(t, window.validate())
+		})
+	}
+}
+
+func TestVerificationPolicyValidate(t *testing.T) {
+	checks := []HTTPCheck{{Path: "/healthz"}}
+
+	assert.NoError(t, (&VerificationPolicy{Interval: MaxVerificationInterval, Checks: checks}).Validate())
+	assert.Error(t, (&VerificationPolicy{Interval: MaxVerificationInterval + time.Second, Checks: checks}).Validate())
+	assert.Error(t, (&VerificationPolicy{Timeout: MaxVerificationTimeout + time.Second, Checks: checks}).Validate())
+	assert.Error(t, (&VerificationPolicy{}).Validate())
+	assert.Error(t, (&VerificationPolicy{Checks: []HTTPCheck{{Path: "healthz"}}}).Validate())
+}
+
+func TestVerificationPolicyJSONDurations(t *testing.T) {
+	var policy VerificationPolicy
+	require.NoError(t, json.Unmarshal([]byte(`{"timeout":"2m","interval":5000000000,"checks":[{"path":"/healthz"}]}`), &policy))
+	assert.Equal(t, 2*time.Minute, policy.Timeout)
+	assert.Equal(t, 5*time.Second, policy.Interval)
+	assert.Equal(t, []HTTPCheck{{Path: "/healthz"}}, policy.Checks)
+
+	// Temporal round-trips activity input through JSON
+	data, err := json.Marshal(policy)
+	require.NoError(t, err)
+	var decoded VerificationPolicy
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, policy, decoded)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"timeout":"soon"}`), &decoded))
+	assert.Error(t, json.Unmarshal([]byte(`{"interval":true}`), &decoded))
+}
+
+func TestURLTemplatesMatchResolvedHost(t *testing.T) {
+	spec := &EnvironmentsSpec{Repositories: []RepositoryTarget{
+		{GithubHost: "ghes-east", Owner: "acme", Repos: []string{"web"}, URLs: &URLTemplates{LogURL: "https://logs.east.example.com"}},
+		{Owner: "acme", Repos: []string{"web"}, URLs: &URLTemplates{LogURL: "https://logs.example.com"}},
+	}}
+
+	assert.Equal(t, "https://logs.east.example.com", spec.URLTemplates("ghes-east", "acme", "web", "staging").LogURL)
+	assert.Equal(t, "https://logs.example.com", spec.URLTemplates("dotcom", "acme", "web", "staging").LogURL)
+}
+
+func TestReleasePolicyMatchesResolvedHost(t *testing.T) {
+	scoped := &ReleasePolicy{}
+	routed := &ReleasePolicy{}
+	spec := &EnvironmentsSpec{Repositories: []RepositoryTarget{
+		{GithubHost: "ghes-east", Owner: "acme", Repos: []string{"web"}, Release: scoped},
+		{Owner: "acme", Repos: []string{"web"}, Release: routed},
+	}}
+
+	assert.Same(t, scoped, spec.ReleasePolicy("ghes-east", "acme", "web"))
+	assert.Same(t, routed, spec.ReleasePolicy("dotcom", "acme", "web"))
+	assert.Nil(t, spec.ReleasePolicy("dotcom", "acme", "api"))
+}
@@ -1,5 +1,10 @@
 package config
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Deployment environments as constants to prevent typos
 const (
 	// EnvironmentProduction represents the production environment
@@ -37,4 +42,24 @@ func IsValidEnvironment(env string) bool {
 		}
 	}
 	return false
-}
\ No newline at end of file
+}
+
+// PREnvironmentPrefix prefixes the per pull request environments created for previews, e.g. pr-123
+const PREnvironmentPrefix = "pr-"
+
+// PREnvironment returns the environment name of a pull request preview
+func PREnvironment(number int) string {
+	return PREnvironmentPrefix + strconv.Itoa(number)
+}
+
+// PRNumberFromEnvironment returns the pull request of a pr-<n> environment, 0 for any other name
+func PRNumberFromEnvironment(env string) int {
+	if !strings.HasPrefix(env, PREnvironmentPrefix) {
+		return 0
+	}
+	number, err := strconv.Atoi(strings.TrimPrefix(env, PREnvironmentPrefix))
+	if err != nil || number <= 0 {
+		return 0
+	}
+	return number
+}
@@ -0,0 +1,292 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/caarlos0/env/v10"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnv names the environment variable holding the configuration file path
+const ConfigFileEnv = "CONFIG_FILE"
+
+// LoadOptions select the configuration file and command-line settings Load reads
+// Settings are layered as defaults < configuration file < environment variables < command-line flags
+type LoadOptions struct {
+	// YAML configuration file, defaults to CONFIG_FILE, empty reads none
+	File string
+
+	// Settings from command-line flags by file key, e.g. temporal.host
+	Settings map[string]string
+}
+
+// BindFlags registers -config and the repeatable -set key=value on a flag set
+// The returned options are filled in once the flag set is parsed
+//
+//	worker -config ghdeploy.yaml -set temporal.host=temporal:7233 -set app.log_level=debug
+func BindFlags(flags *flag.FlagSet) *LoadOptions {
+	options := &LoadOptions{Settings: make(map[string]string)}
+	flags.StringVar(&options.File, "config", "", "YAML configuration file (default $"+ConfigFileEnv+")")
+	flags.Func("set", "configuration file setting as key=value, e.g. temporal.host=localhost:7233 (repeatable)", func(value string) error {
+		key, setting, ok := strings.Cut(value, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return fmt.Errorf("expected key=value, got %q", value)
+		}
+		options.Settings[strings.TrimSpace(key)] = setting
+		return nil
+	})
+	return options
+}
+
+// ConfigFile is the configuration file
+//
+//	temporal:
+//	  host: temporal.internal:7233
+//	github:
+//	  app_id: 319033
+//	  rate_limit:
+//	    max_retries: 8
+//	  hosts: [dotcom, ghes-east]
+//	  host:
+//	    ghes-east:
+//	      url: https://github.east.example.com
+//	      app_id: 42
+//	teardown:
+//	  event_sink_url: https://events.example.com/teardown
+//	overrides:
+//	  - owner: acme
+//	    repo: web
+//	    environment: production
+//	    retry:
+//	      max_attempts: 10
+//
+// Settings mirror the environment variables, github.rate_limit.max_retries is GITHUB_RATE_LIMIT_MAX_RETRIES
+// and lists are joined with commas. Unknown keys are rejected so typos don't silently fall back to a default
+type ConfigFile struct {
+	// Settings by environment variable name
+	Settings map[string]string
+
+	// Per-organization, repository and environment overrides
+	Overrides Overrides
+}
+
+// LoadConfigFile reads a configuration file
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %s: %w", path, err)
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+
+	file := &ConfigFile{Settings: make(map[string]string)}
+	if len(root.Content) == 0 {
+		return file, nil
+	}
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("configuration file %s must be a mapping", path)
+	}
+
+	for i := 0; i < len(document.Content); i += 2 {
+		key, value := document.Content[i], document.Content[i+1]
+		if key.Value == "overrides" {
+			if file.Overrides, err = decodeOverrides(value); err != nil {
+				return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+			}
+			continue
+		}
+		if err := flattenSettings(value, []string{key.Value}, file.Settings); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+		}
+	}
+
+	if err := file.Overrides.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration file %s: %w", path, err)
+	}
+
+	return file, nil
+}
+
+// decodeOverrides decodes the overrides list, rejecting unknown keys
+func decodeOverrides(node *yaml.Node) (Overrides, error) {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return nil, fmt.Errorf("overrides: %w", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	var overrides Overrides
+	if err := decoder.Decode(&overrides); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("overrides: %w", err)
+	}
+	return overrides, nil
+}
+
+// flattenSettings turns nested settings into environment variables, checking every key
+func flattenSettings(node *yaml.Node, path []string, into map[string]string) error {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if err := flattenSettings(node.Content[i+1], append(append([]string(nil), path...), key), into); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case yaml.SequenceNode:
+		values := make([]string, 0, len(node.Content))
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("%s: lists may only hold plain values", strings.Join(path, "."))
+			}
+			values = append(values, item.Value)
+		}
+		return setSetting(strings.Join(path, "."), strings.Join(values, ","), into)
+
+	case yaml.ScalarNode:
+		return setSetting(strings.Join(path, "."), node.Value, into)
+
+	default:
+		return fmt.Errorf("%s: unsupported value", strings.Join(path, "."))
+	}
+}
+
+// setSetting stores a setting under its environment variable name
+func setSetting(key, value string, into map[string]string) error {
+	name := settingEnvName(key)
+	if !isKnownSetting(name) {
+		return fmt.Errorf("unknown key %s", key)
+	}
+	into[name] = value
+	return nil
+}
+
+// settingEnvName maps a file key to its environment variable, e.g. github.host.ghes-east.app_id to GITHUB_HOST_GHES_EAST_APP_ID
+func settingEnvName(key string) string {
+	return strings.NewReplacer(".", "_", "-", "_").Replace(strings.ToUpper(key))
+}
+
+// isKnownSetting reports whether an environment variable is read by Load
+// Host settings are only checked for their field here, checkHostSettings checks the host once GITHUB_HOSTS is known
+func isKnownSetting(name string) bool {
+	if knownSettings()[name] {
+		return true
+	}
+
+	// Registered hosts are read with GITHUB_HOST_<NAME>_ prefixes
+	rest, ok := strings.CutPrefix(name, "GITHUB_HOST_")
+	if !ok {
+		return false
+	}
+	for key := range hostSettings() {
+		if hostName, found := strings.CutSuffix(rest, "_"+key); found && hostName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHostSettings rejects settings of GitHub hosts that the layered GITHUB_HOSTS doesn't list,
+// they would be ignored silently otherwise
+func checkHostSettings(hostNames []string, layers ...map[string]string) error {
+	prefixes := make([]string, 0, len(hostNames))
+	for _, name := range hostNames {
+		if name = strings.TrimSpace(name); name != "" {
+			prefixes = append(prefixes, hostEnvPrefix(name))
+		}
+	}
+	known := knownSettings()
+	fields := hostSettings()
+
+	var names []string
+	for _, layer := range layers {
+		for name := range layer {
+			if !known[name] && strings.HasPrefix(name, "GITHUB_HOST_") {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		listed := false
+		for _, prefix := range prefixes {
+			if field, found := strings.CutPrefix(name, prefix); found && fields[field] {
+				listed = true
+				break
+			}
+		}
+		if !listed {
+			return fmt.Errorf("%s is set for a GitHub host that GITHUB_HOSTS (github.hosts) doesn't list", name)
+		}
+	}
+	return nil
+}
+
+// knownSettings returns the environment variables of Config
+func knownSettings() map[string]bool {
+	params, err := env.GetFieldParams(&Config{})
+	if err != nil {
+		return nil
+	}
+	known := make(map[string]bool, len(params))
+	for _, param := range params {
+		known[param.Key] = true
+	}
+	return known
+}
+
+// hostSettings returns the environment variables of a registered host without the prefix
+func hostSettings() map[string]bool {
+	params, err := env.GetFieldParams(&GitHubHostConfig{})
+	if err != nil {
+		return nil
+	}
+	known := make(map[string]bool, len(params))
+	for _, param := range params {
+		known[param.Key] = true
+	}
+	return known
+}
+
+// layeredEnvironment merges the configuration layers, later ones taking precedence
+func layeredEnvironment(layers ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, layer := range layers {
+		for key, value := range layer {
+			merged[key] = value
+		}
+	}
+	return merged
+}
+
+// flagSettings checks command-line settings and returns them by environment variable name
+func flagSettings(settings map[string]string) (map[string]string, error) {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	environment := make(map[string]string, len(settings))
+	for _, key := range keys {
+		if err := setSetting(key, settings[key], environment); err != nil {
+			return nil, fmt.Errorf("-set %s: %w", key, err)
+		}
+	}
+	return environment, nil
+}
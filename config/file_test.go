@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ghdeploy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	file, err := LoadConfigFile(writeConfigFile(t, `
+temporal:
+  host: temporal.internal:7233
+github:
+  hosts: [dotcom, ghes-east]
+  host:
+    ghes-east:
+      app_id: 42
+overrides:
+  - owner: acme
+    repo: web
+    environment: production
+    retry:
+      max_attempts: 10
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "temporal.internal:7233", file.Settings["TEMPORAL_HOST"])
+	assert.Equal(t, "dotcom,ghes-east", file.Settings["GITHUB_HOSTS"])
+	assert.Equal(t, "42", file.Settings["GITHUB_HOST_GHES_EAST_APP_ID"])
+	require.Len(t, file.Overrides, 1)
+	assert.Equal(t, int32(10), file.Overrides[0].Retry.MaxAttempts)
+}
+
+func TestLoadConfigFileRejectsUnknownKeys(t *testing.T) {
+	_, err := LoadConfigFile(writeConfigFile(t, "temporal:\n  hots: localhost:7233\n"))
+	assert.ErrorContains(t, err, "unknown key temporal.hots")
+
+	_, err = LoadConfigFile(writeConfigFile(t, "github:\n  host:\n    ghes-east:\n      app_idd: 42\n"))
+	assert.ErrorContains(t, err, "unknown key github.host.ghes-east.app_idd")
+}
+
+func TestCheckHostSettings(t *testing.T) {
+	file := map[string]string{"GITHUB_HOSTS": "ghes-east", "GITHUB_HOST_GHES_EAST_APP_ID": "42"}
+	assert.NoError(t, checkHostSettings([]string{"ghes-east"}, file))
+
+	// A host renamed or dropped from the list must not leave its settings behind unnoticed
+	flags := map[string]string{"GITHUB_HOST_GHES_WEST_APP_ID": "43"}
+	assert.ErrorContains(t, checkHostSettings([]string{"ghes-east"}, file, flags), "GITHUB_HOST_GHES_WEST_APP_ID")
+
+	// Without GITHUB_HOSTS there are no registered hosts
+	assert.Error(t, checkHostSettings(nil, file))
+
+	// A host whose name extends another one's is not taken for a setting of the shorter one
+	assert.Error(t, checkHostSettings([]string{"ghes"}, map[string]string{"GITHUB_HOST_GHES_EAST_APP_ID": "42"}))
+}
+
+func TestDeploymentPolicyForOverridesTurnSettingsOff(t *testing.T) {
+	file, err := LoadConfigFile(writeConfigFile(t, `
+overrides:
+  - owner: acme
+    transient: true
+    auto_merge: true
+  - owner: acme
+    repo: web
+    transient: false
+    auto_merge: false
+`))
+	require.NoError(t, err)
+	spec := (&EnvironmentsSpec{}).WithOverrides(file.Overrides)
+
+	policy := spec.DeploymentPolicyFor("", "acme", "api", "staging")
+	assert.True(t, policy.IsTransient(false))
+	assert.True(t, policy.AutoMergeOrDefault())
+
+	policy = spec.DeploymentPolicyFor("", "acme", "web", "staging")
+	assert.False(t, policy.IsTransient(true))
+	assert.False(t, policy.AutoMergeOrDefault())
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/caarlos0/env/v10"
+)
+
+// DefaultGitHubHost is the host name used for the legacy single-host configuration
+const DefaultGitHubHost = "default"
+
+// GitHubHostConfig describes a single GitHub instance (GitHub.com or an Enterprise Server)
+// Each host is configured with GITHUB_HOST_<NAME>_* environment variables, e.g.
+//
+//	GITHUB_HOSTS=dotcom,ghes-east
+//	GITHUB_HOST_DOTCOM_APP_ID=319033
+//	GITHUB_HOST_DOTCOM_DEFAULT=true
+//	GITHUB_HOST_GHES_EAST_URL=https://github.east.example.com
+//	GITHUB_HOST_GHES_EAST_APP_ID=42
+//	GITHUB_HOST_GHES_EAST_OWNERS=platform,payments
+type GitHubHostConfig struct {
+	// Name is the registry key referenced by the github_host workflow input
+	Name string
+
+	// Base URL of the Enterprise instance, empty for GitHub.com
+	URL string `env:"URL"`
+
+	// GitHub App ID registered on this host
+	AppID int64 `env:"APP_ID"`
+
+	// App private key file, directory or glob, defaults to <SECRETS_PATH>/<name>.private-key.pem
+	// Point it at a directory or glob to rotate keys without a redeploy
+	PrivateKeyPath string `env:"PRIVATE_KEY_PATH"`
+
+	// Owners routed to this host when a workflow does not name a host
+	Owners []string `env:"OWNERS" envSeparator:","`
+
+	// Default marks the host used for owners not listed on any host
+	Default bool `env:"DEFAULT" envDefault:"false"`
+
+	// Network options (CA bundles, proxy, client certificates, timeouts)
+	Transport TransportConfig `envPrefix:"TRANSPORT_"`
+
+	// App JWT signer (pem or sidecar)
+	Signer SignerConfig `envPrefix:"SIGNER_"`
+}
+
+// IsEnterprise reports whether the host is a GitHub Enterprise Server instance
+func (h GitHubHostConfig) IsEnterprise() bool {
+	return h.URL != ""
+}
+
+// loadHosts resolves the GitHub host registry from environment variables
+// Without GITHUB_HOSTS a single default host is built from the legacy settings
+func loadHosts(cfg *Config, environment map[string]string) error {
+	if len(cfg.GitHub.HostNames) == 0 {
+		cfg.GitHub.Hosts = []GitHubHostConfig{{
+			Name:      DefaultGitHubHost,
+			URL:       cfg.GitHub.EnterpriseURL,
+			AppID:     cfg.GitHub.AppID,
+			Default:   true,
+			Transport: cfg.GitHub.Transport,
+			Signer:    cfg.GitHub.Signer,
+		}}
+		return nil
+	}
+
+	hosts := make([]GitHubHostConfig, 0, len(cfg.GitHub.HostNames))
+	for _, name := range cfg.GitHub.HostNames {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		host := GitHubHostConfig{Name: name}
+		if err := env.ParseWithOptions(&host, env.Options{Prefix: hostEnvPrefix(name), Environment: environment}); err != nil {
+			return fmt.Errorf("failed to parse configuration for GitHub host %s: %w", name, err)
+		}
+		host.URL = strings.TrimSuffix(host.URL, "/")
+		hosts = append(hosts, host)
+	}
+	cfg.GitHub.Hosts = hosts
+
+	return nil
+}
+
+// hostEnvPrefix returns the environment variable prefix for a host name
+func hostEnvPrefix(name string) string {
+	normalized := strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(name))
+	return "GITHUB_HOST_" + normalized + "_"
+}
+
+func validateHosts(cfg *Config) error {
+	if len(cfg.GitHub.Hosts) == 0 {
+		return fmt.Errorf("GITHUB_HOSTS is set but no hosts were configured")
+	}
+
+	names := make(map[string]bool)
+	owners := make(map[string]string)
+	defaults := 0
+	for _, host := range cfg.GitHub.Hosts {
+		if names[host.Name] {
+			return fmt.Errorf("GitHub host %s is configured more than once", host.Name)
+		}
+		names[host.Name] = true
+
+		if host.AppID == 0 {
+			return fmt.Errorf("GitHub App ID is required for host %s", host.Name)
+		}
+		if err := validateSigner(host.Signer); err != nil {
+			return fmt.Errorf("invalid signer for host %s: %w", host.Name, err)
+		}
+		if host.Signer.UsesPrivateKey() && len(cfg.Secrets.GitHubHostKeys[host.Name]) == 0 {
+			return fmt.Errorf("GitHub App private key is required for host %s", host.Name)
+		}
+		if host.Default {
+			defaults++
+		}
+
+		for _, owner := range host.Owners {
+			key := strings.ToLower(strings.TrimSpace(owner))
+			if other, exists := owners[key]; exists {
+				return fmt.Errorf("owner %s is routed to both GitHub hosts %s and %s", owner, other, host.Name)
+			}
+			owners[key] = host.Name
+		}
+	}
+
+	if defaults > 1 {
+		return fmt.Errorf("only one GitHub host can be marked as default, found %d", defaults)
+	}
+
+	return nil
+}
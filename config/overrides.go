@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Override adjusts the deployment policy and notification targets of an organization,
+// a repository or an environment, set in the configuration file
+//
+//	overrides:
+//	  - owner: acme
+//	    notifications:
+//	      teardown_event_sink_url: https://events.acme.example.com/teardown
+//	  - owner: acme
+//	    repo: web
+//	    environment: "pr-*"
+//	    retry:
+//	      max_attempts: 10
+//	    urls:
+//	      environment_url: "https://pr-{{.PR}}.web.preview.example.com"
+//
+// Every matching override applies on top of environments.yaml, the more specific ones last:
+// organization before repository, and without an environment before with one
+type Override struct {
+	// Registered host name, empty matches every host
+	GithubHost string `yaml:"github_host,omitempty" json:"github_host,omitempty"`
+
+	// Organization or user, empty matches every owner
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+
+	// Repository of the owner, empty matches all of them
+	Repo string `yaml:"repo,omitempty" json:"repo,omitempty"`
+
+	// Deployment policy settings, environment is a name or path.Match pattern and empty matches every environment
+	DeploymentPolicy `yaml:",inline"`
+
+	// Where events about the matching repositories are sent
+	Notifications *NotificationTargets `yaml:"notifications,omitempty" json:"notifications,omitempty"`
+}
+
+// NotificationTargets override the endpoints events are sent to
+type NotificationTargets struct {
+	// CloudEvents endpoint notified after a pull request teardown
+	TeardownEventSinkURL string `yaml:"teardown_event_sink_url,omitempty" json:"teardown_event_sink_url,omitempty"`
+}
+
+// Overrides are the overrides of the configuration file in file order
+type Overrides []Override
+
+// matches reports whether the override applies to a repository's environment
+// host is the resolved host name, an empty environment only matches overrides for every environment
+func (o *Override) matches(host, owner, repo, environment string) bool {
+	if o.GithubHost != "" && o.GithubHost != host {
+		return false
+	}
+	if o.Owner != "" && !strings.EqualFold(o.Owner, owner) {
+		return false
+	}
+	if o.Repo != "" && !strings.EqualFold(o.Repo, repo) {
+		return false
+	}
+	if o.Environment == "" {
+		return true
+	}
+	matched, _ := path.Match(o.Environment, environment)
+	return matched
+}
+
+// specificity orders overrides from organization-wide to repository and environment specific
+func (o *Override) specificity() int {
+	score := 0
+	if o.Repo != "" {
+		score += 2
+	}
+	if o.Environment != "" {
+		score++
+	}
+	return score
+}
+
+// Matching returns the overrides applying to a repository's environment, least specific first
+// host must be the resolved host name, not the empty name workflows route by owner with
+func (o Overrides) Matching(host, owner, repo, environment string) []Override {
+	var matching []Override
+	for i := range o {
+		if o[i].matches(host, owner, repo, environment) {
+			matching = append(matching, o[i])
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return matching[i].specificity() < matching[j].specificity()
+	})
+	return matching
+}
+
+// TeardownEventSinkURL returns the teardown event sink of a repository, fallback when no override sets one
+func (o Overrides) TeardownEventSinkURL(host, owner, repo, fallback string) string {
+	sink := fallback
+	for _, override := range o.Matching(host, owner, repo, "") {
+		if override.Notifications != nil && override.Notifications.TeardownEventSinkURL != "" {
+			sink = override.Notifications.TeardownEventSinkURL
+		}
+	}
+	return sink
+}
+
+// Validate checks every override
+func (o Overrides) Validate() error {
+	for i := range o {
+		override := &o[i]
+		name := override.name()
+		if override.Owner == "" && override.Repo == "" && override.GithubHost == "" && override.Environment == "" {
+			return fmt.Errorf("override %d needs a github_host, owner, repo or environment", i+1)
+		}
+		if override.Repo != "" && override.Owner == "" {
+			return fmt.Errorf("override %s: repo needs an owner", name)
+		}
+		if override.Environment != "" {
+			if _, err := path.Match(override.Environment, ""); err != nil {
+				return fmt.Errorf("override %s: invalid environment pattern: %w", name, err)
+			}
+		}
+		if err := override.DeploymentPolicy.validateSettings(); err != nil {
+			return fmt.Errorf("override %s: %w", name, err)
+		}
+		if notifications := override.Notifications; notifications != nil && notifications.TeardownEventSinkURL != "" {
+			if !strings.HasPrefix(notifications.TeardownEventSinkURL, "http://") && !strings.HasPrefix(notifications.TeardownEventSinkURL, "https://") {
+				return fmt.Errorf("override %s: teardown_event_sink_url must be an http(s) URL", name)
+			}
+		}
+	}
+	return nil
+}
+
+// name describes the override in errors, e.g. acme/web@production
+func (o *Override) name() string {
+	name := o.Owner
+	if o.Repo != "" {
+		name += "/" + o.Repo
+	}
+	if o.Environment != "" {
+		name += "@" + o.Environment
+	}
+	if o.GithubHost != "" {
+		name = o.GithubHost + ":" + name
+	}
+	return name
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Deployment tasks as constants to prevent typos
+const (
+	// TaskDeploy deploys the application
+	TaskDeploy = "deploy"
+
+	// TaskMigrations runs database migrations alongside the application deployment
+	TaskMigrations = "deploy:migrations"
+
+	// TaskConfig applies a configuration change to the running application
+	TaskConfig = "deploy:config"
+
+	// TaskRollback deploys an earlier version of the application
+	TaskRollback = "deploy:rollback"
+
+	// TaskRedeploy copies an earlier deployment of the application
+	TaskRedeploy = "deploy:redeploy"
+)
+
+// Custom tasks are deploy:<name>, e.g. deploy:search-index
+var taskPattern = regexp.MustCompile(`^deploy(:[a-z0-9][a-z0-9_-]*)?$`)
+
+// ValidateTask checks a deployment task, empty means TaskDeploy
+func ValidateTask(task string) error {
+	if task != "" && !taskPattern.MatchString(task) {
+		return fmt.Errorf("invalid deployment task %q, expected %s or deploy:<name> like %s", task, TaskDeploy, TaskMigrations)
+	}
+	return nil
+}
+
+// TaskOrDefault returns the task, TaskDeploy when it is empty
+func TaskOrDefault(task string) string {
+	if task == "" {
+		return TaskDeploy
+	}
+	return task
+}
+
+// IsApplicationTask reports whether a task deploys the application itself, so a successful
+// deployment of it replaces the environment's earlier ones
+// Other tasks, e.g. migrations or config changes, run alongside and leave the application deployment active
+func IsApplicationTask(task string) bool {
+	switch task {
+	case "", TaskDeploy, TaskRollback, TaskRedeploy:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// URLTemplates derive a deployment's status URLs when the event doesn't send them
+//
+//	urls:
+//	  log_url: "https://app.harness.io/ng/account/acme/cd/orgs/default/projects/web/pipelines/{{.HarnessPipelineID}}/executions/{{.HarnessExecutionID}}/pipeline"
+//	  environment_url: "{{if .PR}}https://pr-{{.PR}}.{{.Repo}}.preview.example.com{{end}}"
+//
+// Templates are text/template with .Host, .Owner, .Repo, .Environment, .Task, .DeploymentID,
+// .SHA, .ShortSHA, .PR (0 without one), .HarnessExecutionID, .HarnessPipelineID and .HarnessStageID
+// A template rendering an empty string leaves the URL unset
+type URLTemplates struct {
+	LogURL         string `yaml:"log_url,omitempty" json:"log_url,omitempty"`
+	EnvironmentURL string `yaml:"environment_url,omitempty" json:"environment_url,omitempty"`
+}
+
+// IsEmpty reports whether no template is set
+func (t URLTemplates) IsEmpty() bool {
+	return t.LogURL == "" && t.EnvironmentURL == ""
+}
+
+func (t *URLTemplates) validate() error {
+	for name, text := range map[string]string{"log_url": t.LogURL, "environment_url": t.EnvironmentURL} {
+		if text == "" {
+			continue
+		}
+		if _, err := template.New(name).Option("missingkey=error").Parse(text); err != nil {
+			return fmt.Errorf("urls %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// merge replaces the templates the other one sets
+func (t *URLTemplates) merge(other URLTemplates) {
+	if other.LogURL != "" {
+		t.LogURL = other.LogURL
+	}
+	if other.EnvironmentURL != "" {
+		t.EnvironmentURL = other.EnvironmentURL
+	}
+}
+
+// URLTemplates returns the URL templates of a repository's deployments to an environment
+// Each template comes from the most specific configuration of the configuration file's overrides,
+// the repositories entry and the environment's deployment policy
+func (s *EnvironmentsSpec) URLTemplates(host, owner, repo, environment string) URLTemplates {
+	var templates URLTemplates
+	if policy := s.DeploymentPolicy(environment); policy != nil && policy.URLs != nil {
+		templates = *policy.URLs
+	}
+
+	if target := s.repositoryTarget(host, owner, repo); target != nil && target.URLs != nil {
+		templates.merge(*target.URLs)
+	}
+
+	for _, override := range s.overrides.Matching(host, owner, repo, environment) {
+		if override.URLs != nil {
+			templates.merge(*override.URLs)
+		}
+	}
+	return templates
+}
+
+// repositoryTarget returns the first repositories entry with URL templates listing the repository
+// host is the resolved host name, entries without a github_host match every host
+func (s *EnvironmentsSpec) repositoryTarget(host, owner, repo string) *RepositoryTarget {
+	for i := range s.Repositories {
+		target := &s.Repositories[i]
+		if target.URLs == nil || !target.onHost(host) || !strings.EqualFold(target.Owner, owner) {
+			continue
+		}
+		for _, name := range target.Repos {
+			if strings.EqualFold(name, repo) {
+				return target
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package github
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// HTTPCache stores GitHub GET responses with their ETag/Last-Modified validators
+// Cached entries are revalidated with conditional requests; GitHub answers an
+// unchanged resource with 304 Not Modified, which does not count against the rate limit
+// Memory is bounded by both entry count and total body size (least recently used evicted first)
+type HTTPCache struct {
+	maxEntries int
+	maxBytes   int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	bytes   int64
+
+	hits        atomic.Int64
+	misses      atomic.Int64
+	stores      atomic.Int64
+	evictions   atomic.Int64
+	uncacheable atomic.Int64
+}
+
+// CacheStats is a point-in-time snapshot of cache metrics
+type CacheStats struct {
+	Hits        int64 `json:"hits"`        // 304 responses served from cache
+	Misses      int64 `json:"misses"`      // GET requests that returned a fresh body
+	Stores      int64 `json:"stores"`      // responses added or refreshed in the cache
+	Evictions   int64 `json:"evictions"`   // entries dropped to stay within bounds
+	Uncacheable int64 `json:"uncacheable"` // responses without validators or too large to keep
+	Entries     int   `json:"entries"`
+	Bytes       int64 `json:"bytes"`
+	MaxEntries  int   `json:"max_entries"`
+	MaxBytes    int64 `json:"max_bytes"`
+}
+
+// cacheEntry is a stored response body and the headers needed to replay it
+type cacheEntry struct {
+	key          string
+	etag         string
+	lastModified string
+	header       http.Header
+	body         []byte
+}
+
+// NewHTTPCache creates a cache bounded to maxEntries responses and maxBytes of bodies
+func NewHTTPCache(maxEntries int, maxBytes int64) *HTTPCache {
+	return &HTTPCache{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// Transport wraps base with conditional request caching
+// scope separates entries of different installations that share the cache
+func (c *HTTPCache) Transport(base http.RoundTripper, scope string) http.RoundTripper {
+	return &cachingTransport{cache: c, base: base, scope: scope}
+}
+
+// Stats returns a snapshot of the cache metrics
+func (c *HTTPCache) Stats() CacheStats {
+	c.mu.Lock()
+	entries, size := c.lru.Len(), c.bytes
+	c.mu.Unlock()
+
+	return CacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Stores:      c.stores.Load(),
+		Evictions:   c.evictions.Load(),
+		Uncacheable: c.uncacheable.Load(),
+		Entries:     entries,
+		Bytes:       size,
+		MaxEntries:  c.maxEntries,
+		MaxBytes:    c.maxBytes,
+	}
+}
+
+func (c *HTTPCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil
+	}
+	c.lru.MoveToFront(element)
+	return element.Value.(*cacheEntry)
+}
+
+func (c *HTTPCache) put(entry *cacheEntry) {
+	size := int64(len(entry.body))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[entry.key]; exists {
+		c.bytes -= int64(len(element.Value.(*cacheEntry).body))
+		c.lru.Remove(element)
+		delete(c.entries, entry.key)
+	}
+
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.bytes += size
+	c.stores.Add(1)
+
+	for c.lru.Len() > c.maxEntries || c.bytes > c.maxBytes {
+		oldest := c.lru.Back()
+		if oldest == nil {
+			break
+		}
+		evicted := oldest.Value.(*cacheEntry)
+		c.lru.Remove(oldest)
+		delete(c.entries, evicted.key)
+		c.bytes -= int64(len(evicted.body))
+		c.evictions.Add(1)
+	}
+}
+
+// cachingTransport adds validators to GET requests and replays cached bodies on 304
+type cachingTransport struct {
+	cache *HTTPCache
+	base  http.RoundTripper
+	scope string
+}
+
+func (t *cachingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.Header.Get("Range") != "" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := t.scope + " " + req.Header.Get("Accept") + " " + req.URL.String()
+	entry := t.cache.get(key)
+
+	if entry != nil {
+		req = req.Clone(req.Context())
+		if entry.etag != "" {
+			req.Header.Set("If-None-Match", entry.etag)
+		}
+		if entry.lastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.lastModified)
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && entry != nil {
+		t.cache.hits.Add(1)
+		return entry.replay(req, resp), nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	t.cache.misses.Add(1)
+
+	etag := resp.Header.Get("ETag")
+	lastModified := resp.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		t.cache.uncacheable.Add(1)
+		return resp, nil
+	}
+
+	// Skip bodies that could never fit, without buffering them
+	if resp.ContentLength > t.cache.maxBytes {
+		t.cache.uncacheable.Add(1)
+		return resp, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, t.cache.maxBytes+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if int64(len(body)) > t.cache.maxBytes {
+		// Too large to cache, hand back the buffered prefix followed by the rest of the stream
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		t.cache.uncacheable.Add(1)
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	t.cache.put(&cacheEntry{
+		key:          key,
+		etag:         etag,
+		lastModified: lastModified,
+		header:       resp.Header.Clone(),
+		body:         body,
+	})
+
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// rateLimitHeaders are refreshed from the 304 so callers see the current rate limit
+var rateLimitHeaders = []string{
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Used",
+	"X-RateLimit-Reset",
+	"X-RateLimit-Resource",
+}
+
+// replay builds a 200 response from the cached entry for a 304 revalidation
+func (e *cacheEntry) replay(req *http.Request, notModified *http.Response) *http.Response {
+	io.Copy(io.Discard, notModified.Body)
+	notModified.Body.Close()
+
+	header := e.header.Clone()
+	for _, name := range rateLimitHeaders {
+		if value := notModified.Header.Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	header.Set("X-From-Cache", "1")
+	header.Set("Content-Length", strconv.Itoa(len(e.body)))
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         notModified.Proto,
+		ProtoMajor:    notModified.ProtoMajor,
+		ProtoMinor:    notModified.ProtoMinor,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
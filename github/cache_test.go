@@ -0,0 +1,129 @@
+package github
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etagServer serves the path as body with a weak ETag and answers a matching If-None-Match with 304
+// It records the If-None-Match header of every request
+func etagServer(t *testing.T) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	var conditions []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		conditions = append(conditions, r.Header.Get("If-None-Match"))
+		mu.Unlock()
+
+		etag := `W/"` + r.URL.Path + `"`
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		if r.Header.Get("If-None-Match") == etag {
+			w.Header().Set("X-RateLimit-Remaining", "4998")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), conditions...)
+	}
+}
+
+func cachedGet(t *testing.T, transport http.RoundTripper, url string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestHTTPCacheReplaysNotModified(t *testing.T) {
+	server, conditions := etagServer(t)
+	cache := NewHTTPCache(10, 1<<20)
+	transport := cache.Transport(http.DefaultTransport, "installation-1")
+
+	resp, body := cachedGet(t, transport, server.URL+"/repos/acme/web")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/repos/acme/web", body)
+	assert.Empty(t, resp.Header.Get("X-From-Cache"))
+
+	resp, body = cachedGet(t, transport, server.URL+"/repos/acme/web")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/repos/acme/web", body)
+	assert.Equal(t, "1", resp.Header.Get("X-From-Cache"))
+	assert.Equal(t, "4998", resp.Header.Get("X-RateLimit-Remaining"), "rate limit headers come from the 304")
+
+	assert.Equal(t, []string{"", `W/"/repos/acme/web"`}, conditions())
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, 1, stats.Entries)
+}
+
+func TestHTTPCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	server, conditions := etagServer(t)
+	cache := NewHTTPCache(2, 1<<20)
+	transport := cache.Transport(http.DefaultTransport, "installation-1")
+
+	cachedGet(t, transport, server.URL+"/a")
+	cachedGet(t, transport, server.URL+"/b")
+	cachedGet(t, transport, server.URL+"/a") // /a is now the most recently used
+	cachedGet(t, transport, server.URL+"/c") // evicts /b
+	cachedGet(t, transport, server.URL+"/b")
+
+	assert.Equal(t, []string{"", "", `W/"/a"`, "", ""}, conditions())
+	stats := cache.Stats()
+	assert.Equal(t, int64(2), stats.Evictions)
+	assert.Equal(t, 2, stats.Entries)
+}
+
+func TestHTTPCacheEvictsByBytes(t *testing.T) {
+	server, _ := etagServer(t)
+	cache := NewHTTPCache(10, 10)
+	transport := cache.Transport(http.DefaultTransport, "installation-1")
+
+	cachedGet(t, transport, server.URL+"/first")
+	cachedGet(t, transport, server.URL+"/second")
+
+	stats := cache.Stats()
+	assert.Equal(t, 1, stats.Entries)
+	assert.Equal(t, int64(len("/second")), stats.Bytes)
+	assert.Equal(t, int64(1), stats.Evictions)
+
+	// A body larger than the cache passes through whole and isn't stored
+	_, body := cachedGet(t, transport, server.URL+"/"+strings.Repeat("x", 20))
+	assert.Equal(t, "/"+strings.Repeat("x", 20), body)
+	assert.Equal(t, int64(1), cache.Stats().Uncacheable)
+	assert.Equal(t, 1, cache.Stats().Entries)
+}
+
+func TestHTTPCacheScopesEntriesPerInstallation(t *testing.T) {
+	server, conditions := etagServer(t)
+	cache := NewHTTPCache(10, 1<<20)
+
+	cachedGet(t, cache.Transport(http.DefaultTransport, "installation-1"), server.URL+"/repos/acme/web")
+	resp, _ := cachedGet(t, cache.Transport(http.DefaultTransport, "installation-2"), server.URL+"/repos/acme/web")
+
+	// Another installation may not see the repository, so it never revalidates the first one's entry
+	assert.Empty(t, resp.Header.Get("X-From-Cache"))
+	assert.Equal(t, []string{"", ""}, conditions())
+	assert.Equal(t, 2, cache.Stats().Entries)
+}
@@ -0,0 +1,358 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// GitHub accepts at most 50 annotations per check run request
+const maxAnnotationsPerRequest = 50
+
+// CheckAnnotation is a failure detail reported on a deployment check run
+// Details without a path are listed in the summary instead of as annotations
+type CheckAnnotation struct {
+	Path      string `json:"path,omitempty"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+	Level     string `json:"level,omitempty"` // notice, warning or failure (default)
+	Title     string `json:"title,omitempty"`
+	Message   string `json:"message"`
+}
+
+// DeploymentCheckRun is the check run mirroring one deployment
+type DeploymentCheckRun struct {
+	Name       string
+	ExternalID string
+	Status     string
+	Conclusion string
+	Title      string
+	Summary    string
+	DetailsURL string
+}
+
+// CheckRunName returns the check run name for an environment, e.g. deploy/staging
+// Branch protection requires checks by this name
+func CheckRunName(environment string) string {
+	return "deploy/" + environment
+}
+
+// CheckRunState maps a deployment state to a check run status and conclusion
+func CheckRunState(state string) (status, conclusion string) {
+	switch state {
+	case "success":
+		return "completed", "success"
+	case "failure", "error":
+		return "completed", "failure"
+	case "inactive":
+		return "completed", "neutral"
+	case "in_progress":
+		return "in_progress", ""
+	default:
+		return "queued", ""
+	}
+}
+
+// DeploymentTimeline returns a deployment and all of its statuses, oldest first
+func DeploymentTimeline(ctx context.Context, client DeploymentAPI, owner, repo string, deploymentID int64) (*github.Deployment, []*github.DeploymentStatus, error) {
+	deployment, _, err := client.GetDeployment(ctx, owner, repo, deploymentID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get deployment %d: %w", deploymentID, err)
+	}
+
+	var statuses []*github.DeploymentStatus
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.ListDeploymentStatuses(ctx, owner, repo, deploymentID, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list statuses of deployment %d: %w", deploymentID, err)
+		}
+		statuses = append(statuses, page...)
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	// GitHub lists statuses newest first
+	for i, j := 0, len(statuses)-1; i < j; i, j = i+1, j-1 {
+		statuses[i], statuses[j] = statuses[j], statuses[i]
+	}
+	return deployment, statuses, nil
+}
+
+// NewDeploymentCheckRun builds the check run for a deployment from its status timeline
+func NewDeploymentCheckRun(deployment *github.Deployment, statuses []*github.DeploymentStatus, details []CheckAnnotation) DeploymentCheckRun {
+	environment := deployment.GetEnvironment()
+	state := "pending"
+	var latest *github.DeploymentStatus
+	if len(statuses) > 0 {
+		latest = statuses[len(statuses)-1]
+		state = latest.GetState()
+	}
+
+	status, conclusion := CheckRunState(state)
+	run := DeploymentCheckRun{
+		Name:       CheckRunName(environment),
+		ExternalID: strconv.FormatInt(deployment.GetID(), 10),
+		Status:     status,
+		Conclusion: conclusion,
+		Title:      fmt.Sprintf("Deployment to %s: %s", environment, state),
+	}
+	run.DetailsURL = latestLogURL(statuses)
+	if latest != nil && latest.GetDescription() != "" {
+		run.Title = fmt.Sprintf("%s: %s", environment, latest.GetDescription())
+	}
+	run.Summary = renderCheckRunSummary(deployment, statuses, details)
+	return run
+}
+
+// renderCheckRunSummary renders the Markdown summary: links, changed services, the status timeline
+// with the time spent in each state, and failure details that can't be annotations
+func renderCheckRunSummary(deployment *github.Deployment, statuses []*github.DeploymentStatus, details []CheckAnnotation) string {
+	payload := DeploymentPayload(deployment)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Deployment %d to %s\n\n", deployment.GetID(), deployment.GetEnvironment())
+	fmt.Fprintf(&b, "- **Commit:** `%s`\n", shortCommit(deployment.GetSHA()))
+	if ref := deployment.GetRef(); ref != "" && ref != deployment.GetSHA() {
+		fmt.Fprintf(&b, "- **Ref:** `%s`\n", ref)
+	}
+	if executionID := payload.ExecutionID(); executionID != "" {
+		if logURL := latestLogURL(statuses); logURL != "" {
+			fmt.Fprintf(&b, "- **Harness execution:** [%s](%s)\n", executionID, logURL)
+		} else {
+			fmt.Fprintf(&b, "- **Harness execution:** %s\n", executionID)
+		}
+	}
+	if pipelineID := payload.PipelineID(); pipelineID != "" {
+		fmt.Fprintf(&b, "- **Harness pipeline:** %s\n", pipelineID)
+	}
+	if len(payload.Services) > 0 {
+		b.WriteString("- **Changed services:** ")
+		var names []string
+		for _, service := range payload.Services {
+			names = append(names, "`"+service+"`")
+		}
+		b.WriteString(strings.Join(names, ", ") + "\n")
+	}
+	for _, artifact := range payload.Artifacts {
+		if artifact.Digest != "" {
+			fmt.Fprintf(&b, "- **Image:** `%s` (`%s`)\n", artifact.Image, artifact.Digest)
+		} else {
+			fmt.Fprintf(&b, "- **Image:** `%s`\n", artifact.Image)
+		}
+	}
+	for _, name := range payload.LinkNames() {
+		fmt.Fprintf(&b, "- **%s:** %s\n", name, payload.Links[name])
+	}
+
+	b.WriteString("\n#### Timeline\n\n")
+	started := deployment.GetCreatedAt().Time
+	b.WriteString("| State | At | Duration | Description |\n")
+	b.WriteString("|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| created | %s | | |\n", started.UTC().Format(time.RFC3339))
+	for i, status := range statuses {
+		at := status.GetCreatedAt().Time
+		duration := "—"
+		if i+1 < len(statuses) {
+			duration = statuses[i+1].GetCreatedAt().Time.Sub(at).Round(time.Second).String()
+		}
+		fmt.Fprintf(&b, "| %s %s | %s | %s | %s |\n",
+			stateEmoji(status.GetState()), status.GetState(),
+			at.UTC().Format(time.RFC3339),
+			duration,
+			escapeCell(status.GetDescription()))
+	}
+	if len(statuses) > 0 {
+		fmt.Fprintf(&b, "\n**Elapsed:** %s\n", statuses[len(statuses)-1].GetCreatedAt().Time.Sub(started).Round(time.Second))
+	}
+
+	var unplaced []CheckAnnotation
+	for _, detail := range details {
+		if detail.Path == "" {
+			unplaced = append(unplaced, detail)
+		}
+	}
+	if len(unplaced) > 0 {
+		b.WriteString("\n#### Failure details\n\n")
+		for _, detail := range unplaced {
+			if detail.Title != "" {
+				fmt.Fprintf(&b, "- **%s:** %s\n", detail.Title, detail.Message)
+			} else {
+				fmt.Fprintf(&b, "- %s\n", detail.Message)
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// latestLogURL returns the most recent log URL in the timeline, statuses without one keep the previous
+func latestLogURL(statuses []*github.DeploymentStatus) string {
+	for i := len(statuses) - 1; i >= 0; i-- {
+		if url := statuses[i].GetLogURL(); url != "" {
+			return url
+		}
+	}
+	return ""
+}
+
+// DeploymentPayload decodes a deployment's payload, empty when it isn't a payload of a known schema
+func DeploymentPayload(deployment *github.Deployment) *payload.Payload {
+	decoded, err := payload.Decode(deployment.Payload)
+	if err != nil {
+		return &payload.Payload{}
+	}
+	return decoded
+}
+
+// UpsertDeploymentCheckRun creates or updates the check run for a deployment on sha
+// The run is matched by name and the deployment ID as external ID, so redeployments get their own run
+func UpsertDeploymentCheckRun(ctx context.Context, client *github.Client, owner, repo, sha string, run DeploymentCheckRun, details []CheckAnnotation) (int64, bool, error) {
+	var annotations []*github.CheckRunAnnotation
+	for _, detail := range details {
+		if detail.Path == "" {
+			continue
+		}
+		annotations = append(annotations, checkRunAnnotation(detail))
+	}
+
+	existing, err := findCheckRun(ctx, client, owner, repo, sha, run.Name, run.ExternalID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	first, rest := splitAnnotations(annotations)
+	output := &github.CheckRunOutput{
+		Title:       github.String(run.Title),
+		Summary:     github.String(run.Summary),
+		Annotations: first,
+	}
+
+	var checkRunID int64
+	created := existing == 0
+	if created {
+		opts := github.CreateCheckRunOptions{
+			Name:       run.Name,
+			HeadSHA:    sha,
+			ExternalID: github.String(run.ExternalID),
+			Status:     github.String(run.Status),
+			Output:     output,
+		}
+		if run.DetailsURL != "" {
+			opts.DetailsURL = github.String(run.DetailsURL)
+		}
+		if run.Conclusion != "" {
+			opts.Conclusion = github.String(run.Conclusion)
+		}
+		checkRun, _, err := client.Checks.CreateCheckRun(ctx, owner, repo, opts)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to create check run %s on %s/%s@%s: %w", run.Name, owner, repo, sha, err)
+		}
+		checkRunID = checkRun.GetID()
+	} else {
+		checkRunID = existing
+		if err := updateCheckRun(ctx, client, owner, repo, checkRunID, run, output); err != nil {
+			return 0, false, err
+		}
+	}
+
+	// Remaining annotations are appended in batches
+	for len(rest) > 0 {
+		var batch []*github.CheckRunAnnotation
+		batch, rest = splitAnnotations(rest)
+		output := &github.CheckRunOutput{
+			Title:       github.String(run.Title),
+			Summary:     github.String(run.Summary),
+			Annotations: batch,
+		}
+		if err := updateCheckRun(ctx, client, owner, repo, checkRunID, run, output); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return checkRunID, created, nil
+}
+
+func updateCheckRun(ctx context.Context, client *github.Client, owner, repo string, checkRunID int64, run DeploymentCheckRun, output *github.CheckRunOutput) error {
+	opts := github.UpdateCheckRunOptions{
+		Name:       run.Name,
+		ExternalID: github.String(run.ExternalID),
+		Status:     github.String(run.Status),
+		Output:     output,
+	}
+	if run.DetailsURL != "" {
+		opts.DetailsURL = github.String(run.DetailsURL)
+	}
+	if run.Conclusion != "" {
+		opts.Conclusion = github.String(run.Conclusion)
+	}
+	if _, _, err := client.Checks.UpdateCheckRun(ctx, owner, repo, checkRunID, opts); err != nil {
+		return fmt.Errorf("failed to update check run %d on %s/%s: %w", checkRunID, owner, repo, err)
+	}
+	return nil
+}
+
+// findCheckRun returns the ID of the check run named name for the deployment, 0 when there is none
+func findCheckRun(ctx context.Context, client *github.Client, owner, repo, sha, name, externalID string) (int64, error) {
+	opts := &github.ListCheckRunsOptions{
+		CheckName:   github.String(name),
+		Filter:      github.String("all"),
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		result, resp, err := client.Checks.ListCheckRunsForRef(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list check runs for %s/%s@%s: %w", owner, repo, sha, err)
+		}
+		for _, checkRun := range result.CheckRuns {
+			if checkRun.GetExternalID() == externalID {
+				return checkRun.GetID(), nil
+			}
+		}
+		if resp.NextPage == 0 {
+			return 0, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+func checkRunAnnotation(detail CheckAnnotation) *github.CheckRunAnnotation {
+	level := detail.Level
+	if level == "" {
+		level = "failure"
+	}
+	start := detail.StartLine
+	if start < 1 {
+		start = 1
+	}
+	end := detail.EndLine
+	if end < start {
+		end = start
+	}
+	annotation := &github.CheckRunAnnotation{
+		Path:            github.String(detail.Path),
+		StartLine:       github.Int(start),
+		EndLine:         github.Int(end),
+		AnnotationLevel: github.String(level),
+		Message:         github.String(detail.Message),
+	}
+	if detail.Title != "" {
+		annotation.Title = github.String(detail.Title)
+	}
+	return annotation
+}
+
+func splitAnnotations(annotations []*github.CheckRunAnnotation) ([]*github.CheckRunAnnotation, []*github.CheckRunAnnotation) {
+	if len(annotations) <= maxAnnotationsPerRequest {
+		return annotations, nil
+	}
+	return annotations[:maxAnnotationsPerRequest], annotations[maxAnnotationsPerRequest:]
+}
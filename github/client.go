@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bradleyfalzon/ghinstallation/v2"
 	"github.com/google/go-github/v58/github"
@@ -16,211 +18,324 @@ import (
 // ClientFactory creates authenticated GitHub clients
 type ClientFactory struct {
 	config config.GitHubConfig
-	privateKey []byte
 	logger zerolog.Logger
-	// Cache for installation IDs by organization
+	// Registered hosts by name
+	hosts map[string]*host
+	// Host routing by lowercased owner
+	ownerHosts map[string]string
+	// Host used when neither the workflow nor the owner routing names one
+	defaultHost string
+	// Cache for installation IDs by host and organization
 	installationCache map[string]int64
+	// Installation permissions and repository selection by host and organization
+	capabilityCache map[string]*InstallationCapabilities
+	// Bot logins of the host Apps by host name
+	appLogins map[string]string
+	mu        sync.Mutex
+	// Conditional request cache shared by all clients (nil when disabled)
+	httpCache *HTTPCache
+}
+
+// host holds the App credentials and network setup for a single GitHub instance
+type host struct {
+	config config.GitHubHostConfig
+	signer AppSigner
+	// Private key files, nil for remote signers
+	keys      *KeySet
+	transport http.RoundTripper
 }
 
 // NewClientFactory creates a new GitHub client factory
-func NewClientFactory(cfg config.GitHubConfig, privateKey []byte, logger zerolog.Logger) *ClientFactory {
-	return &ClientFactory{
-		config: cfg,
-		privateKey: privateKey,
-		logger: logger,
+// secrets holds the App private key and TLS material for each host in cfg.Hosts
+func NewClientFactory(cfg config.GitHubConfig, secrets config.SecretsConfig, logger zerolog.Logger) (*ClientFactory, error) {
+	f := &ClientFactory{
+		config:            cfg,
+		logger:            logger,
+		hosts:             make(map[string]*host),
+		ownerHosts:        make(map[string]string),
 		installationCache: make(map[string]int64),
+		capabilityCache:   make(map[string]*InstallationCapabilities),
+		appLogins:         make(map[string]string),
+	}
+
+	if cfg.Cache.Enabled {
+		f.httpCache = NewHTTPCache(cfg.Cache.MaxEntries, cfg.Cache.MaxBytes)
+	}
+
+	for _, hostConfig := range cfg.Hosts {
+		transport, err := newHostTransport(hostConfig.Transport, secrets.GitHubHostTLS[hostConfig.Name])
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure transport for GitHub host %s: %w", hostConfig.Name, err)
+		}
+		h := &host{config: hostConfig, transport: transport}
+		hostLogger := logger.With().Str("github_host", hostConfig.Name).Logger()
+
+		switch hostConfig.Signer.Type {
+		case config.SignerSidecar:
+			h.signer = NewSidecarSigner(
+				hostConfig.Signer.URL,
+				secrets.GitHubSignerTokens[hostConfig.Name],
+				hostConfig.Signer.KeyID,
+				hostConfig.Signer.Timeout,
+				hostLogger,
+			)
+		default:
+			keys, err := NewKeySet(hostConfig.PrivateKeyPath, secrets.GitHubHostKeys[hostConfig.Name], hostLogger)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load private keys for GitHub host %s: %w", hostConfig.Name, err)
+			}
+			h.keys = keys
+			h.signer = keys
+		}
+
+		f.hosts[hostConfig.Name] = h
+		for _, owner := range hostConfig.Owners {
+			f.ownerHosts[strings.ToLower(strings.TrimSpace(owner))] = hostConfig.Name
+		}
+		if hostConfig.Default {
+			f.defaultHost = hostConfig.Name
+		}
+	}
+
+	// A single registered host serves every owner
+	if f.defaultHost == "" && len(cfg.Hosts) == 1 {
+		f.defaultHost = cfg.Hosts[0].Name
 	}
+
+	return f, nil
 }
 
-// CreateClientForOrg creates a GitHub client based on configuration
-// This is the main entry point that routes to either Enterprise or GitHub.com
+// CreateClientForOrg creates a GitHub client for an organization on its routed host
 func (f *ClientFactory) CreateClientForOrg(ctx context.Context, org string) (*github.Client, error) {
-	if f.config.EnterpriseURL != "" {
-		return f.CreateEnterpriseClientForOrg(ctx, org)
-	}
-	
-	// TODO: Remove this GitHub.com fallback when fully migrated to Enterprise
-	// START REMOVE WHEN ENTERPRISE-ONLY
-	return f.CreateGitHubComClientForOrg(ctx, org)
-	// END REMOVE WHEN ENTERPRISE-ONLY
+	return f.CreateClientForHost(ctx, "", org)
 }
 
-// CreateEnterpriseClientForOrg creates a GitHub Enterprise client for a specific organization
-func (f *ClientFactory) CreateEnterpriseClientForOrg(ctx context.Context, org string) (*github.Client, error) {
-	if f.config.EnterpriseURL == "" {
-		return nil, fmt.Errorf("GitHub Enterprise URL not configured")
+// CreateClientForHost creates a GitHub client for an organization on a specific host
+// This is the main entry point: an empty hostName falls back to owner routing and then the default host
+func (f *ClientFactory) CreateClientForHost(ctx context.Context, hostName, org string) (*github.Client, error) {
+	h, err := f.resolveHost(hostName, org)
+	if err != nil {
+		return nil, err
 	}
-	
-	// Check if we have a cached installation ID for this org
-	if installationID, exists := f.installationCache[org]; exists {
-		return f.createEnterpriseInstallationClient(installationID)
-	}
-	
-	// Create GitHub App transport to find installations
-	atr, err := ghinstallation.NewAppsTransport(
-		http.DefaultTransport,
-		f.config.AppID,
-		f.privateKey,
-	)
+
+	installationID, err := f.findInstallationID(ctx, h, org)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create app transport: %w", err)
+		return nil, err
 	}
-	
-	// Configure Enterprise base URL
-	baseURL := strings.TrimSuffix(f.config.EnterpriseURL, "/")
-	atr.BaseURL = baseURL + "/api/v3"
-	
-	// Create temporary client to list installations
-	appClient := github.NewClient(&http.Client{Transport: atr})
-	appClient.BaseURL, _ = appClient.BaseURL.Parse(baseURL + "/api/v3/")
-	appClient.UploadURL, _ = appClient.UploadURL.Parse(baseURL + "/api/uploads/")
-	
-	// Find installation for the organization
-	installations, _, err := appClient.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 100})
+
+	return f.createInstallationClient(h, installationID)
+}
+
+// ResolveHost returns the name of the host that serves the organization
+func (f *ClientFactory) ResolveHost(hostName, org string) (string, error) {
+	h, err := f.resolveHost(hostName, org)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list app installations on Enterprise: %w", err)
+		return "", err
 	}
-	
-	var targetInstallationID int64
-	for _, installation := range installations {
-		if installation.Account.GetLogin() == org {
-			targetInstallationID = installation.GetID()
-			// Cache the installation ID
-			f.installationCache[org] = targetInstallationID
-			break
+	return h.config.Name, nil
+}
+
+// resolveHost picks the host for a request: explicit name, owner routing, then default
+func (f *ClientFactory) resolveHost(hostName, org string) (*host, error) {
+	if hostName != "" {
+		h, exists := f.hosts[hostName]
+		if !exists {
+			return nil, fmt.Errorf("GitHub host '%s' is not configured", hostName)
 		}
+		return h, nil
 	}
-	
-	if targetInstallationID == 0 {
-		return nil, fmt.Errorf("no installation found for organization '%s' on Enterprise GitHub %s", org, f.config.EnterpriseURL)
+
+	if routed, exists := f.ownerHosts[strings.ToLower(org)]; exists {
+		return f.hosts[routed], nil
 	}
-	
-	f.logger.Info().
-		Int64("app_id", f.config.AppID).
-		Int64("installation_id", targetInstallationID).
-		Str("organization", org).
-		Str("enterprise_url", f.config.EnterpriseURL).
-		Msg("Found GitHub Enterprise App installation for organization")
-	
-	return f.createEnterpriseInstallationClient(targetInstallationID)
-}
-
-// createEnterpriseInstallationClient creates a client for a specific Enterprise installation ID
-func (f *ClientFactory) createEnterpriseInstallationClient(installationID int64) (*github.Client, error) {
-	// Create GitHub App installation transport
-	itr, err := ghinstallation.New(
-		http.DefaultTransport,
-		f.config.AppID,
-		installationID,
-		f.privateKey,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create installation transport: %w", err)
-	}
-	
-	// Configure Enterprise base URL
-	baseURL := strings.TrimSuffix(f.config.EnterpriseURL, "/")
-	itr.BaseURL = baseURL + "/api/v3"
-	
-	// Create the client
-	client := github.NewClient(&http.Client{Transport: itr})
-	client.BaseURL, _ = client.BaseURL.Parse(baseURL + "/api/v3/")
-	client.UploadURL, _ = client.UploadURL.Parse(baseURL + "/api/uploads/")
-	
-	f.logger.Info().
-		Int64("app_id", f.config.AppID).
-		Int64("installation_id", installationID).
-		Str("enterprise_url", f.config.EnterpriseURL).
-		Msg("GitHub Enterprise installation client created successfully")
-	
-	return client, nil
+
+	if f.defaultHost != "" {
+		return f.hosts[f.defaultHost], nil
+	}
+
+	return nil, fmt.Errorf("no GitHub host configured for organization '%s' and no default host set", org)
 }
 
-// TODO: REMOVE ALL CODE BELOW THIS LINE WHEN FULLY MIGRATED TO ENTERPRISE
-// ============================================================================
-// START REMOVE WHEN ENTERPRISE-ONLY
+// findInstallationID looks up the App installation for an organization on a host
+func (f *ClientFactory) findInstallationID(ctx context.Context, h *host, org string) (int64, error) {
+	cacheKey := h.config.Name + "/" + org
 
-// CreateGitHubComClientForOrg creates a GitHub.com client for a specific organization
-// DEPRECATED: This function will be removed when fully migrated to Enterprise
-func (f *ClientFactory) CreateGitHubComClientForOrg(ctx context.Context, org string) (*github.Client, error) {
 	// Check if we have a cached installation ID for this org
-	if installationID, exists := f.installationCache[org]; exists {
-		return f.createGitHubComInstallationClient(installationID)
-	}
-	
-	// Create GitHub App transport to find installations
-	atr, err := ghinstallation.NewAppsTransport(
-		http.DefaultTransport,
-		f.config.AppID,
-		f.privateKey,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create app transport: %w", err)
+	f.mu.Lock()
+	installationID, exists := f.installationCache[cacheKey]
+	f.mu.Unlock()
+	if exists {
+		return installationID, nil
 	}
-	
+
 	// Create temporary client to list installations
-	appClient := github.NewClient(&http.Client{Transport: atr})
-	
+	appClient, err := f.appClient(h)
+	if err != nil {
+		return 0, err
+	}
+
 	// Find installation for the organization
 	installations, _, err := appClient.Apps.ListInstallations(ctx, &github.ListOptions{PerPage: 100})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list app installations on GitHub.com: %w", err)
+		return 0, fmt.Errorf("failed to list app installations on %s: %w", h.describe(), err)
 	}
-	
-	var targetInstallationID int64
+
 	for _, installation := range installations {
 		if installation.Account.GetLogin() == org {
-			targetInstallationID = installation.GetID()
-			// Cache the installation ID
-			f.installationCache[org] = targetInstallationID
+			installationID = installation.GetID()
 			break
 		}
 	}
-	
-	if targetInstallationID == 0 {
-		return nil, fmt.Errorf("no installation found for organization '%s' on GitHub.com", org)
+
+	if installationID == 0 {
+		return 0, fmt.Errorf("no installation found for organization '%s' on %s", org, h.describe())
 	}
-	
+
+	// Cache the installation ID
+	f.mu.Lock()
+	f.installationCache[cacheKey] = installationID
+	f.mu.Unlock()
+
 	f.logger.Info().
-		Int64("app_id", f.config.AppID).
-		Int64("installation_id", targetInstallationID).
+		Str("github_host", h.config.Name).
+		Str("github_url", h.config.URL).
+		Int64("app_id", h.config.AppID).
+		Int64("installation_id", installationID).
 		Str("organization", org).
-		Str("github_type", "github.com").
-		Msg("Found GitHub.com App installation for organization")
-	
-	return f.createGitHubComInstallationClient(targetInstallationID)
-}
-
-// createGitHubComInstallationClient creates a client for a specific GitHub.com installation ID
-// DEPRECATED: This function will be removed when fully migrated to Enterprise
-func (f *ClientFactory) createGitHubComInstallationClient(installationID int64) (*github.Client, error) {
-	// Create GitHub App installation transport
-	itr, err := ghinstallation.New(
-		http.DefaultTransport,
-		f.config.AppID,
-		installationID,
-		f.privateKey,
-	)
+		Str("key_id", h.signer.KeyID()).
+		Msg("Found GitHub App installation for organization")
+
+	return installationID, nil
+}
+
+// appClient creates a client authenticated as the GitHub App itself (JWT)
+func (f *ClientFactory) appClient(h *host) (*github.Client, error) {
+	atr, err := f.appsTransport(h, "app")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create installation transport: %w", err)
+		return nil, err
 	}
-	
-	// Create the client (uses default GitHub.com URLs)
-	client := github.NewClient(&http.Client{Transport: itr})
-	
-	f.logger.Info().
-		Int64("app_id", f.config.AppID).
+
+	if h.config.IsEnterprise() {
+		return newEnterpriseClient(h.httpClient(atr), h.config.URL), nil
+	}
+	return github.NewClient(h.httpClient(atr)), nil
+}
+
+// createInstallationClient creates a client for a specific installation ID on a host
+func (f *ClientFactory) createInstallationClient(h *host, installationID int64) (*github.Client, error) {
+	itr, err := f.installationTransport(h, installationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create the client (GitHub.com uses the default URLs)
+	var client *github.Client
+	if h.config.IsEnterprise() {
+		client = newEnterpriseClient(h.httpClient(itr), h.config.URL)
+	} else {
+		client = github.NewClient(h.httpClient(itr))
+	}
+
+	f.logger.Debug().
+		Str("github_host", h.config.Name).
+		Int64("app_id", h.config.AppID).
 		Int64("installation_id", installationID).
-		Str("github_type", "github.com").
-		Msg("GitHub.com installation client created successfully")
-	
+		Msg("GitHub installation client created successfully")
+
 	return client, nil
 }
 
-// END REMOVE WHEN ENTERPRISE-ONLY
-// ============================================================================
+// installationTransport creates the GitHub App installation transport for a host
+func (f *ClientFactory) installationTransport(h *host, installationID int64) (*ghinstallation.Transport, error) {
+	atr, err := f.appsTransport(h, fmt.Sprintf("installation/%d", installationID))
+	if err != nil {
+		return nil, err
+	}
+	return ghinstallation.NewFromAppsTransport(atr, installationID), nil
+}
+
+// appsTransport creates a GitHub App (JWT) transport signing with the host's signer
+// With private key files, requests rejected with 401 are retried with the other active keys
+func (f *ClientFactory) appsTransport(h *host, scope string) (*ghinstallation.AppsTransport, error) {
+	base := f.transport(h, scope)
+	if h.keys != nil {
+		base = &keyFallbackTransport{keys: h.keys, base: base}
+	}
+	atr, err := ghinstallation.NewAppsTransportWithOptions(base, h.config.AppID, ghinstallation.WithSigner(h.signer))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create app transport for host %s: %w", h.config.Name, err)
+	}
+
+	// Configure Enterprise base URL for token requests
+	if h.config.IsEnterprise() {
+		atr.BaseURL = h.config.URL + "/api/v3"
+	}
+
+	return atr, nil
+}
+
+// WatchKeys re-reads every host's private key location at the interval until ctx is done
+// Hosts with a remote signer have no key files and are skipped
+func (f *ClientFactory) WatchKeys(ctx context.Context, interval time.Duration) {
+	for _, h := range f.hosts {
+		if h.keys == nil {
+			continue
+		}
+		go h.keys.Watch(ctx, interval)
+	}
+}
+
+// transport returns the base HTTP transport of a host for a credential scope (app or installation)
+func (f *ClientFactory) transport(h *host, scope string) http.RoundTripper {
+	if f.httpCache == nil {
+		return h.transport
+	}
+	return f.httpCache.Transport(h.transport, h.config.Name+"/"+scope)
+}
+
+// httpClient wraps an authenticating transport with the host's request timeout
+func (h *host) httpClient(transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Transport: transport,
+		Timeout:   h.config.Transport.RequestTimeout,
+	}
+}
+
+// PRCommentsEnabled reports whether deployment status comments are kept on pull requests
+func (f *ClientFactory) PRCommentsEnabled() bool {
+	return f.config.PRCommentsEnabled
+}
+
+// CheckRunsEnabled reports whether deployments are mirrored as check runs
+func (f *ClientFactory) CheckRunsEnabled() bool {
+	return f.config.CheckRunsEnabled
+}
+
+// CacheStats returns the conditional request cache metrics, zero when caching is disabled
+func (f *ClientFactory) CacheStats() CacheStats {
+	if f.httpCache == nil {
+		return CacheStats{}
+	}
+	return f.httpCache.Stats()
+}
+
+// newEnterpriseClient creates a client pointed at an Enterprise Server base URL
+func newEnterpriseClient(httpClient *http.Client, baseURL string) *github.Client {
+	client := github.NewClient(httpClient)
+	client.BaseURL, _ = client.BaseURL.Parse(baseURL + "/api/v3/")
+	client.UploadURL, _ = client.UploadURL.Parse(baseURL + "/api/uploads/")
+	return client
+}
+
+// describe returns a human readable name for log and error messages
+func (h *host) describe() string {
+	if h.config.IsEnterprise() {
+		return fmt.Sprintf("Enterprise GitHub %s (host %s)", h.config.URL, h.config.Name)
+	}
+	return fmt.Sprintf("GitHub.com (host %s)", h.config.Name)
+}
 
 // CreateClient creates a new authenticated GitHub client (DEPRECATED - use CreateClientForOrg)
 func (f *ClientFactory) CreateClient(ctx context.Context) (*github.Client, error) {
 	return nil, fmt.Errorf("CreateClient is deprecated - use CreateClientForOrg instead")
-}
\ No newline at end of file
+}
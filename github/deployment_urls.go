@@ -0,0 +1,72 @@
+package github
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-github/v58/github"
+)
+
+// DeploymentURLData is the data available to log and environment URL templates
+type DeploymentURLData struct {
+	Host               string
+	Owner              string
+	Repo               string
+	Environment        string
+	Task               string
+	DeploymentID       int64
+	SHA                string
+	ShortSHA           string
+	PR                 int // 0 when the deployment has no pull request
+	HarnessExecutionID string
+	HarnessPipelineID  string
+	HarnessStageID     string
+}
+
+// NewDeploymentURLData describes a deployment and its payload for the URL templates
+func NewDeploymentURLData(host, owner, repo string, deployment *github.Deployment) DeploymentURLData {
+	decoded := DeploymentPayload(deployment)
+	data := DeploymentURLData{
+		Host:         host,
+		Owner:        owner,
+		Repo:         repo,
+		Environment:  deployment.GetEnvironment(),
+		Task:         deployment.GetTask(),
+		DeploymentID: deployment.GetID(),
+		SHA:          deployment.GetSHA(),
+		ShortSHA:     shortCommit(deployment.GetSHA()),
+		PR:           decoded.PullRequest,
+
+		HarnessExecutionID: decoded.ExecutionID(),
+		HarnessPipelineID:  decoded.PipelineID(),
+	}
+	if decoded.Harness != nil {
+		data.HarnessStageID = decoded.Harness.StageID
+	}
+	return data
+}
+
+// RenderDeploymentURL renders a log or environment URL from a text/template
+// An empty result is returned as is, anything else must be an absolute http(s) URL
+func RenderDeploymentURL(tmpl string, data DeploymentURLData) (string, error) {
+	parsed, err := template.New("url").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := parsed.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render URL template: %w", err)
+	}
+	rendered := strings.TrimSpace(b.String())
+	if rendered == "" {
+		return "", nil
+	}
+	parsedURL, err := url.Parse(rendered)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || parsedURL.Host == "" {
+		return "", fmt.Errorf("URL template rendered invalid URL %q", rendered)
+	}
+	return rendered, nil
+}
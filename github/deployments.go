@@ -0,0 +1,33 @@
+package github
+
+import (
+	"context"
+
+	"github.com/google/go-github/v58/github"
+)
+
+// DeploymentAPI is the subset of the GitHub REST API used to track deployments
+// *github.RepositoriesService satisfies it, as do the fakes in the fake package
+type DeploymentAPI interface {
+	CreateDeployment(ctx context.Context, owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error)
+	GetDeployment(ctx context.Context, owner, repo string, deploymentID int64) (*github.Deployment, *github.Response, error)
+	ListDeployments(ctx context.Context, owner, repo string, opts *github.DeploymentsListOptions) ([]*github.Deployment, *github.Response, error)
+	DeleteDeployment(ctx context.Context, owner, repo string, deploymentID int64) (*github.Response, error)
+	CreateDeploymentStatus(ctx context.Context, owner, repo string, deploymentID int64, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error)
+	GetDeploymentStatus(ctx context.Context, owner, repo string, deploymentID, statusID int64) (*github.DeploymentStatus, *github.Response, error)
+	ListDeploymentStatuses(ctx context.Context, owner, repo string, deploymentID int64, opts *github.ListOptions) ([]*github.DeploymentStatus, *github.Response, error)
+}
+
+// DeploymentClientProvider returns a DeploymentAPI for an organization on a host
+type DeploymentClientProvider interface {
+	DeploymentClient(ctx context.Context, hostName, org string) (DeploymentAPI, error)
+}
+
+// DeploymentClient returns the deployment API of an installation client for the organization
+func (f *ClientFactory) DeploymentClient(ctx context.Context, hostName, org string) (DeploymentAPI, error) {
+	client, err := f.CreateClientForHost(ctx, hostName, org)
+	if err != nil {
+		return nil, err
+	}
+	return client.Repositories, nil
+}
@@ -0,0 +1,414 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// Branch policy types accepted by the deployment branch policies API
+const (
+	branchPolicyBranch = "branch"
+	branchPolicyTag    = "tag"
+)
+
+// EnvironmentChanges summarizes what applying an EnvironmentSpec changed, or would change in a dry run
+type EnvironmentChanges struct {
+	Created                 bool     `json:"created"`
+	SettingsUpdated         bool     `json:"settings_updated"`
+	BranchPoliciesAdded     []string `json:"branch_policies_added,omitempty"`
+	BranchPoliciesRemoved   []string `json:"branch_policies_removed,omitempty"`
+	ProtectionRulesEnabled  []int64  `json:"protection_rules_enabled,omitempty"`
+	ProtectionRulesDisabled []int64  `json:"protection_rules_disabled,omitempty"`
+}
+
+// Changed reports whether the environment drifted from its spec
+func (c *EnvironmentChanges) Changed() bool {
+	return c.Created || c.SettingsUpdated ||
+		len(c.BranchPoliciesAdded) > 0 || len(c.BranchPoliciesRemoved) > 0 ||
+		len(c.ProtectionRulesEnabled) > 0 || len(c.ProtectionRulesDisabled) > 0
+}
+
+// EnvironmentManager creates, updates and deletes repository environments
+type EnvironmentManager struct {
+	client *github.Client
+}
+
+// NewEnvironmentManager creates an environment manager for an installation client
+func NewEnvironmentManager(client *github.Client) *EnvironmentManager {
+	return &EnvironmentManager{client: client}
+}
+
+// EnvironmentManager creates an environment manager for an organization on its routed host
+func (f *ClientFactory) EnvironmentManager(ctx context.Context, hostName, org string) (*EnvironmentManager, error) {
+	client, err := f.CreateClientForHost(ctx, hostName, org)
+	if err != nil {
+		return nil, err
+	}
+	return NewEnvironmentManager(client), nil
+}
+
+// Apply converges a repository environment on its spec: protection settings,
+// deployment branch policies and custom protection rule apps
+// With dryRun set nothing is written and the returned changes describe the drift
+func (m *EnvironmentManager) Apply(ctx context.Context, owner, repo string, spec config.EnvironmentSpec, dryRun bool) (*EnvironmentChanges, error) {
+	changes := &EnvironmentChanges{}
+
+	existing, resp, err := m.client.Repositories.GetEnvironment(ctx, owner, repo, spec.Name)
+	if err != nil {
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			return nil, fmt.Errorf("failed to get environment %s for %s/%s: %w", spec.Name, owner, repo, err)
+		}
+		existing = nil
+		changes.Created = true
+	}
+
+	desired, err := m.desiredSettings(ctx, owner, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if changes.Created || !settingsMatch(existing, desired) {
+		changes.SettingsUpdated = !changes.Created
+		if !dryRun {
+			if _, _, err := m.client.Repositories.CreateUpdateEnvironment(ctx, owner, repo, spec.Name, desired); err != nil {
+				return nil, fmt.Errorf("failed to update environment %s for %s/%s: %w", spec.Name, owner, repo, err)
+			}
+		}
+	}
+
+	if spec.BranchPolicy != nil && spec.BranchPolicy.CustomPolicies() {
+		if err := m.syncBranchPolicies(ctx, owner, repo, spec, changes, dryRun); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.syncProtectionRules(ctx, owner, repo, spec, changes, dryRun); err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// Delete removes a repository environment, a missing environment is not an error
+func (m *EnvironmentManager) Delete(ctx context.Context, owner, repo, name string) (bool, error) {
+	resp, err := m.client.Repositories.DeleteEnvironment(ctx, owner, repo, name)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to delete environment %s for %s/%s: %w", name, owner, repo, err)
+	}
+	return true, nil
+}
+
+// PullRequestEnvironments returns the pull requests that still have a pr-<n> environment in the repository
+func (m *EnvironmentManager) PullRequestEnvironments(ctx context.Context, owner, repo string) ([]int, error) {
+	var numbers []int
+	opts := &github.EnvironmentListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		environments, resp, err := m.client.Repositories.ListEnvironments(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list environments for %s/%s: %w", owner, repo, err)
+		}
+		for _, environment := range environments.Environments {
+			if number := config.PRNumberFromEnvironment(environment.GetName()); number > 0 {
+				numbers = append(numbers, number)
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return numbers, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// desiredSettings builds the environment request, resolving reviewer logins and team slugs to IDs
+func (m *EnvironmentManager) desiredSettings(ctx context.Context, owner string, spec config.EnvironmentSpec) (*github.CreateUpdateEnvironment, error) {
+	desired := &github.CreateUpdateEnvironment{
+		WaitTimer:       github.Int(spec.WaitTimer),
+		CanAdminsBypass: github.Bool(true),
+		Reviewers:       []*github.EnvReviewers{},
+	}
+	if spec.CanAdminsBypass != nil {
+		desired.CanAdminsBypass = github.Bool(*spec.CanAdminsBypass)
+	}
+	if spec.PreventSelfReview {
+		desired.PreventSelfReview = github.Bool(true)
+	}
+
+	for _, login := range spec.Reviewers.Users {
+		user, _, err := m.client.Users.Get(ctx, login)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve reviewer %s: %w", login, err)
+		}
+		desired.Reviewers = append(desired.Reviewers, &github.EnvReviewers{Type: github.String("User"), ID: user.ID})
+	}
+	for _, slug := range spec.Reviewers.Teams {
+		team, _, err := m.client.Teams.GetTeamBySlug(ctx, owner, slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve reviewer team %s/%s: %w", owner, slug, err)
+		}
+		desired.Reviewers = append(desired.Reviewers, &github.EnvReviewers{Type: github.String("Team"), ID: team.ID})
+	}
+
+	if policy := spec.BranchPolicy; policy != nil {
+		desired.DeploymentBranchPolicy = &github.BranchPolicy{
+			ProtectedBranches:    github.Bool(policy.ProtectedBranches),
+			CustomBranchPolicies: github.Bool(policy.CustomPolicies()),
+		}
+	}
+
+	return desired, nil
+}
+
+// settingsMatch compares an existing environment with the desired settings
+func settingsMatch(existing *github.Environment, desired *github.CreateUpdateEnvironment) bool {
+	if existing == nil {
+		return false
+	}
+
+	if existing.GetCanAdminsBypass() != desired.GetCanAdminsBypass() {
+		return false
+	}
+
+	var waitTimer int
+	var preventSelfReview bool
+	var reviewers []string
+	for _, rule := range existing.ProtectionRules {
+		switch rule.GetType() {
+		case "wait_timer":
+			waitTimer = rule.GetWaitTimer()
+		case "required_reviewers":
+			preventSelfReview = rule.GetPreventSelfReview()
+			for _, reviewer := range rule.Reviewers {
+				reviewers = append(reviewers, reviewerKey(reviewer.GetType(), reviewerID(reviewer)))
+			}
+		}
+	}
+	if waitTimer != desired.GetWaitTimer() || preventSelfReview != desired.GetPreventSelfReview() {
+		return false
+	}
+
+	wanted := make([]string, 0, len(desired.Reviewers))
+	for _, reviewer := range desired.Reviewers {
+		wanted = append(wanted, reviewerKey(reviewer.GetType(), reviewer.GetID()))
+	}
+	if !sameStrings(reviewers, wanted) {
+		return false
+	}
+
+	current := existing.DeploymentBranchPolicy
+	wantedPolicy := desired.DeploymentBranchPolicy
+	if current == nil || wantedPolicy == nil {
+		return current == nil && wantedPolicy == nil
+	}
+	return current.GetProtectedBranches() == wantedPolicy.GetProtectedBranches() &&
+		current.GetCustomBranchPolicies() == wantedPolicy.GetCustomBranchPolicies()
+}
+
+// syncBranchPolicies adds missing branch and tag patterns and removes the ones not in the spec
+func (m *EnvironmentManager) syncBranchPolicies(ctx context.Context, owner, repo string, spec config.EnvironmentSpec, changes *EnvironmentChanges, dryRun bool) error {
+	wanted := make(map[string]*github.DeploymentBranchPolicyRequest)
+	for _, branch := range spec.BranchPolicy.Branches {
+		wanted[branchPolicyBranch+":"+branch] = &github.DeploymentBranchPolicyRequest{Name: github.String(branch), Type: github.String(branchPolicyBranch)}
+	}
+	for _, tag := range spec.BranchPolicy.Tags {
+		wanted[branchPolicyTag+":"+tag] = &github.DeploymentBranchPolicyRequest{Name: github.String(tag), Type: github.String(branchPolicyTag)}
+	}
+
+	current := make(map[string]int64)
+	if !changes.Created {
+		policies, err := m.listBranchPolicies(ctx, owner, repo, spec.Name)
+		if err != nil {
+			return err
+		}
+		for _, policy := range policies {
+			policyType := policy.GetType()
+			if policyType == "" {
+				policyType = branchPolicyBranch
+			}
+			current[policyType+":"+policy.GetName()] = policy.GetID()
+		}
+	}
+
+	for _, key := range sortedKeys(wanted) {
+		if _, exists := current[key]; exists {
+			continue
+		}
+		changes.BranchPoliciesAdded = append(changes.BranchPoliciesAdded, key)
+		if dryRun {
+			continue
+		}
+		if _, _, err := m.client.Repositories.CreateDeploymentBranchPolicy(ctx, owner, repo, spec.Name, wanted[key]); err != nil {
+			return fmt.Errorf("failed to add branch policy %s to environment %s for %s/%s: %w", key, spec.Name, owner, repo, err)
+		}
+	}
+
+	for _, key := range sortedKeys(current) {
+		if _, exists := wanted[key]; exists {
+			continue
+		}
+		changes.BranchPoliciesRemoved = append(changes.BranchPoliciesRemoved, key)
+		if dryRun {
+			continue
+		}
+		if _, err := m.client.Repositories.DeleteDeploymentBranchPolicy(ctx, owner, repo, spec.Name, current[key]); err != nil {
+			return fmt.Errorf("failed to remove branch policy %s from environment %s for %s/%s: %w", key, spec.Name, owner, repo, err)
+		}
+	}
+
+	return nil
+}
+
+// listBranchPolicies pages through an environment's branch policies
+// go-github v58 reads only the first page, which holds 30 policies by default
+func (m *EnvironmentManager) listBranchPolicies(ctx context.Context, owner, repo, environment string) ([]*github.DeploymentBranchPolicy, error) {
+	path := fmt.Sprintf("repos/%v/%v/environments/%v/deployment-branch-policies", owner, repo, url.PathEscape(environment))
+
+	var policies []*github.DeploymentBranchPolicy
+	page := 1
+	for {
+		req, err := m.client.NewRequest(http.MethodGet, fmt.Sprintf("%s?per_page=100&page=%d", path, page), nil)
+		if err != nil {
+			return nil, err
+		}
+		var list github.DeploymentBranchPolicyResponse
+		resp, err := m.client.Do(ctx, req, &list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list branch policies of environment %s for %s/%s: %w", environment, owner, repo, err)
+		}
+		policies = append(policies, list.BranchPolicies...)
+		if resp == nil || resp.NextPage == 0 {
+			return policies, nil
+		}
+		page = resp.NextPage
+	}
+}
+
+// protectionRule is a custom deployment protection rule (not modelled by go-github v58)
+type protectionRule struct {
+	ID      int64 `json:"id"`
+	Enabled bool  `json:"enabled"`
+	App     struct {
+		ID   int64  `json:"id"`
+		Slug string `json:"slug"`
+	} `json:"app"`
+}
+
+type protectionRulesResponse struct {
+	TotalCount int               `json:"total_count"`
+	Rules      []*protectionRule `json:"custom_deployment_protection_rules"`
+}
+
+// syncProtectionRules enables the custom protection rule apps in the spec and disables the rest
+func (m *EnvironmentManager) syncProtectionRules(ctx context.Context, owner, repo string, spec config.EnvironmentSpec, changes *EnvironmentChanges, dryRun bool) error {
+	path := fmt.Sprintf("repos/%v/%v/environments/%v/deployment_protection_rules", owner, repo, url.PathEscape(spec.Name))
+
+	current := make(map[int64]int64)
+	if !changes.Created {
+		req, err := m.client.NewRequest(http.MethodGet, path, nil)
+		if err != nil {
+			return err
+		}
+		var rules protectionRulesResponse
+		if _, err := m.client.Do(ctx, req, &rules); err != nil {
+			return fmt.Errorf("failed to list protection rules of environment %s for %s/%s: %w", spec.Name, owner, repo, err)
+		}
+		for _, rule := range rules.Rules {
+			current[rule.App.ID] = rule.ID
+		}
+	}
+
+	wanted := make(map[int64]bool)
+	for _, appID := range spec.ProtectionRuleApps {
+		wanted[appID] = true
+		if _, exists := current[appID]; exists {
+			continue
+		}
+		changes.ProtectionRulesEnabled = append(changes.ProtectionRulesEnabled, appID)
+		if dryRun {
+			continue
+		}
+		req, err := m.client.NewRequest(http.MethodPost, path, map[string]int64{"integration_id": appID})
+		if err != nil {
+			return err
+		}
+		if _, err := m.client.Do(ctx, req, nil); err != nil {
+			return fmt.Errorf("failed to enable protection rule app %d on environment %s for %s/%s: %w", appID, spec.Name, owner, repo, err)
+		}
+	}
+
+	for appID, ruleID := range current {
+		if wanted[appID] {
+			continue
+		}
+		changes.ProtectionRulesDisabled = append(changes.ProtectionRulesDisabled, appID)
+		if dryRun {
+			continue
+		}
+		req, err := m.client.NewRequest(http.MethodDelete, fmt.Sprintf("%s/%d", path, ruleID), nil)
+		if err != nil {
+			return err
+		}
+		if _, err := m.client.Do(ctx, req, nil); err != nil {
+			return fmt.Errorf("failed to disable protection rule app %d on environment %s for %s/%s: %w", appID, spec.Name, owner, repo, err)
+		}
+	}
+	sort.Slice(changes.ProtectionRulesDisabled, func(i, j int) bool {
+		return changes.ProtectionRulesDisabled[i] < changes.ProtectionRulesDisabled[j]
+	})
+
+	return nil
+}
+
+// reviewerID extracts the user or team ID from a required reviewer entry
+// go-github decodes the reviewer into a *User or *Team depending on its type
+func reviewerID(reviewer *github.RequiredReviewer) int64 {
+	switch r := reviewer.Reviewer.(type) {
+	case *github.User:
+		return r.GetID()
+	case *github.Team:
+		return r.GetID()
+	case map[string]interface{}:
+		if id, ok := r["id"].(float64); ok {
+			return int64(id)
+		}
+	}
+	return 0
+}
+
+func reviewerKey(reviewerType string, id int64) string {
+	return fmt.Sprintf("%s:%d", reviewerType, id)
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,240 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// existingEnvironment decodes an environment like the API returns it, reviewers as untyped JSON
+func existingEnvironment(t *testing.T, body string) *github.Environment {
+	t.Helper()
+	var environment github.Environment
+	require.NoError(t, json.Unmarshal([]byte(body), &environment))
+	return &environment
+}
+
+func TestSettingsMatch(t *testing.T) {
+	existing := `{
+		"name": "production",
+		"can_admins_bypass": false,
+		"protection_rules": [
+			{"type": "wait_timer", "wait_timer": 10},
+			{"type": "required_reviewers", "prevent_self_review": true, "reviewers": [
+				{"type": "Team", "reviewer": {"id": 20}},
+				{"type": "User", "reviewer": {"id": 1}}
+			]}
+		],
+		"deployment_branch_policy": {"protected_branches": false, "custom_branch_policies": true}
+	}`
+	desired := func() *github.CreateUpdateEnvironment {
+		return &github.CreateUpdateEnvironment{
+			WaitTimer:         github.Int(10),
+			CanAdminsBypass:   github.Bool(false),
+			PreventSelfReview: github.Bool(true),
+			Reviewers: []*github.EnvReviewers{
+				{Type: github.String("User"), ID: github.Int64(1)},
+				{Type: github.String("Team"), ID: github.Int64(20)},
+			},
+			DeploymentBranchPolicy: &github.BranchPolicy{ProtectedBranches: github.Bool(false), CustomBranchPolicies: github.Bool(true)},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		existing string
+		change   func(*github.CreateUpdateEnvironment)
+		want     bool
+	}{
+		{name: "same settings, reviewers in any order", existing: existing, want: true},
+		{name: "wait timer", existing: existing, change: func(d *github.CreateUpdateEnvironment) { d.WaitTimer = github.Int(5) }},
+		{name: "admin bypass", existing: existing, change: func(d *github.CreateUpdateEnvironment) { d.CanAdminsBypass = github.Bool(true) }},
+		{name: "self review", existing: existing, change: func(d *github.CreateUpdateEnvironment) { d.PreventSelfReview = nil }},
+		{name: "reviewer removed", existing: existing, change: func(d *github.CreateUpdateEnvironment) { d.Reviewers = d.Reviewers[:1] }},
+		{name: "reviewer type", existing: existing, change: func(d *github.CreateUpdateEnvironment) { d.Reviewers[1].Type = github.String("User") }},
+		{name: "branch policy removed", existing: existing, change: func(d *github.CreateUpdateEnvironment) { d.DeploymentBranchPolicy = nil }},
+		{
+			name:     "branch policy kind",
+			existing: existing,
+			change: func(d *github.CreateUpdateEnvironment) {
+				d.DeploymentBranchPolicy = &github.BranchPolicy{ProtectedBranches: github.Bool(true), CustomBranchPolicies: github.Bool(false)}
+			},
+		},
+		{
+			name:     "no protection",
+			existing: `{"name": "staging", "can_admins_bypass": true}`,
+			change: func(d *github.CreateUpdateEnvironment) {
+				*d = github.CreateUpdateEnvironment{WaitTimer: github.Int(0), CanAdminsBypass: github.Bool(true), Reviewers: []*github.EnvReviewers{}}
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wanted := desired()
+			if tt.change != nil {
+				tt.change(wanted)
+			}
+			assert.Equal(t, tt.want, settingsMatch(existingEnvironment(t, tt.existing), wanted))
+		})
+	}
+
+	assert.False(t, settingsMatch(nil, desired()))
+}
+
+// environmentsAPI is a fake of the environment endpoints Apply uses
+// Branch policies are served 2 per page to exercise paging
+type environmentsAPI struct {
+	mu       sync.Mutex
+	exists   bool
+	policies []map[string]interface{}
+	writes   []string
+}
+
+func (a *environmentsAPI) record(r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.writes = append(a.writes, r.Method+" "+r.URL.Path)
+}
+
+func (a *environmentsAPI) server(t *testing.T) *github.Client {
+	t.Helper()
+	const environment = "/repos/acme/web/environments/production"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+environment, func(w http.ResponseWriter, r *http.Request) {
+		if !a.exists {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{
+			"name": "production",
+			"can_admins_bypass": true,
+			"protection_rules": [{"type": "wait_timer", "wait_timer": 5}],
+			"deployment_branch_policy": {"protected_branches": false, "custom_branch_policies": true}
+		}`))
+	})
+	mux.HandleFunc("PUT "+environment, func(w http.ResponseWriter, r *http.Request) {
+		a.record(r)
+		_, _ = w.Write([]byte(`{"name": "production"}`))
+	})
+	mux.HandleFunc("GET /users/octocat", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"login": "octocat", "id": 1}`))
+	})
+	mux.HandleFunc("GET "+environment+"/deployment-branch-policies", func(w http.ResponseWriter, r *http.Request) {
+		if !a.exists {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+		page := 1
+		if r.URL.Query().Get("page") == "2" {
+			page = 2
+		}
+		start, end := (page-1)*2, page*2
+		if end >= len(a.policies) {
+			end = len(a.policies)
+		} else {
+			w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next"`)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"total_count": len(a.policies), "branch_policies": a.policies[start:end]})
+	})
+	mux.HandleFunc("POST "+environment+"/deployment-branch-policies", func(w http.ResponseWriter, r *http.Request) {
+		a.record(r)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("DELETE "+environment+"/deployment-branch-policies/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.record(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET "+environment+"/deployment_protection_rules", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"total_count": 1, "custom_deployment_protection_rules": [{"id": 10, "enabled": true, "app": {"id": 77, "slug": "other"}}]}`))
+	})
+	mux.HandleFunc("POST "+environment+"/deployment_protection_rules", func(w http.ResponseWriter, r *http.Request) {
+		a.record(r)
+		_, _ = w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("DELETE "+environment+"/deployment_protection_rules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		a.record(r)
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	return client
+}
+
+var productionSpec = config.EnvironmentSpec{
+	Name:               "production",
+	WaitTimer:          10,
+	Reviewers:          config.ReviewersSpec{Users: []string{"octocat"}},
+	BranchPolicy:       &config.BranchPolicySpec{Branches: []string{"main", "release/*"}, Tags: []string{"v*"}},
+	ProtectionRuleApps: []int64{99},
+}
+
+func TestApplyConvergesExistingEnvironment(t *testing.T) {
+	api := &environmentsAPI{
+		exists: true,
+		policies: []map[string]interface{}{
+			{"id": 1, "name": "main", "type": "branch"},
+			{"id": 2, "name": "legacy/*"},
+			{"id": 3, "name": "v*", "type": "tag"},
+		},
+	}
+	manager := NewEnvironmentManager(api.server(t))
+
+	changes, err := manager.Apply(context.Background(), "acme", "web", productionSpec, false)
+	require.NoError(t, err)
+	assert.Equal(t, &EnvironmentChanges{
+		SettingsUpdated:         true,
+		BranchPoliciesAdded:     []string{"branch:release/*"},
+		BranchPoliciesRemoved:   []string{"branch:legacy/*"},
+		ProtectionRulesEnabled:  []int64{99},
+		ProtectionRulesDisabled: []int64{77},
+	}, changes)
+	assert.Equal(t, []string{
+		"PUT /repos/acme/web/environments/production",
+		"POST /repos/acme/web/environments/production/deployment-branch-policies",
+		"DELETE /repos/acme/web/environments/production/deployment-branch-policies/2",
+		"POST /repos/acme/web/environments/production/deployment_protection_rules",
+		"DELETE /repos/acme/web/environments/production/deployment_protection_rules/10",
+	}, api.writes)
+}
+
+func TestApplyDryRunWritesNothing(t *testing.T) {
+	api := &environmentsAPI{exists: true, policies: []map[string]interface{}{{"id": 1, "name": "main", "type": "branch"}}}
+	manager := NewEnvironmentManager(api.server(t))
+
+	changes, err := manager.Apply(context.Background(), "acme", "web", productionSpec, true)
+	require.NoError(t, err)
+	assert.True(t, changes.Changed())
+	assert.Equal(t, []string{"branch:release/*", "tag:v*"}, changes.BranchPoliciesAdded)
+	assert.Empty(t, api.writes)
+}
+
+func TestApplyCreatesMissingEnvironment(t *testing.T) {
+	api := &environmentsAPI{}
+	manager := NewEnvironmentManager(api.server(t))
+
+	changes, err := manager.Apply(context.Background(), "acme", "web", productionSpec, false)
+	require.NoError(t, err)
+	assert.True(t, changes.Created)
+	assert.False(t, changes.SettingsUpdated)
+	assert.Equal(t, []string{"branch:main", "branch:release/*", "tag:v*"}, changes.BranchPoliciesAdded)
+	assert.Empty(t, changes.BranchPoliciesRemoved)
+	assert.Equal(t, []int64{99}, changes.ProtectionRulesEnabled)
+	assert.Empty(t, changes.ProtectionRulesDisabled)
+	assert.Len(t, api.writes, 5)
+}
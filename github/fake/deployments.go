@@ -0,0 +1,486 @@
+// Package fake provides in-memory stand-ins for the GitHub deployments API
+// so activities and tools can be exercised without a real GitHub instance
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+)
+
+// validStates lists the deployment status states GitHub accepts
+var validStates = map[string]bool{
+	"error":       true,
+	"failure":     true,
+	"inactive":    true,
+	"in_progress": true,
+	"queued":      true,
+	"pending":     true,
+	"success":     true,
+}
+
+// Deployments is an in-memory implementation of githubClient.DeploymentAPI
+// It models the GitHub semantics the tracker relies on: newest-first listing,
+// list filters, pagination and auto-inactive status propagation
+type Deployments struct {
+	mu     sync.Mutex
+	nextID int64
+	repos  map[string][]*deploymentRecord
+
+	// BaseURL is used to build the url fields of returned objects
+	BaseURL string
+
+	// Creator is reported as the creator of deployments and statuses
+	Creator *github.User
+
+	// Now returns the current time, override for deterministic timestamps
+	Now func() time.Time
+
+	// Permissions granted to the installation by name, nil grants everything
+	Permissions map[string]string
+
+	// Commits branches and tags point at, a ref without an entry is its own SHA
+	Refs map[string]string
+}
+
+// deploymentRecord holds a deployment and its statuses (oldest first)
+type deploymentRecord struct {
+	deployment *github.Deployment
+	transient  bool
+	production bool
+	statuses   []*github.DeploymentStatus
+}
+
+// Compile-time interface checks
+var (
+	_ githubClient.DeploymentAPI            = (*Deployments)(nil)
+	_ githubClient.DeploymentClientProvider = (*Deployments)(nil)
+	_ githubClient.PermissionChecker        = (*Deployments)(nil)
+)
+
+// NewDeployments creates an empty in-memory deployments store
+func NewDeployments() *Deployments {
+	return &Deployments{
+		repos:   make(map[string][]*deploymentRecord),
+		BaseURL: "https://api.github.com",
+		Creator: &github.User{Login: github.String("gh-deploy-wf[bot]"), Type: github.String("Bot")},
+		Now:     time.Now,
+	}
+}
+
+// DeploymentClient returns the store itself for every host and organization
+func (d *Deployments) DeploymentClient(ctx context.Context, hostName, org string) (githubClient.DeploymentAPI, error) {
+	return d, nil
+}
+
+// CheckPermissions checks required against Permissions, like ClientFactory.CheckPermissions
+func (d *Deployments) CheckPermissions(ctx context.Context, hostName, org, repo string, required ...githubClient.Permission) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.Permissions == nil {
+		return nil
+	}
+	capabilities := &githubClient.InstallationCapabilities{
+		Host:        hostName,
+		Owner:       org,
+		Permissions: d.Permissions,
+	}
+	missing := capabilities.Missing(required...)
+	if len(missing) == 0 {
+		return nil
+	}
+	granted := make(map[string]string)
+	for _, p := range missing {
+		granted[p.Name] = d.Permissions[p.Name]
+	}
+	return &githubClient.PermissionError{Host: hostName, Owner: org, Repo: repo, Missing: missing, Granted: granted}
+}
+
+// CreateDeployment records a new deployment
+func (d *Deployments) CreateDeployment(ctx context.Context, owner, repo string, request *github.DeploymentRequest) (*github.Deployment, *github.Response, error) {
+	if request == nil || request.GetRef() == "" {
+		return nil, response(http.StatusUnprocessableEntity, 0), errorResponse(http.StatusUnprocessableEntity, "ref is required")
+	}
+
+	payload := json.RawMessage("{}")
+	if request.Payload != nil {
+		data, err := json.Marshal(request.Payload)
+		if err != nil {
+			return nil, response(http.StatusUnprocessableEntity, 0), errorResponse(http.StatusUnprocessableEntity, "payload must be valid JSON")
+		}
+		payload = data
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.nextID++
+	now := github.Timestamp{Time: d.Now().UTC()}
+	environment := request.GetEnvironment()
+	if environment == "" {
+		environment = "production"
+	}
+	task := request.GetTask()
+	if task == "" {
+		task = "deploy"
+	}
+	sha := request.GetRef()
+	if commit, ok := d.Refs[sha]; ok {
+		sha = commit
+	}
+	repoURL := fmt.Sprintf("%s/repos/%s/%s", d.BaseURL, owner, repo)
+	deploymentURL := fmt.Sprintf("%s/deployments/%d", repoURL, d.nextID)
+
+	deployment := &github.Deployment{
+		ID:            github.Int64(d.nextID),
+		NodeID:        github.String(fmt.Sprintf("DE_%d", d.nextID)),
+		URL:           github.String(deploymentURL),
+		SHA:           github.String(sha),
+		Ref:           github.String(request.GetRef()),
+		Task:          github.String(task),
+		Payload:       payload,
+		Environment:   github.String(environment),
+		Description:   github.String(request.GetDescription()),
+		Creator:       d.Creator,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+		StatusesURL:   github.String(deploymentURL + "/statuses"),
+		RepositoryURL: github.String(repoURL),
+	}
+
+	production := environment == "production"
+	if request.ProductionEnvironment != nil {
+		production = request.GetProductionEnvironment()
+	}
+
+	key := repoKey(owner, repo)
+	d.repos[key] = append(d.repos[key], &deploymentRecord{
+		deployment: deployment,
+		transient:  request.GetTransientEnvironment(),
+		production: production,
+	})
+
+	return copyDeployment(deployment), response(http.StatusCreated, 0), nil
+}
+
+// GetDeployment returns a single deployment
+func (d *Deployments) GetDeployment(ctx context.Context, owner, repo string, deploymentID int64) (*github.Deployment, *github.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	record := d.find(owner, repo, deploymentID)
+	if record == nil {
+		return nil, response(http.StatusNotFound, 0), errorResponse(http.StatusNotFound, "Not Found")
+	}
+	return copyDeployment(record.deployment), response(http.StatusOK, 0), nil
+}
+
+// ListDeployments returns deployments newest first, filtered like the REST API
+func (d *Deployments) ListDeployments(ctx context.Context, owner, repo string, opts *github.DeploymentsListOptions) ([]*github.Deployment, *github.Response, error) {
+	if opts == nil {
+		opts = &github.DeploymentsListOptions{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var matched []*github.Deployment
+	records := d.repos[repoKey(owner, repo)]
+	for i := len(records) - 1; i >= 0; i-- {
+		deployment := records[i].deployment
+		if opts.SHA != "" && deployment.GetSHA() != opts.SHA {
+			continue
+		}
+		if opts.Ref != "" && deployment.GetRef() != opts.Ref {
+			continue
+		}
+		if opts.Task != "" && deployment.GetTask() != opts.Task {
+			continue
+		}
+		if opts.Environment != "" && deployment.GetEnvironment() != opts.Environment {
+			continue
+		}
+		matched = append(matched, copyDeployment(deployment))
+	}
+
+	page, nextPage := paginate(len(matched), opts.ListOptions)
+	return matched[page.start:page.end], response(http.StatusOK, nextPage), nil
+}
+
+// DeleteDeployment removes a deployment, GitHub only allows this for inactive deployments
+func (d *Deployments) DeleteDeployment(ctx context.Context, owner, repo string, deploymentID int64) (*github.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := repoKey(owner, repo)
+	for i, record := range d.repos[key] {
+		if record.deployment.GetID() != deploymentID {
+			continue
+		}
+		if latestState(record) != "inactive" && d.inEnvironment(key, record.deployment.GetEnvironment()) > 1 {
+			return response(http.StatusUnprocessableEntity, 0), errorResponse(http.StatusUnprocessableEntity,
+				"We cannot delete an active deployment unless it is the only deployment in a given environment.")
+		}
+		d.repos[key] = append(d.repos[key][:i], d.repos[key][i+1:]...)
+		return response(http.StatusNoContent, 0), nil
+	}
+
+	return response(http.StatusNotFound, 0), errorResponse(http.StatusNotFound, "Not Found")
+}
+
+// inEnvironment counts a repository's deployments to an environment
+func (d *Deployments) inEnvironment(key, environment string) int {
+	count := 0
+	for _, record := range d.repos[key] {
+		if record.deployment.GetEnvironment() == environment {
+			count++
+		}
+	}
+	return count
+}
+
+// CreateDeploymentStatus appends a status and applies auto-inactive to older deployments
+func (d *Deployments) CreateDeploymentStatus(ctx context.Context, owner, repo string, deploymentID int64, request *github.DeploymentStatusRequest) (*github.DeploymentStatus, *github.Response, error) {
+	if request == nil || !validStates[request.GetState()] {
+		return nil, response(http.StatusUnprocessableEntity, 0), errorResponse(http.StatusUnprocessableEntity,
+			fmt.Sprintf("state %q is not a valid deployment state", request.GetState()))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	record := d.find(owner, repo, deploymentID)
+	if record == nil {
+		return nil, response(http.StatusNotFound, 0), errorResponse(http.StatusNotFound, "Not Found")
+	}
+
+	if request.Environment != nil {
+		record.deployment.Environment = github.String(request.GetEnvironment())
+	}
+
+	status := d.appendStatus(record, request.GetState(), request.GetDescription())
+	if request.LogURL != nil {
+		status.LogURL = github.String(request.GetLogURL())
+		status.TargetURL = github.String(request.GetLogURL())
+	}
+	if request.EnvironmentURL != nil {
+		status.EnvironmentURL = github.String(request.GetEnvironmentURL())
+	}
+
+	// auto_inactive defaults to true on GitHub
+	autoInactive := request.AutoInactive == nil || request.GetAutoInactive()
+	if autoInactive && status.GetState() == "success" {
+		d.deactivatePrevious(owner, repo, record)
+	}
+
+	return copyStatus(status), response(http.StatusCreated, 0), nil
+}
+
+// GetDeploymentStatus returns a single deployment status
+func (d *Deployments) GetDeploymentStatus(ctx context.Context, owner, repo string, deploymentID, statusID int64) (*github.DeploymentStatus, *github.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	record := d.find(owner, repo, deploymentID)
+	if record == nil {
+		return nil, response(http.StatusNotFound, 0), errorResponse(http.StatusNotFound, "Not Found")
+	}
+	for _, status := range record.statuses {
+		if status.GetID() == statusID {
+			return copyStatus(status), response(http.StatusOK, 0), nil
+		}
+	}
+	return nil, response(http.StatusNotFound, 0), errorResponse(http.StatusNotFound, "Not Found")
+}
+
+// ListDeploymentStatuses returns the statuses of a deployment newest first
+func (d *Deployments) ListDeploymentStatuses(ctx context.Context, owner, repo string, deploymentID int64, opts *github.ListOptions) ([]*github.DeploymentStatus, *github.Response, error) {
+	if opts == nil {
+		opts = &github.ListOptions{}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	record := d.find(owner, repo, deploymentID)
+	if record == nil {
+		return nil, response(http.StatusNotFound, 0), errorResponse(http.StatusNotFound, "Not Found")
+	}
+
+	statuses := make([]*github.DeploymentStatus, 0, len(record.statuses))
+	for i := len(record.statuses) - 1; i >= 0; i-- {
+		statuses = append(statuses, copyStatus(record.statuses[i]))
+	}
+
+	page, nextPage := paginate(len(statuses), *opts)
+	return statuses[page.start:page.end], response(http.StatusOK, nextPage), nil
+}
+
+// Statuses returns the state history of a deployment oldest first, for assertions
+func (d *Deployments) Statuses(owner, repo string, deploymentID int64) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	record := d.find(owner, repo, deploymentID)
+	if record == nil {
+		return nil
+	}
+	states := make([]string, 0, len(record.statuses))
+	for _, status := range record.statuses {
+		states = append(states, status.GetState())
+	}
+	return states
+}
+
+// Reset removes all deployments and statuses
+func (d *Deployments) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.repos = make(map[string][]*deploymentRecord)
+	d.nextID = 0
+}
+
+// deactivatePrevious mirrors GitHub's auto_inactive: prior successful deployments
+// of the same environment that are neither transient nor production become inactive
+func (d *Deployments) deactivatePrevious(owner, repo string, current *deploymentRecord) {
+	for _, record := range d.repos[repoKey(owner, repo)] {
+		if record == current || record.transient || record.production {
+			continue
+		}
+		if record.deployment.GetEnvironment() != current.deployment.GetEnvironment() {
+			continue
+		}
+		if record.deployment.GetID() > current.deployment.GetID() {
+			continue
+		}
+		if latestState(record) != "success" {
+			continue
+		}
+		d.appendStatus(record, "inactive", "")
+	}
+}
+
+// appendStatus adds a status to a deployment, the caller must hold the lock
+func (d *Deployments) appendStatus(record *deploymentRecord, state, description string) *github.DeploymentStatus {
+	d.nextID++
+	now := github.Timestamp{Time: d.Now().UTC()}
+	status := &github.DeploymentStatus{
+		ID:            github.Int64(d.nextID),
+		NodeID:        github.String(fmt.Sprintf("DES_%d", d.nextID)),
+		State:         github.String(state),
+		Description:   github.String(description),
+		Environment:   github.String(record.deployment.GetEnvironment()),
+		Creator:       d.Creator,
+		CreatedAt:     &now,
+		UpdatedAt:     &now,
+		DeploymentURL: github.String(record.deployment.GetURL()),
+		RepositoryURL: github.String(record.deployment.GetRepositoryURL()),
+		URL:           github.String(fmt.Sprintf("%s/%d", record.deployment.GetStatusesURL(), d.nextID)),
+	}
+	record.statuses = append(record.statuses, status)
+	record.deployment.UpdatedAt = &now
+	return status
+}
+
+// find returns the record for a deployment, the caller must hold the lock
+func (d *Deployments) find(owner, repo string, deploymentID int64) *deploymentRecord {
+	for _, record := range d.repos[repoKey(owner, repo)] {
+		if record.deployment.GetID() == deploymentID {
+			return record
+		}
+	}
+	return nil
+}
+
+// latestState returns the state of the newest status, or empty when there is none
+func latestState(record *deploymentRecord) string {
+	if len(record.statuses) == 0 {
+		return ""
+	}
+	return record.statuses[len(record.statuses)-1].GetState()
+}
+
+func repoKey(owner, repo string) string {
+	return owner + "/" + repo
+}
+
+// pageBounds is the slice window for a page of results
+type pageBounds struct {
+	start int
+	end   int
+}
+
+// paginate computes the window for a page and the next page number (0 when last)
+func paginate(total int, opts github.ListOptions) (pageBounds, int) {
+	perPage := opts.PerPage
+	if perPage <= 0 {
+		perPage = 30
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	page := opts.Page
+	if page <= 0 {
+		page = 1
+	}
+
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	nextPage := 0
+	if end < total {
+		nextPage = page + 1
+	}
+	return pageBounds{start: start, end: end}, nextPage
+}
+
+// response builds a go-github response with the given status code
+func response(statusCode int, nextPage int) *github.Response {
+	return &github.Response{
+		Response: &http.Response{
+			StatusCode: statusCode,
+			Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+			Header:     make(http.Header),
+		},
+		NextPage: nextPage,
+	}
+}
+
+// errorResponse builds the error go-github returns for a failed API call
+func errorResponse(statusCode int, message string) error {
+	return &github.ErrorResponse{
+		Response: &http.Response{
+			StatusCode: statusCode,
+			Status:     fmt.Sprintf("%d %s", statusCode, http.StatusText(statusCode)),
+		},
+		Message: message,
+	}
+}
+
+// copyDeployment returns a shallow copy so callers cannot mutate stored state
+func copyDeployment(deployment *github.Deployment) *github.Deployment {
+	c := *deployment
+	c.Payload = append(json.RawMessage(nil), deployment.Payload...)
+	return &c
+}
+
+// copyStatus returns a shallow copy so callers cannot mutate stored state
+func copyStatus(status *github.DeploymentStatus) *github.DeploymentStatus {
+	c := *status
+	return &c
+}
@@ -0,0 +1,100 @@
+package fake
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+)
+
+func createDeployment(t *testing.T, d *Deployments, environment, state string) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	deployment, _, err := d.CreateDeployment(ctx, "acme", "web", &github.DeploymentRequest{
+		Ref:         github.String("main"),
+		Environment: github.String(environment),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "" {
+		if _, _, err := d.CreateDeploymentStatus(ctx, "acme", "web", deployment.GetID(), &github.DeploymentStatusRequest{State: github.String(state)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return deployment.GetID()
+}
+
+func TestListDeploymentsNewestFirstWithPagination(t *testing.T) {
+	d := NewDeployments()
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		ids = append(ids, createDeployment(t, d, "staging", ""))
+	}
+	createDeployment(t, d, "production", "")
+
+	opts := &github.DeploymentsListOptions{Environment: "staging", ListOptions: github.ListOptions{PerPage: 2}}
+	first, resp, err := d.ListDeployments(context.Background(), "acme", "web", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first) != 2 || first[0].GetID() != ids[2] || first[1].GetID() != ids[1] || resp.NextPage != 2 {
+		t.Fatalf("first page = %v, next page %d", first, resp.NextPage)
+	}
+
+	opts.Page = resp.NextPage
+	second, resp, err := d.ListDeployments(context.Background(), "acme", "web", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second) != 1 || second[0].GetID() != ids[0] || resp.NextPage != 0 {
+		t.Fatalf("second page = %v, next page %d", second, resp.NextPage)
+	}
+}
+
+func TestDeleteDeploymentOnlyInEnvironment(t *testing.T) {
+	d := NewDeployments()
+	createDeployment(t, d, "staging", "success")
+	preview := createDeployment(t, d, "pr-7", "success")
+
+	resp, err := d.DeleteDeployment(context.Background(), "acme", "web", preview)
+	if err != nil || resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("deleting the only deployment of pr-7 = %v, want 204", err)
+	}
+}
+
+func TestDeleteDeploymentActiveWithOthersInEnvironment(t *testing.T) {
+	d := NewDeployments()
+	createDeployment(t, d, "pr-7", "failure")
+	active := createDeployment(t, d, "pr-7", "success")
+
+	resp, err := d.DeleteDeployment(context.Background(), "acme", "web", active)
+	if err == nil || resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("deleting an active deployment = %v, want 422", err)
+	}
+
+	if _, _, err := d.CreateDeploymentStatus(context.Background(), "acme", "web", active, &github.DeploymentStatusRequest{State: github.String("inactive")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.DeleteDeployment(context.Background(), "acme", "web", active); err != nil {
+		t.Fatalf("deleting an inactive deployment: %v", err)
+	}
+}
+
+func TestAutoInactiveReplacesPreviousSuccess(t *testing.T) {
+	d := NewDeployments()
+	first := createDeployment(t, d, "staging", "success")
+	createDeployment(t, d, "staging", "success")
+	production := createDeployment(t, d, "production", "success")
+	createDeployment(t, d, "production", "success")
+
+	if got := d.Statuses("acme", "web", first); !reflect.DeepEqual(got, []string{"success", "inactive"}) {
+		t.Errorf("replaced staging deployment statuses = %v", got)
+	}
+	if got := d.Statuses("acme", "web", production); !reflect.DeepEqual(got, []string{"success"}) {
+		t.Errorf("replaced production deployment statuses = %v", got)
+	}
+}
@@ -0,0 +1,316 @@
+package fake
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+)
+
+// Server is an httptest-based fake of the GitHub REST deployments endpoints
+// Requests are served from an in-memory Deployments store and every response
+// carries X-RateLimit-* headers so clients can exercise rate limit handling
+// GET responses carry an ETag; a matching If-None-Match gets a 304 that,
+// like on GitHub, does not count against the rate limit
+type Server struct {
+	*httptest.Server
+
+	// Deployments backs the REST endpoints and can be inspected directly
+	Deployments *Deployments
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time
+}
+
+// NewServer starts a fake GitHub REST server with a 5000 request hourly limit
+func NewServer() *Server {
+	s := &Server{
+		Deployments: NewDeployments(),
+		limit:       5000,
+		remaining:   5000,
+		reset:       time.Now().Add(time.Hour),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/{owner}/{repo}/deployments", s.listDeployments)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/deployments", s.createDeployment)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/deployments/{id}", s.getDeployment)
+	mux.HandleFunc("DELETE /repos/{owner}/{repo}/deployments/{id}", s.deleteDeployment)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/deployments/{id}/statuses", s.listStatuses)
+	mux.HandleFunc("POST /repos/{owner}/{repo}/deployments/{id}/statuses", s.createStatus)
+	mux.HandleFunc("GET /repos/{owner}/{repo}/deployments/{id}/statuses/{statusID}", s.getStatus)
+	mux.HandleFunc("GET /rate_limit", s.rateLimit)
+
+	s.Server = httptest.NewServer(s.withRateLimit(mux))
+	s.Deployments.BaseURL = s.URL
+
+	return s
+}
+
+// GitHubClient returns a go-github client pointed at the fake server
+func (s *Server) GitHubClient() *github.Client {
+	client := github.NewClient(s.Client())
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	client.UploadURL, _ = url.Parse(s.URL + "/uploads/")
+	return client
+}
+
+// SetRateLimit sets the request budget for the current window
+// Once remaining reaches zero requests fail with GitHub's 403 rate limit response
+func (s *Server) SetRateLimit(limit, remaining int, reset time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limit = limit
+	s.remaining = remaining
+	s.reset = reset
+}
+
+// withRateLimit decrements the request budget and writes the rate limit headers
+func (s *Server) withRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		if time.Now().After(s.reset) {
+			s.remaining = s.limit
+			s.reset = time.Now().Add(time.Hour)
+		}
+		exhausted := s.remaining <= 0
+		if !exhausted && r.URL.Path != "/rate_limit" {
+			s.remaining--
+		}
+		header := w.Header()
+		header.Set("X-RateLimit-Limit", strconv.Itoa(s.limit))
+		header.Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+		header.Set("X-RateLimit-Used", strconv.Itoa(s.limit-s.remaining))
+		header.Set("X-RateLimit-Reset", strconv.FormatInt(s.reset.Unix(), 10))
+		header.Set("X-RateLimit-Resource", "core")
+		s.mu.Unlock()
+
+		if exhausted && r.URL.Path != "/rate_limit" {
+			writeJSON(w, http.StatusForbidden, map[string]string{
+				"message":           "API rate limit exceeded for installation.",
+				"documentation_url": "https://docs.github.com/rest/overview/resources-in-the-rest-api#rate-limiting",
+			})
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) listDeployments(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	opts := &github.DeploymentsListOptions{
+		SHA:         query.Get("sha"),
+		Ref:         query.Get("ref"),
+		Task:        query.Get("task"),
+		Environment: query.Get("environment"),
+		ListOptions: listOptions(r),
+	}
+
+	deployments, resp, err := s.Deployments.ListDeployments(r.Context(), r.PathValue("owner"), r.PathValue("repo"), opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setLinkHeader(w, r, resp.NextPage)
+	s.writeCacheable(w, r, deployments)
+}
+
+func (s *Server) createDeployment(w http.ResponseWriter, r *http.Request) {
+	var request github.DeploymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Problems parsing JSON"})
+		return
+	}
+
+	deployment, _, err := s.Deployments.CreateDeployment(r.Context(), r.PathValue("owner"), r.PathValue("repo"), &request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, deployment)
+}
+
+func (s *Server) getDeployment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	deployment, _, err := s.Deployments.GetDeployment(r.Context(), r.PathValue("owner"), r.PathValue("repo"), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeCacheable(w, r, deployment)
+}
+
+func (s *Server) deleteDeployment(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	if _, err := s.Deployments.DeleteDeployment(r.Context(), r.PathValue("owner"), r.PathValue("repo"), id); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listStatuses(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	opts := listOptions(r)
+	statuses, resp, err := s.Deployments.ListDeploymentStatuses(r.Context(), r.PathValue("owner"), r.PathValue("repo"), id, &opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	setLinkHeader(w, r, resp.NextPage)
+	s.writeCacheable(w, r, statuses)
+}
+
+func (s *Server) createStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	var request github.DeploymentStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Problems parsing JSON"})
+		return
+	}
+
+	status, _, err := s.Deployments.CreateDeploymentStatus(r.Context(), r.PathValue("owner"), r.PathValue("repo"), id, &request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, status)
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	statusID, ok := pathID(w, r, "statusID")
+	if !ok {
+		return
+	}
+
+	status, _, err := s.Deployments.GetDeploymentStatus(r.Context(), r.PathValue("owner"), r.PathValue("repo"), id, statusID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	s.writeCacheable(w, r, status)
+}
+
+func (s *Server) rateLimit(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	core := map[string]int64{
+		"limit":     int64(s.limit),
+		"remaining": int64(s.remaining),
+		"used":      int64(s.limit - s.remaining),
+		"reset":     s.reset.Unix(),
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"resources": map[string]interface{}{"core": core},
+		"rate":      core,
+	})
+}
+
+// writeCacheable writes a GET response with an ETag, answering 304 when the client's copy is current
+func (s *Server) writeCacheable(w http.ResponseWriter, r *http.Request, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		return
+	}
+	sum := sha1.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		// Refund the request, conditional hits are free on GitHub
+		s.mu.Lock()
+		if s.remaining < s.limit {
+			s.remaining++
+		}
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(s.remaining))
+		w.Header().Set("X-RateLimit-Used", strconv.Itoa(s.limit-s.remaining))
+		s.mu.Unlock()
+
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
+
+// listOptions parses the page and per_page query parameters
+func listOptions(r *http.Request) github.ListOptions {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	return github.ListOptions{Page: page, PerPage: perPage}
+}
+
+// setLinkHeader writes the rel="next" Link header go-github uses for NextPage
+func setLinkHeader(w http.ResponseWriter, r *http.Request, nextPage int) {
+	if nextPage == 0 {
+		return
+	}
+	next := *r.URL
+	query := next.Query()
+	query.Set("page", strconv.Itoa(nextPage))
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<http://%s%s>; rel="next"`, r.Host, next.RequestURI()))
+}
+
+// pathID parses a numeric path value, writing a 404 when it is malformed
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+		return 0, false
+	}
+	return id, true
+}
+
+// writeError converts a store error into a GitHub error response
+func writeError(w http.ResponseWriter, err error) {
+	var errorResponse *github.ErrorResponse
+	if errors.As(err, &errorResponse) {
+		writeJSON(w, errorResponse.Response.StatusCode, map[string]string{"message": errorResponse.Message})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
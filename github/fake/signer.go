@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+)
+
+// SignerServer is an httptest-based fake of the JWT signing sidecar protocol
+// POST /v1/sign takes {"algorithm":"RS256","digest":"<base64 sha256>"} and
+// answers {"signature":"<base64>","key_id":"..."}
+type SignerServer struct {
+	*httptest.Server
+
+	// Key signs every digest; its public half verifies the issued JWTs
+	Key *rsa.PrivateKey
+	// KeyID is reported with each signature
+	KeyID string
+	// Token, when set, is required as the bearer token
+	Token string
+
+	requests atomic.Int64
+}
+
+// NewSignerServer starts a fake signing sidecar, generating a 2048 bit key when key is nil
+func NewSignerServer(key *rsa.PrivateKey) (*SignerServer, error) {
+	if key == nil {
+		generated, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		key = generated
+	}
+
+	s := &SignerServer{Key: key, KeyID: "fake-signer"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/sign", s.sign)
+	s.Server = httptest.NewServer(mux)
+
+	return s, nil
+}
+
+// PublicKey returns the public key GitHub would hold for the App
+func (s *SignerServer) PublicKey() *rsa.PublicKey {
+	return &s.Key.PublicKey
+}
+
+// Requests returns the number of signing requests served
+func (s *SignerServer) Requests() int64 {
+	return s.requests.Load()
+}
+
+func (s *SignerServer) sign(w http.ResponseWriter, r *http.Request) {
+	s.requests.Add(1)
+
+	if s.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.Token {
+		http.Error(w, "invalid signer token", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Algorithm string `json:"algorithm"`
+		Digest    string `json:"digest"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Algorithm != "RS256" {
+		http.Error(w, "unsupported algorithm "+req.Algorithm, http.StatusBadRequest)
+		return
+	}
+	digest, err := base64.StdEncoding.DecodeString(req.Digest)
+	if err != nil || len(digest) != sha256.Size {
+		http.Error(w, "digest must be a base64 SHA-256 hash", http.StatusBadRequest)
+		return
+	}
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.Key, crypto.SHA256, digest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"signature": base64.StdEncoding.EncodeToString(signature),
+		"key_id":    s.KeyID,
+	})
+}
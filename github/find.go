@@ -0,0 +1,253 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// ErrDeploymentNotFound is returned when no deployment matches a query
+var ErrDeploymentNotFound = errors.New("no matching deployment found")
+
+// DeploymentQuery selects the deployment a status update belongs to
+type DeploymentQuery struct {
+	SHA         string
+	Environment string
+
+	// Deployment task, e.g. deploy:migrations, empty matches the application tasks (see config.IsApplicationTask)
+	Task string
+
+	// Creator login, e.g. my-app[bot], empty matches any creator
+	CreatorLogin string
+
+	// Payload values that must match by payload.Value name, e.g. harness.execution_id
+	Correlation map[string]string
+}
+
+// String describes the query for errors and logs
+func (q DeploymentQuery) String() string {
+	parts := []string{fmt.Sprintf("%s in %s", q.SHA, q.Environment)}
+	if q.Task != "" {
+		parts = append(parts, "task "+q.Task)
+	}
+	if q.CreatorLogin != "" {
+		parts = append(parts, "created by "+q.CreatorLogin)
+	}
+	for _, key := range sortedKeys(q.Correlation) {
+		parts = append(parts, fmt.Sprintf("%s=%s", key, q.Correlation[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// AmbiguousDeploymentError reports several in-flight deployments matching a query
+// Add correlation keys (e.g. harness_execution_id) to tell redeployments of the same commit apart
+type AmbiguousDeploymentError struct {
+	Query      DeploymentQuery
+	Candidates []int64
+}
+
+func (e *AmbiguousDeploymentError) Error() string {
+	const shown = 10
+	var ids []string
+	for i, id := range e.Candidates {
+		if i == shown {
+			ids = append(ids, fmt.Sprintf("and %d more", len(e.Candidates)-shown))
+			break
+		}
+		ids = append(ids, fmt.Sprint(id))
+	}
+	return fmt.Sprintf("%d deployments match %s: %s", len(e.Candidates), e.Query, strings.Join(ids, ", "))
+}
+
+// AppLoginResolver resolves the bot login of a GitHub App, the creator of the deployments it creates
+type AppLoginResolver interface {
+	AppLogin(ctx context.Context, hostName, org string, appID int64) (string, error)
+}
+
+// FindDeployment returns the single deployment matching the query
+// Every page is searched and a single in-flight deployment (pending, queued, in_progress) wins,
+// otherwise the newest finished one does, inactive ones only match when nothing else does,
+// and several in-flight matches return an *AmbiguousDeploymentError instead of a guess
+func FindDeployment(ctx context.Context, client DeploymentAPI, owner, repo string, query DeploymentQuery) (*github.Deployment, error) {
+	opts := &github.DeploymentsListOptions{
+		SHA:         query.SHA,
+		Environment: query.Environment,
+		Task:        query.Task,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var matched []*github.Deployment
+	for {
+		deployments, resp, err := client.ListDeployments(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments for %s/%s@%s in %s environment: %w",
+				owner, repo, query.SHA, query.Environment, err)
+		}
+		for _, deployment := range deployments {
+			if query.matches(deployment) {
+				matched = append(matched, deployment)
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%w for %s/%s: %s", ErrDeploymentNotFound, owner, repo, query)
+	}
+	if len(matched) == 1 {
+		return matched[0], nil
+	}
+
+	// Newest first, so superseded deployments lose to the ones that replaced them
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i].GetCreatedAt().Time, matched[j].GetCreatedAt().Time
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return matched[i].GetID() > matched[j].GetID()
+	})
+
+	var inFlight []*github.Deployment
+	var finished *github.Deployment
+	for _, deployment := range matched {
+		state, err := latestDeploymentState(ctx, client, owner, repo, deployment.GetID())
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case inFlightStates[state]:
+			inFlight = append(inFlight, deployment)
+		case state != "inactive" && finished == nil:
+			finished = deployment
+		}
+	}
+
+	switch {
+	case len(inFlight) == 1:
+		return inFlight[0], nil
+	case len(inFlight) > 1:
+		candidates := make([]int64, len(inFlight))
+		for i, deployment := range inFlight {
+			candidates[i] = deployment.GetID()
+		}
+		return nil, &AmbiguousDeploymentError{Query: query, Candidates: candidates}
+	case finished != nil:
+		// Older finished deployments were superseded by a redeploy of the same commit
+		return finished, nil
+	default:
+		// Everything was deactivated, fall back to the newest one
+		return matched[0], nil
+	}
+}
+
+// inFlightStates are the deployment states that can still change, only these make a lookup ambiguous
+var inFlightStates = map[string]bool{"pending": true, "queued": true, "in_progress": true}
+
+// CommitSucceeded reports whether an application deployment of sha to the environment ever reached success
+// A successful deployment that a newer one replaced is inactive by now, so the whole timeline is checked
+func CommitSucceeded(ctx context.Context, client DeploymentAPI, owner, repo, sha, environment string) (bool, error) {
+	opts := &github.DeploymentsListOptions{
+		SHA:         sha,
+		Environment: environment,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		deployments, resp, err := client.ListDeployments(ctx, owner, repo, opts)
+		if err != nil {
+			return false, fmt.Errorf("failed to list deployments for %s/%s@%s in %s environment: %w", owner, repo, sha, environment, err)
+		}
+		for _, deployment := range deployments {
+			if !config.IsApplicationTask(deployment.GetTask()) {
+				continue
+			}
+			succeeded, err := everSucceeded(ctx, client, owner, repo, deployment.GetID())
+			if err != nil {
+				return false, err
+			}
+			if succeeded {
+				return true, nil
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// matches applies the filters the list endpoint can't
+func (q DeploymentQuery) matches(deployment *github.Deployment) bool {
+	if q.SHA != "" && deployment.GetSHA() != q.SHA {
+		return false
+	}
+	if q.Environment != "" && deployment.GetEnvironment() != q.Environment {
+		return false
+	}
+	if q.Task != "" && deployment.GetTask() != q.Task {
+		return false
+	}
+	if q.Task == "" && !config.IsApplicationTask(deployment.GetTask()) {
+		return false
+	}
+	if q.CreatorLogin != "" && !strings.EqualFold(deployment.GetCreator().GetLogin(), q.CreatorLogin) {
+		return false
+	}
+	if len(q.Correlation) == 0 {
+		return true
+	}
+
+	decoded, err := payload.Decode(deployment.Payload)
+	if err != nil {
+		return false
+	}
+	for key, want := range q.Correlation {
+		if got, ok := decoded.Value(key); !ok || got != want {
+			return false
+		}
+	}
+	return true
+}
+
+// AppLogin returns the bot login of the host's App, e.g. my-app[bot]
+// appID must be the App the host authenticates as, 0 selects it
+func (f *ClientFactory) AppLogin(ctx context.Context, hostName, org string, appID int64) (string, error) {
+	h, err := f.resolveHost(hostName, org)
+	if err != nil {
+		return "", err
+	}
+	if appID != 0 && appID != h.config.AppID {
+		return "", fmt.Errorf("App %d is not the App configured for %s (App %d)", appID, h.describe(), h.config.AppID)
+	}
+
+	f.mu.Lock()
+	login, exists := f.appLogins[h.config.Name]
+	f.mu.Unlock()
+	if exists {
+		return login, nil
+	}
+
+	appClient, err := f.appClient(h)
+	if err != nil {
+		return "", err
+	}
+	app, _, err := appClient.Apps.Get(ctx, "")
+	if err != nil {
+		return "", fmt.Errorf("failed to get App %d on %s: %w", h.config.AppID, h.describe(), err)
+	}
+
+	login = app.GetSlug() + "[bot]"
+	f.mu.Lock()
+	f.appLogins[h.config.Name] = login
+	f.mu.Unlock()
+	return login, nil
+}
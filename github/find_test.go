@@ -0,0 +1,113 @@
+package github_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/github/fake"
+)
+
+// deploy creates a deployment of sha in environment and posts states in order
+func deploy(t *testing.T, d *fake.Deployments, sha, environment string, states ...string) int64 {
+	t.Helper()
+	ctx := context.Background()
+
+	deployment, _, err := d.CreateDeployment(ctx, "acme", "web", &github.DeploymentRequest{
+		Ref:         github.String(sha),
+		Environment: github.String(environment),
+	})
+	require.NoError(t, err)
+	for _, state := range states {
+		_, _, err := d.CreateDeploymentStatus(ctx, "acme", "web", deployment.GetID(), &github.DeploymentStatusRequest{State: github.String(state)})
+		require.NoError(t, err)
+	}
+	return deployment.GetID()
+}
+
+func findDeployment(d *fake.Deployments, sha, environment string) (*github.Deployment, error) {
+	return githubClient.FindDeployment(context.Background(), d, "acme", "web", githubClient.DeploymentQuery{SHA: sha, Environment: environment})
+}
+
+func TestFindDeploymentSingleMatch(t *testing.T) {
+	d := fake.NewDeployments()
+	id := deploy(t, d, "abc123", "staging", "in_progress")
+	deploy(t, d, "def456", "staging", "in_progress")
+
+	deployment, err := findDeployment(d, "abc123", "staging")
+	require.NoError(t, err)
+	assert.Equal(t, id, deployment.GetID())
+
+	_, err = findDeployment(d, "abc123", "production")
+	assert.ErrorIs(t, err, githubClient.ErrDeploymentNotFound)
+}
+
+func TestFindDeploymentRedeployAfterFailure(t *testing.T) {
+	d := fake.NewDeployments()
+	deploy(t, d, "abc123", "staging", "in_progress", "failure")
+	retry := deploy(t, d, "abc123", "staging", "in_progress")
+
+	deployment, err := findDeployment(d, "abc123", "staging")
+	require.NoError(t, err)
+	assert.Equal(t, retry, deployment.GetID())
+
+	// Once the retry finishes it still supersedes the failed attempt
+	_, _, err = d.CreateDeploymentStatus(context.Background(), "acme", "web", retry, &github.DeploymentStatusRequest{State: github.String("error")})
+	require.NoError(t, err)
+	deployment, err = findDeployment(d, "abc123", "staging")
+	require.NoError(t, err)
+	assert.Equal(t, retry, deployment.GetID())
+}
+
+func TestFindDeploymentSecondProductionDeployment(t *testing.T) {
+	d := fake.NewDeployments()
+	// Production deployments are not deactivated when the next one succeeds
+	deploy(t, d, "abc123", "production", "success")
+	second := deploy(t, d, "abc123", "production", "success")
+
+	deployment, err := findDeployment(d, "abc123", "production")
+	require.NoError(t, err)
+	assert.Equal(t, second, deployment.GetID())
+}
+
+func TestFindDeploymentPrefersInFlightOverNewerFinished(t *testing.T) {
+	d := fake.NewDeployments()
+	running := deploy(t, d, "abc123", "staging", "queued")
+	deploy(t, d, "abc123", "staging", "failure")
+
+	deployment, err := findDeployment(d, "abc123", "staging")
+	require.NoError(t, err)
+	assert.Equal(t, running, deployment.GetID())
+}
+
+func TestFindDeploymentAllInactive(t *testing.T) {
+	d := fake.NewDeployments()
+	deploy(t, d, "abc123", "staging", "success", "inactive")
+	newest := deploy(t, d, "abc123", "staging", "success", "inactive")
+
+	deployment, err := findDeployment(d, "abc123", "staging")
+	require.NoError(t, err)
+	assert.Equal(t, newest, deployment.GetID())
+}
+
+func TestFindDeploymentAmbiguousInFlight(t *testing.T) {
+	d := fake.NewDeployments()
+	deploy(t, d, "abc123", "staging", "success")
+	first := deploy(t, d, "abc123", "staging", "in_progress")
+	second := deploy(t, d, "abc123", "staging")
+
+	_, err := findDeployment(d, "abc123", "staging")
+	var ambiguous *githubClient.AmbiguousDeploymentError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []int64{second, first}, ambiguous.Candidates)
+
+	// A correlation key tells them apart
+	_, err = githubClient.FindDeployment(context.Background(), d, "acme", "web", githubClient.DeploymentQuery{
+		SHA: "abc123", Environment: "staging", Correlation: map[string]string{"harness.execution_id": "exec-1"},
+	})
+	assert.ErrorIs(t, err, githubClient.ErrDeploymentNotFound)
+}
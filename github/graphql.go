@@ -0,0 +1,684 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxNodesPerQuery keeps batched queries well under GitHub's 500,000 node limit
+	// and close to the 100-node unit GitHub charges one rate limit point for
+	maxNodesPerQuery = 5000
+	// maxReposPerQuery caps aliases per query to keep query size and latency reasonable
+	maxReposPerQuery = 50
+	// pageSize is the largest page GitHub returns for a connection, longer ones are read through endCursor
+	pageSize = 100
+	// defaultRateLimitWait is how long a bulk read waits for the rate limit to reset
+	defaultRateLimitWait = 5 * time.Minute
+)
+
+// RepositoryRef identifies a repository
+type RepositoryRef struct {
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+}
+
+// String returns owner/name
+func (r RepositoryRef) String() string {
+	return r.Owner + "/" + r.Name
+}
+
+// BulkDeploymentQuery describes a batched read across many repositories
+type BulkDeploymentQuery struct {
+	Repositories []RepositoryRef
+
+	// Environments returns the latest deployment of each named environment
+	// When empty the newest DeploymentsPerRepo deployments of any environment are returned
+	Environments []string
+
+	// DeploymentsPerRepo is used when Environments is empty (default 1)
+	// More than 100 are read page by page after the batch
+	DeploymentsPerRepo int
+
+	// StatusesPerDeployment is the status history depth per deployment (default 0, max 100)
+	// The latest status is always returned
+	StatusesPerDeployment int
+
+	// IncludeEnvironments also lists every GitHub environment configured on each repository
+	IncludeEnvironments bool
+}
+
+// RepositoryDeployments is the bulk read result for a single repository
+type RepositoryDeployments struct {
+	Repository   RepositoryRef       `json:"repository"`
+	Environments []string            `json:"environments,omitempty"`
+	Deployments  []DeploymentSummary `json:"deployments"`
+	// Error is set when this repository could not be read (e.g. not found or not installed)
+	Error string `json:"error,omitempty"`
+}
+
+// DeploymentSummary is a deployment as returned by the GraphQL API
+type DeploymentSummary struct {
+	ID           int64                     `json:"id"`
+	Environment  string                    `json:"environment"`
+	Task         string                    `json:"task"`
+	State        string                    `json:"state"`
+	SHA          string                    `json:"sha"`
+	Ref          string                    `json:"ref,omitempty"`
+	Creator      string                    `json:"creator,omitempty"`
+	Description  string                    `json:"description,omitempty"`
+	CreatedAt    time.Time                 `json:"created_at"`
+	LatestStatus *DeploymentStatusSummary  `json:"latest_status,omitempty"`
+	Statuses     []DeploymentStatusSummary `json:"statuses,omitempty"`
+}
+
+// DeploymentStatusSummary is a deployment status as returned by the GraphQL API
+type DeploymentStatusSummary struct {
+	State          string    `json:"state"`
+	Description    string    `json:"description,omitempty"`
+	EnvironmentURL string    `json:"environment_url,omitempty"`
+	LogURL         string    `json:"log_url,omitempty"`
+	Creator        string    `json:"creator,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GraphQLRateLimit is the rateLimit object returned with every query
+type GraphQLRateLimit struct {
+	Cost      int       `json:"cost"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	NodeCount int       `json:"nodeCount"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// pageInfo is the paging state of a connection
+type pageInfo struct {
+	HasNextPage bool   `json:"hasNextPage"`
+	EndCursor   string `json:"endCursor"`
+}
+
+// repositoryPages holds the end cursors of a repository's connections with pages left to read
+type repositoryPages struct {
+	environments string
+	deployments  string
+}
+
+// GraphQLClient reads deployment data in bulk through the GitHub GraphQL API
+type GraphQLClient struct {
+	httpClient *http.Client
+	endpoint   string
+	logger     zerolog.Logger
+
+	// MaxRateLimitWait bounds how long a read waits for the rate limit to reset
+	MaxRateLimitWait time.Duration
+}
+
+// NewGraphQLClient creates a GraphQL client for an authenticated HTTP client
+// endpoint is https://api.github.com/graphql for GitHub.com or <url>/api/graphql for Enterprise
+func NewGraphQLClient(httpClient *http.Client, endpoint string, logger zerolog.Logger) *GraphQLClient {
+	return &GraphQLClient{
+		httpClient:       httpClient,
+		endpoint:         endpoint,
+		logger:           logger,
+		MaxRateLimitWait: defaultRateLimitWait,
+	}
+}
+
+// CreateGraphQLClientForHost creates a GraphQL client using the organization's installation auth
+func (f *ClientFactory) CreateGraphQLClientForHost(ctx context.Context, hostName, org string) (*GraphQLClient, error) {
+	h, err := f.resolveHost(hostName, org)
+	if err != nil {
+		return nil, err
+	}
+
+	installationID, err := f.findInstallationID(ctx, h, org)
+	if err != nil {
+		return nil, err
+	}
+
+	itr, err := f.installationTransport(h, installationID)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := "https://api.github.com/graphql"
+	if h.config.IsEnterprise() {
+		endpoint = h.config.URL + "/api/graphql"
+	}
+
+	return NewGraphQLClient(h.httpClient(itr), endpoint, f.logger), nil
+}
+
+// FetchDeployments reads deployments for repositories across organizations on a host
+// Repositories are grouped by owner so each group uses its own installation
+func (f *ClientFactory) FetchDeployments(ctx context.Context, hostName string, query BulkDeploymentQuery) ([]RepositoryDeployments, error) {
+	var owners []string
+	byOwner := make(map[string][]RepositoryRef)
+	for _, repo := range query.Repositories {
+		if _, exists := byOwner[repo.Owner]; !exists {
+			owners = append(owners, repo.Owner)
+		}
+		byOwner[repo.Owner] = append(byOwner[repo.Owner], repo)
+	}
+
+	var results []RepositoryDeployments
+	for _, owner := range owners {
+		client, err := f.CreateGraphQLClientForHost(ctx, hostName, owner)
+		if err != nil {
+			return results, fmt.Errorf("failed to create GraphQL client for organization %s: %w", owner, err)
+		}
+
+		ownerQuery := query
+		ownerQuery.Repositories = byOwner[owner]
+		ownerResults, err := client.FetchDeployments(ctx, ownerQuery)
+		results = append(results, ownerResults...)
+		if err != nil {
+			return results, fmt.Errorf("failed to read deployments for organization %s: %w", owner, err)
+		}
+	}
+
+	return results, nil
+}
+
+// FetchDeployments reads deployments, statuses and environments for many repositories
+// Repositories are split into batches sized by estimated node count, and the rate limit
+// reported by each response is checked before sending the next batch
+func (c *GraphQLClient) FetchDeployments(ctx context.Context, query BulkDeploymentQuery) ([]RepositoryDeployments, error) {
+	query = normalizeBulkQuery(query)
+	batchSize := bulkBatchSize(query)
+	estimatedCost := estimateCost(query, batchSize)
+
+	results := make([]RepositoryDeployments, 0, len(query.Repositories))
+	for start := 0; start < len(query.Repositories); start += batchSize {
+		end := start + batchSize
+		if end > len(query.Repositories) {
+			end = len(query.Repositories)
+		}
+		batch := query.Repositories[start:end]
+
+		batchResults, rateLimit, err := c.fetchBatch(ctx, query, batch)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, batchResults...)
+
+		c.logger.Debug().
+			Int("repositories", len(batch)).
+			Int("cost", rateLimit.Cost).
+			Int("node_count", rateLimit.NodeCount).
+			Int("remaining", rateLimit.Remaining).
+			Msg("GraphQL deployment batch fetched")
+
+		// Wait for the reset when the next batch would exceed the remaining budget
+		if end < len(query.Repositories) && rateLimit.Remaining < estimatedCost {
+			if err := c.waitForReset(ctx, rateLimit); err != nil {
+				return results, err
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// waitForReset sleeps until the rate limit resets, bounded by MaxRateLimitWait
+func (c *GraphQLClient) waitForReset(ctx context.Context, rateLimit GraphQLRateLimit) error {
+	wait := time.Until(rateLimit.ResetAt)
+	if wait > c.MaxRateLimitWait {
+		return fmt.Errorf("GraphQL rate limit exhausted (%d remaining), resets at %s", rateLimit.Remaining, rateLimit.ResetAt.Format(time.RFC3339))
+	}
+	if wait <= 0 {
+		return nil
+	}
+
+	c.logger.Warn().
+		Int("remaining", rateLimit.Remaining).
+		Time("reset_at", rateLimit.ResetAt).
+		Dur("wait", wait).
+		Msg("Waiting for GraphQL rate limit reset")
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// fetchBatch runs one aliased query for a batch of repositories
+func (c *GraphQLClient) fetchBatch(ctx context.Context, query BulkDeploymentQuery, batch []RepositoryRef) ([]RepositoryDeployments, GraphQLRateLimit, error) {
+	document, variables := buildBulkQuery(query, batch)
+
+	var response struct {
+		Data   map[string]json.RawMessage `json:"data"`
+		Errors []struct {
+			Type    string        `json:"type"`
+			Message string        `json:"message"`
+			Path    []interface{} `json:"path"`
+		} `json:"errors"`
+	}
+	if err := c.do(ctx, document, variables, &response); err != nil {
+		return nil, GraphQLRateLimit{}, err
+	}
+
+	// Errors scoped to a repository alias are reported on that repository, any other one fails the batch
+	repoErrors := make(map[string]string)
+	for _, gqlErr := range response.Errors {
+		if alias, ok := repositoryAlias(gqlErr.Path); ok {
+			repoErrors[alias] = gqlErr.Message
+			continue
+		}
+		return nil, GraphQLRateLimit{}, fmt.Errorf("GraphQL query failed: %s", gqlErr.Message)
+	}
+
+	var rateLimit GraphQLRateLimit
+	if raw, exists := response.Data["rateLimit"]; exists {
+		if err := json.Unmarshal(raw, &rateLimit); err != nil {
+			return nil, GraphQLRateLimit{}, fmt.Errorf("failed to decode GraphQL rate limit: %w", err)
+		}
+	}
+
+	results := make([]RepositoryDeployments, 0, len(batch))
+	for i, repo := range batch {
+		alias := fmt.Sprintf("r%d", i)
+		result := RepositoryDeployments{Repository: repo, Deployments: []DeploymentSummary{}}
+
+		raw := response.Data[alias]
+		if len(raw) == 0 || string(raw) == "null" {
+			result.Error = repoErrors[alias]
+			if result.Error == "" {
+				result.Error = "repository not found"
+			}
+			results = append(results, result)
+			continue
+		}
+
+		pages, err := decodeRepository(raw, query, &result)
+		if err != nil {
+			return nil, rateLimit, fmt.Errorf("failed to decode deployments for %s: %w", repo, err)
+		}
+		if pages != (repositoryPages{}) {
+			pageRateLimit, err := c.fetchPages(ctx, query, &result, pages)
+			if err != nil {
+				return nil, rateLimit, err
+			}
+			rateLimit = pageRateLimit
+		}
+		results = append(results, result)
+	}
+
+	return results, rateLimit, nil
+}
+
+// repositoryAlias returns the r<n> repository alias an error path starts with
+func repositoryAlias(path []interface{}) (string, bool) {
+	if len(path) == 0 {
+		return "", false
+	}
+	alias, ok := path[0].(string)
+	if !ok || len(alias) < 2 || alias[0] != 'r' {
+		return "", false
+	}
+	if _, err := strconv.Atoi(alias[1:]); err != nil {
+		return "", false
+	}
+	return alias, true
+}
+
+// fetchPages reads the rest of a repository's environments and deployments through their end cursors
+// Returns the rate limit reported by the last page
+func (c *GraphQLClient) fetchPages(ctx context.Context, query BulkDeploymentQuery, result *RepositoryDeployments, pages repositoryPages) (GraphQLRateLimit, error) {
+	var rateLimit GraphQLRateLimit
+	for pages.environments != "" {
+		var connection struct {
+			Nodes []struct {
+				Name string `json:"name"`
+			} `json:"nodes"`
+			PageInfo pageInfo `json:"pageInfo"`
+		}
+		selection := fmt.Sprintf("environments(first: %d, after: $after) { nodes { name } pageInfo { hasNextPage endCursor } }", pageSize)
+		var err error
+		if rateLimit, err = c.fetchPage(ctx, result.Repository, selection, pages.environments, &connection); err != nil {
+			return rateLimit, err
+		}
+		for _, node := range connection.Nodes {
+			result.Environments = append(result.Environments, node.Name)
+		}
+		pages.environments = nextCursor(connection.PageInfo)
+	}
+
+	for pages.deployments != "" {
+		var connection struct {
+			Nodes    []graphQLDeployment `json:"nodes"`
+			PageInfo pageInfo            `json:"pageInfo"`
+		}
+		first := query.DeploymentsPerRepo - len(result.Deployments)
+		if first > pageSize {
+			first = pageSize
+		}
+		selection := fmt.Sprintf("deployments(first: %d, after: $after, orderBy: {field: CREATED_AT, direction: DESC}) { nodes { %s%s } pageInfo { hasNextPage endCursor } }",
+			first, deploymentFields, statusSelection(query))
+		var err error
+		if rateLimit, err = c.fetchPage(ctx, result.Repository, selection, pages.deployments, &connection); err != nil {
+			return rateLimit, err
+		}
+		for _, node := range connection.Nodes {
+			result.Deployments = append(result.Deployments, node.summary())
+		}
+		pages.deployments = ""
+		if len(result.Deployments) < query.DeploymentsPerRepo {
+			pages.deployments = nextCursor(connection.PageInfo)
+		}
+	}
+
+	return rateLimit, nil
+}
+
+// fetchPage reads the page after a cursor of one connection of a repository
+func (c *GraphQLClient) fetchPage(ctx context.Context, repo RepositoryRef, selection, after string, out interface{}) (GraphQLRateLimit, error) {
+	document := fmt.Sprintf("query($owner: String!, $name: String!, $after: String!) {\nrateLimit { cost limit remaining nodeCount resetAt }\nrepository(owner: $owner, name: $name) {\n  page: %s\n}\n}", selection)
+	variables := map[string]interface{}{"owner": repo.Owner, "name": repo.Name, "after": after}
+
+	var response struct {
+		Data *struct {
+			RateLimit  GraphQLRateLimit `json:"rateLimit"`
+			Repository *struct {
+				Page json.RawMessage `json:"page"`
+			} `json:"repository"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := c.do(ctx, document, variables, &response); err != nil {
+		return GraphQLRateLimit{}, err
+	}
+	if len(response.Errors) > 0 {
+		return GraphQLRateLimit{}, fmt.Errorf("GraphQL page query for %s failed: %s", repo, response.Errors[0].Message)
+	}
+	if response.Data == nil || response.Data.Repository == nil {
+		return GraphQLRateLimit{}, fmt.Errorf("GraphQL page query for %s returned no repository", repo)
+	}
+	if err := json.Unmarshal(response.Data.Repository.Page, out); err != nil {
+		return response.Data.RateLimit, fmt.Errorf("failed to decode GraphQL page for %s: %w", repo, err)
+	}
+	return response.Data.RateLimit, nil
+}
+
+// nextCursor returns the cursor of the next page, empty after the last one
+func nextCursor(info pageInfo) string {
+	if !info.HasNextPage {
+		return ""
+	}
+	return info.EndCursor
+}
+
+// do posts a GraphQL document and decodes the response body
+func (c *GraphQLClient) do(ctx context.Context, document string, variables map[string]interface{}, out interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     document,
+		"variables": variables,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode GraphQL request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create GraphQL request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("GraphQL request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("GraphQL request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode GraphQL response: %w", err)
+	}
+	return nil
+}
+
+// deploymentFields selects the deployment fields shared by every alias
+const deploymentFields = `databaseId environment task state description createdAt commitOid
+ref { name } creator { login }
+latestStatus { state description environmentUrl logUrl createdAt }`
+
+// buildBulkQuery renders the aliased query document and its variables
+func buildBulkQuery(query BulkDeploymentQuery, batch []RepositoryRef) (string, map[string]interface{}) {
+	variables := make(map[string]interface{})
+	var params []string
+	var doc strings.Builder
+
+	statuses := statusSelection(query)
+
+	for i, env := range query.Environments {
+		name := fmt.Sprintf("e%d", i)
+		params = append(params, fmt.Sprintf("$%s: String!", name))
+		variables[name] = env
+	}
+
+	for i, repo := range batch {
+		owner, name := fmt.Sprintf("o%d", i), fmt.Sprintf("n%d", i)
+		params = append(params, fmt.Sprintf("$%s: String!, $%s: String!", owner, name))
+		variables[owner] = repo.Owner
+		variables[name] = repo.Name
+
+		fmt.Fprintf(&doc, "r%d: repository(owner: $%s, name: $%s) {\n", i, owner, name)
+		if query.IncludeEnvironments {
+			fmt.Fprintf(&doc, "  environments(first: %d) { nodes { name } pageInfo { hasNextPage endCursor } }\n", pageSize)
+		}
+		if len(query.Environments) > 0 {
+			for j := range query.Environments {
+				fmt.Fprintf(&doc, "  d%d: deployments(first: 1, environments: [$e%d], orderBy: {field: CREATED_AT, direction: DESC}) { nodes { %s%s } }\n",
+					j, j, deploymentFields, statuses)
+			}
+		} else {
+			fmt.Fprintf(&doc, "  d0: deployments(first: %d, orderBy: {field: CREATED_AT, direction: DESC}) { nodes { %s%s } pageInfo { hasNextPage endCursor } }\n",
+				firstPage(query), deploymentFields, statuses)
+		}
+		doc.WriteString("}\n")
+	}
+
+	document := fmt.Sprintf("query(%s) {\nrateLimit { cost limit remaining nodeCount resetAt }\n%s}", strings.Join(params, ", "), doc.String())
+	return document, variables
+}
+
+// statusSelection selects the requested status history of each deployment
+func statusSelection(query BulkDeploymentQuery) string {
+	if query.StatusesPerDeployment == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" statuses(first: %d) { nodes { state description environmentUrl logUrl createdAt } }", query.StatusesPerDeployment)
+}
+
+// firstPage is how many deployments of each repository the batch reads
+func firstPage(query BulkDeploymentQuery) int {
+	if query.DeploymentsPerRepo > pageSize {
+		return pageSize
+	}
+	return query.DeploymentsPerRepo
+}
+
+// graphQLDeployment mirrors the selected Deployment fields
+type graphQLDeployment struct {
+	DatabaseID  int64     `json:"databaseId"`
+	Environment string    `json:"environment"`
+	Task        string    `json:"task"`
+	State       string    `json:"state"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	CommitOid   string    `json:"commitOid"`
+	Ref         *struct {
+		Name string `json:"name"`
+	} `json:"ref"`
+	Creator *struct {
+		Login string `json:"login"`
+	} `json:"creator"`
+	LatestStatus *graphQLStatus `json:"latestStatus"`
+	Statuses     *struct {
+		Nodes []graphQLStatus `json:"nodes"`
+	} `json:"statuses"`
+}
+
+type graphQLStatus struct {
+	State          string    `json:"state"`
+	Description    string    `json:"description"`
+	EnvironmentURL string    `json:"environmentUrl"`
+	LogURL         string    `json:"logUrl"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+func (s graphQLStatus) summary() DeploymentStatusSummary {
+	return DeploymentStatusSummary{
+		State:          strings.ToLower(s.State),
+		Description:    s.Description,
+		EnvironmentURL: s.EnvironmentURL,
+		LogURL:         s.LogURL,
+		CreatedAt:      s.CreatedAt,
+	}
+}
+
+// decodeRepository converts an aliased repository object into a result
+// Returns the cursors of the connections with pages left to read
+func decodeRepository(raw json.RawMessage, query BulkDeploymentQuery, result *RepositoryDeployments) (repositoryPages, error) {
+	var pages repositoryPages
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return pages, err
+	}
+
+	if envRaw, exists := fields["environments"]; exists {
+		var environments struct {
+			Nodes []struct {
+				Name string `json:"name"`
+			} `json:"nodes"`
+			PageInfo pageInfo `json:"pageInfo"`
+		}
+		if err := json.Unmarshal(envRaw, &environments); err != nil {
+			return pages, err
+		}
+		for _, node := range environments.Nodes {
+			result.Environments = append(result.Environments, node.Name)
+		}
+		pages.environments = nextCursor(environments.PageInfo)
+	}
+
+	aliases := 1
+	if len(query.Environments) > 0 {
+		aliases = len(query.Environments)
+	}
+	for i := 0; i < aliases; i++ {
+		var connection struct {
+			Nodes    []graphQLDeployment `json:"nodes"`
+			PageInfo pageInfo            `json:"pageInfo"`
+		}
+		if err := json.Unmarshal(fields[fmt.Sprintf("d%d", i)], &connection); err != nil {
+			return pages, err
+		}
+		for _, node := range connection.Nodes {
+			result.Deployments = append(result.Deployments, node.summary())
+		}
+		// Only the deployments of any environment are paged, the per-environment aliases read one each
+		if len(query.Environments) == 0 && len(result.Deployments) < query.DeploymentsPerRepo {
+			pages.deployments = nextCursor(connection.PageInfo)
+		}
+	}
+
+	return pages, nil
+}
+
+func (d graphQLDeployment) summary() DeploymentSummary {
+	summary := DeploymentSummary{
+		ID:          d.DatabaseID,
+		Environment: d.Environment,
+		Task:        d.Task,
+		State:       strings.ToLower(d.State),
+		SHA:         d.CommitOid,
+		Description: d.Description,
+		CreatedAt:   d.CreatedAt,
+	}
+	if d.Ref != nil {
+		summary.Ref = d.Ref.Name
+	}
+	if d.Creator != nil {
+		summary.Creator = d.Creator.Login
+	}
+	if d.LatestStatus != nil {
+		latest := d.LatestStatus.summary()
+		summary.LatestStatus = &latest
+	}
+	if d.Statuses != nil {
+		for _, status := range d.Statuses.Nodes {
+			summary.Statuses = append(summary.Statuses, status.summary())
+		}
+	}
+	return summary
+}
+
+// normalizeBulkQuery applies defaults and GitHub's status connection size limit
+func normalizeBulkQuery(query BulkDeploymentQuery) BulkDeploymentQuery {
+	if query.DeploymentsPerRepo <= 0 {
+		query.DeploymentsPerRepo = 1
+	}
+	if query.StatusesPerDeployment < 0 {
+		query.StatusesPerDeployment = 0
+	}
+	if query.StatusesPerDeployment > 100 {
+		query.StatusesPerDeployment = 100
+	}
+	return query
+}
+
+// estimateNodesPerRepo approximates GitHub's node count for one repository alias
+func estimateNodesPerRepo(query BulkDeploymentQuery) int {
+	deployments := firstPage(query)
+	if len(query.Environments) > 0 {
+		deployments = len(query.Environments)
+	}
+	// Each deployment carries its latest status plus the requested history
+	nodes := 1 + deployments*(2+query.StatusesPerDeployment)
+	if query.IncludeEnvironments {
+		nodes += pageSize
+	}
+	return nodes
+}
+
+// bulkBatchSize returns how many repositories fit in one query
+func bulkBatchSize(query BulkDeploymentQuery) int {
+	size := maxNodesPerQuery / estimateNodesPerRepo(query)
+	if size > maxReposPerQuery {
+		size = maxReposPerQuery
+	}
+	if size < 1 {
+		size = 1
+	}
+	return size
+}
+
+// estimateCost approximates the rate limit points of one batch (one point per 100 nodes)
+func estimateCost(query BulkDeploymentQuery, batchSize int) int {
+	cost := (estimateNodesPerRepo(query)*batchSize + 99) / 100
+	if cost < 1 {
+		cost = 1
+	}
+	return cost
+}
@@ -0,0 +1,224 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphQLRequest is a request the fake GraphQL endpoint received
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// graphQLServer answers every request with respond's response body
+func graphQLServer(t *testing.T, respond func(request graphQLRequest) map[string]interface{}) (*GraphQLClient, *[]graphQLRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []graphQLRequest
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, request)
+		mu.Unlock()
+		_ = json.NewEncoder(w).Encode(respond(request))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewGraphQLClient(server.Client(), server.URL, zerolog.Nop()), &requests
+}
+
+func rateLimit(remaining int, resetAt time.Time) map[string]interface{} {
+	return map[string]interface{}{"cost": 1, "limit": 5000, "remaining": remaining, "nodeCount": 10, "resetAt": resetAt}
+}
+
+// deploymentNodes returns count deployments numbered from first
+func deploymentNodes(first, count int) []map[string]interface{} {
+	nodes := make([]map[string]interface{}, count)
+	for i := range nodes {
+		nodes[i] = map[string]interface{}{
+			"databaseId": first + i, "environment": "production", "task": "deploy", "state": "ACTIVE",
+			"commitOid": fmt.Sprintf("sha%d", first+i), "createdAt": "2026-10-01T12:00:00Z",
+			"latestStatus": map[string]interface{}{"state": "SUCCESS", "createdAt": "2026-10-01T12:05:00Z"},
+		}
+	}
+	return nodes
+}
+
+// batchData answers a batched query with one deployment per repository alias, named after the repository
+func batchData(request graphQLRequest) map[string]interface{} {
+	data := map[string]interface{}{"rateLimit": rateLimit(4000, time.Now().Add(time.Hour))}
+	for i := 0; ; i++ {
+		name, ok := request.Variables[fmt.Sprintf("n%d", i)].(string)
+		if !ok {
+			break
+		}
+		nodes := deploymentNodes(i, 1)
+		nodes[0]["description"] = name
+		data[fmt.Sprintf("r%d", i)] = map[string]interface{}{"d0": map[string]interface{}{"nodes": nodes}}
+	}
+	return data
+}
+
+func repositories(count int) []RepositoryRef {
+	repos := make([]RepositoryRef, count)
+	for i := range repos {
+		repos[i] = RepositoryRef{Owner: "acme", Name: fmt.Sprintf("svc-%d", i)}
+	}
+	return repos
+}
+
+func TestFetchDeploymentsBatchesRepositories(t *testing.T) {
+	client, requests := graphQLServer(t, func(request graphQLRequest) map[string]interface{} {
+		data := batchData(request)
+		response := map[string]interface{}{"data": data}
+		if request.Variables["n3"] == "svc-3" {
+			data["r3"] = nil
+			response["errors"] = []map[string]interface{}{
+				{"type": "NOT_FOUND", "message": "Could not resolve to a Repository", "path": []string{"r3"}},
+			}
+		}
+		return response
+	})
+
+	results, err := client.FetchDeployments(context.Background(), BulkDeploymentQuery{Repositories: repositories(maxReposPerQuery + 10)})
+	require.NoError(t, err)
+	require.Len(t, *requests, 2)
+	assert.Len(t, (*requests)[0].Variables, 2*maxReposPerQuery)
+	assert.Len(t, (*requests)[1].Variables, 2*10)
+
+	require.Len(t, results, maxReposPerQuery+10)
+	for i, result := range results {
+		assert.Equal(t, fmt.Sprintf("svc-%d", i), result.Repository.Name)
+		if i == 3 {
+			assert.Equal(t, "Could not resolve to a Repository", result.Error)
+			assert.Empty(t, result.Deployments)
+			continue
+		}
+		require.Len(t, result.Deployments, 1)
+		assert.Equal(t, result.Repository.Name, result.Deployments[0].Description)
+		assert.Equal(t, "active", result.Deployments[0].State)
+		assert.Equal(t, "success", result.Deployments[0].LatestStatus.State)
+	}
+}
+
+func TestFetchDeploymentsFailsOnUnscopedErrors(t *testing.T) {
+	client, _ := graphQLServer(t, func(request graphQLRequest) map[string]interface{} {
+		return map[string]interface{}{
+			"data":   batchData(request),
+			"errors": []map[string]interface{}{{"message": "Something went wrong", "path": []string{"rateLimit"}}},
+		}
+	})
+
+	_, err := client.FetchDeployments(context.Background(), BulkDeploymentQuery{Repositories: repositories(2)})
+	assert.ErrorContains(t, err, "Something went wrong")
+}
+
+func TestFetchDeploymentsWaitsForRateLimitReset(t *testing.T) {
+	var resetAt time.Time
+	client, requests := graphQLServer(t, func(request graphQLRequest) map[string]interface{} {
+		data := batchData(request)
+		data["rateLimit"] = rateLimit(0, resetAt)
+		return map[string]interface{}{"data": data}
+	})
+	client.MaxRateLimitWait = time.Second
+
+	resetAt = time.Now().Add(200 * time.Millisecond)
+	started := time.Now()
+	results, err := client.FetchDeployments(context.Background(), BulkDeploymentQuery{Repositories: repositories(maxReposPerQuery + 1)})
+	require.NoError(t, err)
+	assert.Len(t, results, maxReposPerQuery+1)
+	assert.Len(t, *requests, 2)
+	assert.GreaterOrEqual(t, time.Since(started), 150*time.Millisecond)
+
+	// A reset further away than MaxRateLimitWait fails with the batches read so far
+	resetAt = time.Now().Add(time.Hour)
+	results, err = client.FetchDeployments(context.Background(), BulkDeploymentQuery{Repositories: repositories(maxReposPerQuery + 1)})
+	assert.ErrorContains(t, err, "rate limit exhausted")
+	assert.Len(t, results, maxReposPerQuery)
+	assert.Len(t, *requests, 3)
+}
+
+func TestFetchDeploymentsPagesThroughConnections(t *testing.T) {
+	client, requests := graphQLServer(t, func(request graphQLRequest) map[string]interface{} {
+		limit := rateLimit(4000, time.Now().Add(time.Hour))
+		after, paged := request.Variables["after"]
+		if !paged {
+			return map[string]interface{}{"data": map[string]interface{}{
+				"rateLimit": limit,
+				"r0": map[string]interface{}{
+					"environments": map[string]interface{}{
+						"nodes":    []map[string]string{{"name": "production"}},
+						"pageInfo": map[string]interface{}{"hasNextPage": true, "endCursor": "env-1"},
+					},
+					"d0": map[string]interface{}{
+						"nodes":    deploymentNodes(0, 100),
+						"pageInfo": map[string]interface{}{"hasNextPage": true, "endCursor": "dep-1"},
+					},
+				},
+			}}
+		}
+
+		var page map[string]interface{}
+		switch after {
+		case "env-1":
+			page = map[string]interface{}{
+				"nodes":    []map[string]string{{"name": "staging"}},
+				"pageInfo": map[string]interface{}{"hasNextPage": false, "endCursor": "env-2"},
+			}
+		case "dep-1":
+			page = map[string]interface{}{
+				"nodes":    deploymentNodes(100, 50),
+				"pageInfo": map[string]interface{}{"hasNextPage": true, "endCursor": "dep-2"},
+			}
+		}
+		return map[string]interface{}{"data": map[string]interface{}{
+			"rateLimit":  limit,
+			"repository": map[string]interface{}{"page": page},
+		}}
+	})
+
+	results, err := client.FetchDeployments(context.Background(), BulkDeploymentQuery{
+		Repositories:        repositories(1),
+		DeploymentsPerRepo:  150,
+		IncludeEnvironments: true,
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, []string{"production", "staging"}, results[0].Environments)
+	require.Len(t, results[0].Deployments, 150)
+	assert.Equal(t, int64(149), results[0].Deployments[149].ID)
+
+	// The batch, one environments page and one deployments page sized to what is left
+	require.Len(t, *requests, 3)
+	assert.Contains(t, (*requests)[0].Query, "d0: deployments(first: 100")
+	assert.Contains(t, (*requests)[2].Query, "deployments(first: 50, after: $after")
+}
+
+func TestDeployedRepositories(t *testing.T) {
+	since := time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)
+	latest := []RepositoryDeployments{
+		{Repository: RepositoryRef{Owner: "acme", Name: "recent"}, Deployments: []DeploymentSummary{{CreatedAt: since.Add(time.Hour)}}},
+		{Repository: RepositoryRef{Owner: "acme", Name: "stale"}, Deployments: []DeploymentSummary{{CreatedAt: since.Add(-time.Hour)}}},
+		{Repository: RepositoryRef{Owner: "acme", Name: "never"}},
+		{Repository: RepositoryRef{Owner: "acme", Name: "unreadable"}, Error: "repository not found"},
+	}
+
+	assert.Equal(t, []RepositoryRef{{Owner: "acme", Name: "recent"}, {Owner: "acme", Name: "unreadable"}}, DeployedRepositories(latest, since))
+	assert.Len(t, DeployedRepositories(latest, time.Time{}), 3)
+}
@@ -0,0 +1,199 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// HistoryQuery selects the deployments exported by the history command
+type HistoryQuery struct {
+	Repositories []RepositoryRef
+
+	// Environment limits the export to one environment, empty exports all
+	Environment string
+
+	// Created-at range, zero values leave the range open
+	Since time.Time
+	Until time.Time
+}
+
+// contains reports whether t falls in [Since, Until)
+func (q HistoryQuery) contains(t time.Time) bool {
+	if !q.Since.IsZero() && t.Before(q.Since) {
+		return false
+	}
+	if !q.Until.IsZero() && !t.Before(q.Until) {
+		return false
+	}
+	return true
+}
+
+// HistoryRecord is one deployment with its full status timeline, oldest status first
+type HistoryRecord struct {
+	Repository string `json:"repository"`
+	DeploymentSummary
+	Payload *payload.Payload `json:"payload,omitempty"` // Legacy payloads are upgraded to the current schema
+
+	// The payload as stored when it can't be decoded, e.g. written by a newer schema
+	RawPayload json.RawMessage `json:"raw_payload,omitempty"`
+}
+
+// InstallationRepositories lists the repositories the App can access in an organization
+func InstallationRepositories(ctx context.Context, client *github.Client, retrier *RateLimitRetrier) ([]RepositoryRef, error) {
+	var repos []RepositoryRef
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var page *github.ListRepositories
+		var resp *github.Response
+		err := retrier.Do(ctx, func() (*github.Response, error) {
+			var err error
+			page, resp, err = client.Apps.ListRepos(ctx, opts)
+			return resp, err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list installation repositories: %w", err)
+		}
+		for _, repo := range page.Repositories {
+			repos = append(repos, RepositoryRef{Owner: repo.GetOwner().GetLogin(), Name: repo.GetName()})
+		}
+		if resp.NextPage == 0 {
+			return repos, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// DeployedRepositories returns the repositories of a bulk read of the latest deployments with one created at or after since
+// Repositories the read failed for are kept, so the export reports their errors
+func DeployedRepositories(latest []RepositoryDeployments, since time.Time) []RepositoryRef {
+	var repos []RepositoryRef
+	for _, result := range latest {
+		deployed := result.Error != ""
+		for _, deployment := range result.Deployments {
+			if !deployment.CreatedAt.Before(since) {
+				deployed = true
+			}
+		}
+		if deployed {
+			repos = append(repos, result.Repository)
+		}
+	}
+	return repos
+}
+
+// ExportHistory streams the deployments of one repository in the query's range to fn, newest first
+// Reads page through every deployment and status and wait out rate limits instead of failing
+func ExportHistory(ctx context.Context, client DeploymentAPI, retrier *RateLimitRetrier, repo RepositoryRef, query HistoryQuery, fn func(HistoryRecord) error) error {
+	opts := &github.DeploymentsListOptions{
+		Environment: query.Environment,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		var deployments []*github.Deployment
+		var resp *github.Response
+		err := retrier.Do(ctx, func() (*github.Response, error) {
+			var err error
+			deployments, resp, err = client.ListDeployments(ctx, repo.Owner, repo.Name, opts)
+			return resp, err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list deployments for %s: %w", repo, err)
+		}
+
+		for _, deployment := range deployments {
+			created := deployment.GetCreatedAt().Time
+			// Deployments are listed newest first, nothing older can be in range
+			if !query.Since.IsZero() && created.Before(query.Since) {
+				return nil
+			}
+			if !query.contains(created) {
+				continue
+			}
+
+			record, err := historyRecord(ctx, client, retrier, repo, deployment)
+			if err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if resp == nil || resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// historyRecord reads the full status timeline of a deployment
+func historyRecord(ctx context.Context, client DeploymentAPI, retrier *RateLimitRetrier, repo RepositoryRef, deployment *github.Deployment) (HistoryRecord, error) {
+	record := HistoryRecord{
+		Repository: repo.String(),
+		DeploymentSummary: DeploymentSummary{
+			ID:          deployment.GetID(),
+			Environment: deployment.GetEnvironment(),
+			Task:        deployment.GetTask(),
+			State:       "pending",
+			SHA:         deployment.GetSHA(),
+			Ref:         deployment.GetRef(),
+			Creator:     deployment.GetCreator().GetLogin(),
+			Description: deployment.GetDescription(),
+			CreatedAt:   deployment.GetCreatedAt().Time,
+		},
+	}
+	if len(deployment.Payload) > 0 {
+		decoded, err := payload.Decode(deployment.Payload)
+		if err != nil {
+			record.RawPayload = append(json.RawMessage(nil), deployment.Payload...)
+		} else {
+			record.Payload = decoded
+		}
+	}
+
+	var statuses []*github.DeploymentStatus
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		var page []*github.DeploymentStatus
+		var resp *github.Response
+		err := retrier.Do(ctx, func() (*github.Response, error) {
+			var err error
+			page, resp, err = client.ListDeploymentStatuses(ctx, repo.Owner, repo.Name, deployment.GetID(), opts)
+			return resp, err
+		})
+		if err != nil {
+			return record, fmt.Errorf("failed to list statuses of deployment %d in %s: %w", deployment.GetID(), repo, err)
+		}
+		statuses = append(statuses, page...)
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	// GitHub lists statuses newest first
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		record.Statuses = append(record.Statuses, DeploymentStatusSummary{
+			State:          status.GetState(),
+			Description:    status.GetDescription(),
+			EnvironmentURL: status.GetEnvironmentURL(),
+			LogURL:         status.GetLogURL(),
+			Creator:        status.GetCreator().GetLogin(),
+			CreatedAt:      status.GetCreatedAt().Time,
+		})
+	}
+	if n := len(record.Statuses); n > 0 {
+		latest := record.Statuses[n-1]
+		record.State = latest.State
+		record.LatestStatus = &latest
+	}
+
+	return record, nil
+}
@@ -0,0 +1,358 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/rs/zerolog"
+
+	"github.com/imranansari/gh-deploy-wf/secrets"
+)
+
+// AppKey is a parsed GitHub App private key
+type AppKey struct {
+	// Fingerprint matches the one GitHub shows in the App settings (SHA256:<base64>)
+	Fingerprint string
+	Path        string
+	ModTime     time.Time
+
+	key *rsa.PrivateKey
+	pem []byte
+}
+
+// KeySet holds every active App private key of a host
+// Keys are tried newest first; a key that GitHub accepts after a fallback becomes
+// the preferred key until the set changes. Reload picks up added and removed files
+type KeySet struct {
+	location string
+	logger   zerolog.Logger
+
+	mu        sync.RWMutex
+	keys      []*AppKey
+	preferred string
+}
+
+// NewKeySet creates a key set from already loaded key files (path to PEM)
+// location is the file, directory or glob re-read by Reload
+func NewKeySet(location string, files map[string][]byte, logger zerolog.Logger) (*KeySet, error) {
+	ks := &KeySet{location: location, logger: logger}
+	keys, err := parseKeys(files)
+	if err != nil {
+		return nil, err
+	}
+	ks.keys = keys
+
+	for _, key := range keys {
+		logger.Info().
+			Str("key_fingerprint", key.Fingerprint).
+			Str("key_path", key.Path).
+			Msg("Loaded GitHub App private key")
+	}
+
+	return ks, nil
+}
+
+// Current returns the key used for the next JWT
+func (ks *KeySet) Current() *AppKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	return ks.currentLocked()
+}
+
+func (ks *KeySet) currentLocked() *AppKey {
+	for _, key := range ks.keys {
+		if key.Fingerprint == ks.preferred {
+			return key
+		}
+	}
+	if len(ks.keys) == 0 {
+		return nil
+	}
+	return ks.keys[0]
+}
+
+// Fingerprints returns the fingerprints of all active keys, preferred first
+func (ks *KeySet) Fingerprints() []string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	current := ks.currentLocked()
+	fingerprints := make([]string, 0, len(ks.keys))
+	if current != nil {
+		fingerprints = append(fingerprints, current.Fingerprint)
+	}
+	for _, key := range ks.keys {
+		if current == nil || key.Fingerprint != current.Fingerprint {
+			fingerprints = append(fingerprints, key.Fingerprint)
+		}
+	}
+	return fingerprints
+}
+
+// Sign signs JWT claims with the current key (implements ghinstallation.Signer)
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := ks.Current()
+	if key == nil {
+		return "", fmt.Errorf("no GitHub App private key available in %s", ks.location)
+	}
+	return ks.signWith(key, claims)
+}
+
+func (ks *KeySet) signWith(key *AppKey, claims jwt.Claims) (string, error) {
+	ks.logger.Debug().
+		Str("key_fingerprint", key.Fingerprint).
+		Msg("Signing GitHub App JWT")
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key.key)
+}
+
+// prefer makes the key with the fingerprint the preferred key
+func (ks *KeySet) prefer(fingerprint string) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	ks.preferred = fingerprint
+}
+
+// alternatives returns every key except the one with the given fingerprint
+func (ks *KeySet) alternatives(fingerprint string) []*AppKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	var keys []*AppKey
+	for _, key := range ks.keys {
+		if key.Fingerprint != fingerprint {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Reload re-reads the key location and swaps in the new key set
+// The previous keys stay active when the location is empty or unreadable
+func (ks *KeySet) Reload() error {
+	files, err := secrets.LoadFromGlob(ks.location)
+	if err != nil {
+		return err
+	}
+	keys, err := parseKeys(files)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	if sameKeys(ks.keys, keys) {
+		return nil
+	}
+
+	added, removed := diffKeys(ks.keys, keys)
+	ks.keys = keys
+	if !containsKey(keys, ks.preferred) {
+		ks.preferred = ""
+	}
+
+	ks.logger.Info().
+		Strs("added_fingerprints", added).
+		Strs("removed_fingerprints", removed).
+		Int("active_keys", len(keys)).
+		Str("key_location", ks.location).
+		Msg("Reloaded GitHub App private keys")
+
+	return nil
+}
+
+// Watch polls the key location until ctx is done, picking up rotated keys
+func (ks *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				ks.logger.Error().
+					Err(err).
+					Str("key_location", ks.location).
+					Msg("Failed to reload GitHub App private keys, keeping current keys")
+			}
+		}
+	}
+}
+
+// parseKeys parses PEM files into keys ordered newest first
+func parseKeys(files map[string][]byte) ([]*AppKey, error) {
+	keys := make([]*AppKey, 0, len(files))
+	seen := make(map[string]bool)
+	for path, data := range files {
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse private key %s: %w", path, err)
+		}
+		fingerprint, err := keyFingerprint(privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not fingerprint private key %s: %w", path, err)
+		}
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+
+		var modTime time.Time
+		if info, err := os.Stat(path); err == nil {
+			modTime = info.ModTime()
+		}
+		keys = append(keys, &AppKey{
+			Fingerprint: fingerprint,
+			Path:        path,
+			ModTime:     modTime,
+			key:         privateKey,
+			pem:         data,
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].ModTime.Equal(keys[j].ModTime) {
+			return keys[i].ModTime.After(keys[j].ModTime)
+		}
+		return keys[i].Path > keys[j].Path
+	})
+
+	return keys, nil
+}
+
+// keyFingerprint returns the SHA-256 fingerprint of the public key as GitHub displays it
+func keyFingerprint(key *rsa.PrivateKey) (string, error) {
+	return publicKeyFingerprint(&key.PublicKey)
+}
+
+// publicKeyFingerprint fingerprints a public key the same way as keyFingerprint
+func publicKeyFingerprint(key *rsa.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return "SHA256:" + base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+func sameKeys(a, b []*AppKey) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Fingerprint != b[i].Fingerprint || !bytes.Equal(a[i].pem, b[i].pem) {
+			return false
+		}
+	}
+	return true
+}
+
+func diffKeys(previous, current []*AppKey) (added, removed []string) {
+	for _, key := range current {
+		if !containsKey(previous, key.Fingerprint) {
+			added = append(added, key.Fingerprint)
+		}
+	}
+	for _, key := range previous {
+		if !containsKey(current, key.Fingerprint) {
+			removed = append(removed, key.Fingerprint)
+		}
+	}
+	return added, removed
+}
+
+func containsKey(keys []*AppKey, fingerprint string) bool {
+	for _, key := range keys {
+		if key.Fingerprint == fingerprint {
+			return true
+		}
+	}
+	return false
+}
+
+// keyFallbackTransport retries App JWT requests rejected with 401 using the other active keys
+// It sits below the ghinstallation transports, which only ever sign with the current key
+type keyFallbackTransport struct {
+	keys *KeySet
+	base http.RoundTripper
+}
+
+func (t *keyFallbackTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return t.base.RoundTrip(req)
+	}
+
+	// Keep the body replayable for retries
+	if req.Body != nil && req.GetBody == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	current := t.keys.Current()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || current == nil {
+		return resp, err
+	}
+
+	// Re-sign the same claims with each remaining key
+	claims := &jwt.RegisteredClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(strings.TrimPrefix(auth, "Bearer "), claims); err != nil {
+		return resp, nil
+	}
+
+	for _, key := range t.keys.alternatives(current.Fingerprint) {
+		signed, err := t.keys.signWith(key, claims)
+		if err != nil {
+			continue
+		}
+
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return resp, nil
+			}
+			retry.Body = body
+		}
+		retry.Header.Set("Authorization", "Bearer "+signed)
+
+		retryResp, err := t.base.RoundTrip(retry)
+		if err != nil || retryResp.StatusCode == http.StatusUnauthorized {
+			if retryResp != nil {
+				retryResp.Body.Close()
+			}
+			continue
+		}
+
+		t.keys.logger.Warn().
+			Str("rejected_fingerprint", current.Fingerprint).
+			Str("key_fingerprint", key.Fingerprint).
+			Msg("GitHub rejected App JWT, switched to fallback private key")
+		t.keys.prefer(key.Fingerprint)
+
+		resp.Body.Close()
+		return retryResp, nil
+	}
+
+	t.keys.logger.Error().
+		Strs("tried_fingerprints", t.keys.Fingerprints()).
+		Msg("GitHub rejected App JWT for every active private key")
+
+	return resp, nil
+}
@@ -0,0 +1,166 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imranansari/gh-deploy-wf/secrets"
+)
+
+// writeKey writes a new App private key to dir with the given modification time
+func writeKey(t *testing.T, dir, name string, modTime time.Time) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+	return key
+}
+
+func loadKeySet(t *testing.T, dir string) *KeySet {
+	t.Helper()
+	files, err := secrets.LoadFromGlob(dir)
+	require.NoError(t, err)
+	keys, err := NewKeySet(dir, files, zerolog.Nop())
+	require.NoError(t, err)
+	return keys
+}
+
+func fingerprint(t *testing.T, key *rsa.PrivateKey) string {
+	t.Helper()
+	fingerprint, err := keyFingerprint(key)
+	require.NoError(t, err)
+	return fingerprint
+}
+
+// appServer accepts App JWTs signed by the registered key only, like GitHub after a key was deleted
+// POST bodies must arrive intact on every attempt
+func appServer(t *testing.T, registered *rsa.PublicKey, requests *atomic.Int64) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		_, err := jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return registered, nil })
+		if err != nil {
+			http.Error(w, `{"message":"A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && string(body) != `{"repositories":["web"]}` {
+			http.Error(w, "body lost on retry", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func signedRequest(t *testing.T, keys *KeySet, url string) *http.Request {
+	t.Helper()
+	token, err := keys.Sign(jwt.RegisteredClaims{
+		Issuer:    "12345",
+		IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(5 * time.Minute)),
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader([]byte(`{"repositories":["web"]}`)))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestKeyFallbackTransportResignsWithOtherKeys(t *testing.T) {
+	dir := t.TempDir()
+	old := writeKey(t, dir, "old.pem", time.Now().Add(-time.Hour))
+	writeKey(t, dir, "new.pem", time.Now())
+	keys := loadKeySet(t, dir)
+
+	// GitHub still only knows the old key, the new one is tried first
+	var requests atomic.Int64
+	server := appServer(t, &old.PublicKey, &requests)
+	transport := &keyFallbackTransport{keys: keys, base: http.DefaultTransport}
+	require.NotEqual(t, fingerprint(t, old), keys.Current().Fingerprint)
+
+	resp, err := transport.RoundTrip(signedRequest(t, keys, server.URL+"/app/installations/1/access_tokens"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, int64(2), requests.Load())
+
+	// The accepted key is preferred from now on, no more rejected attempts
+	assert.Equal(t, fingerprint(t, old), keys.Current().Fingerprint)
+	resp, err = transport.RoundTrip(signedRequest(t, keys, server.URL+"/app/installations/1/access_tokens"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, int64(3), requests.Load())
+}
+
+func TestKeyFallbackTransportEveryKeyRejected(t *testing.T) {
+	dir := t.TempDir()
+	writeKey(t, dir, "a.pem", time.Now().Add(-time.Hour))
+	writeKey(t, dir, "b.pem", time.Now())
+	keys := loadKeySet(t, dir)
+	current := keys.Current().Fingerprint
+
+	unknown, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	var requests atomic.Int64
+	server := appServer(t, &unknown.PublicKey, &requests)
+	transport := &keyFallbackTransport{keys: keys, base: http.DefaultTransport}
+
+	resp, err := transport.RoundTrip(signedRequest(t, keys, server.URL+"/app"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, int64(2), requests.Load())
+	assert.Equal(t, current, keys.Current().Fingerprint)
+}
+
+func TestKeySetWatchPicksUpRotatedKeys(t *testing.T) {
+	dir := t.TempDir()
+	first := writeKey(t, dir, "first.pem", time.Now().Add(-time.Hour))
+	keys := loadKeySet(t, dir)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go keys.Watch(ctx, 10*time.Millisecond)
+
+	// A newly mounted key takes over, the old one stays active until its file is removed
+	second := writeKey(t, dir, "second.pem", time.Now())
+	require.Eventually(t, func() bool {
+		return keys.Current().Fingerprint == fingerprint(t, second)
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{fingerprint(t, second), fingerprint(t, first)}, keys.Fingerprints())
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "first.pem")))
+	require.Eventually(t, func() bool {
+		return len(keys.Fingerprints()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// An empty location keeps the current keys
+	require.NoError(t, os.Remove(filepath.Join(dir, "second.pem")))
+	assert.Error(t, keys.Reload())
+	assert.Equal(t, []string{fingerprint(t, second)}, keys.Fingerprints())
+}
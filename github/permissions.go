@@ -0,0 +1,275 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+)
+
+// Installation permission names as they appear in the installation's permissions object
+const (
+	PermissionDeployments  = "deployments"
+	PermissionStatuses     = "statuses"
+	PermissionPullRequests = "pull_requests"
+	PermissionChecks       = "checks"
+	PermissionEnvironments = "environments"
+	PermissionContents     = "contents"
+	// Administration covers creating environments, branch policies and protection rules
+	PermissionAdministration = "administration"
+)
+
+// Permission access levels, each level includes the ones below it
+const (
+	AccessRead  = "read"
+	AccessWrite = "write"
+	AccessAdmin = "admin"
+)
+
+// Permission is an installation permission an operation relies on, e.g. deployments: write
+type Permission struct {
+	Name   string
+	Access string
+}
+
+// Read requires read access to the named permission
+func Read(name string) Permission {
+	return Permission{Name: name, Access: AccessRead}
+}
+
+// Write requires write access to the named permission
+func Write(name string) Permission {
+	return Permission{Name: name, Access: AccessWrite}
+}
+
+func (p Permission) String() string {
+	return p.Name + ": " + p.Access
+}
+
+// PermissionChecker verifies an installation can serve a repository before any call is made
+type PermissionChecker interface {
+	CheckPermissions(ctx context.Context, hostName, org, repo string, required ...Permission) error
+}
+
+// InstallationCapabilities is what an App installation was granted on an organization
+type InstallationCapabilities struct {
+	Host           string
+	Owner          string
+	InstallationID int64
+	// Granted permissions by name (read, write or admin)
+	Permissions map[string]string
+	// all or selected
+	RepositorySelection string
+	// Lowercased repository names, only populated for selected repositories
+	Repositories map[string]bool
+	CheckedAt    time.Time
+}
+
+// Has reports whether the installation grants at least the required access
+func (c *InstallationCapabilities) Has(p Permission) bool {
+	return accessLevel(c.Permissions[p.Name]) >= accessLevel(p.Access)
+}
+
+// Missing returns the required permissions the installation was not granted
+func (c *InstallationCapabilities) Missing(required ...Permission) []Permission {
+	var missing []Permission
+	for _, p := range required {
+		if !c.Has(p) {
+			missing = append(missing, p)
+		}
+	}
+	return missing
+}
+
+// CanAccess reports whether the repository is included in the installation
+func (c *InstallationCapabilities) CanAccess(repo string) bool {
+	if c.RepositorySelection != "selected" {
+		return true
+	}
+	return c.Repositories[strings.ToLower(repo)]
+}
+
+func accessLevel(access string) int {
+	switch access {
+	case AccessRead:
+		return 1
+	case AccessWrite:
+		return 2
+	case AccessAdmin:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// PermissionError reports an installation that lacks a permission or repository
+// Retrying cannot help until an organization owner changes the installation
+type PermissionError struct {
+	Host    string
+	Owner   string
+	Repo    string
+	Missing []Permission
+	// Granted access for each missing permission (empty when none)
+	Granted map[string]string
+	// RepositoryNotSelected is set when the installation does not include the repository
+	RepositoryNotSelected bool
+}
+
+func (e *PermissionError) Error() string {
+	if e.RepositoryNotSelected {
+		return fmt.Sprintf("GitHub App installation for %s on host %s does not include repository %s/%s",
+			e.Owner, e.Host, e.Owner, e.Repo)
+	}
+
+	missing := make([]string, 0, len(e.Missing))
+	for _, p := range e.Missing {
+		granted := e.Granted[p.Name]
+		if granted == "" {
+			granted = "none"
+		}
+		missing = append(missing, fmt.Sprintf("%s (granted: %s)", p, granted))
+	}
+	return fmt.Sprintf("GitHub App installation for %s on host %s is missing permission %s",
+		e.Owner, e.Host, strings.Join(missing, ", "))
+}
+
+// Capabilities returns the installation's permissions and repository selection for an organization
+// Results are cached for GitHubConfig.PermissionCacheTTL
+func (f *ClientFactory) Capabilities(ctx context.Context, hostName, org string) (*InstallationCapabilities, error) {
+	h, err := f.resolveHost(hostName, org)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := h.config.Name + "/" + org
+	f.mu.Lock()
+	cached, exists := f.capabilityCache[cacheKey]
+	f.mu.Unlock()
+	if exists && time.Since(cached.CheckedAt) < f.config.PermissionCacheTTL {
+		return cached, nil
+	}
+
+	installationID, err := f.findInstallationID(ctx, h, org)
+	if err != nil {
+		return nil, err
+	}
+
+	appClient, err := f.appClient(h)
+	if err != nil {
+		return nil, err
+	}
+	installation, _, err := appClient.Apps.GetInstallation(ctx, installationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get app installation %d on %s: %w", installationID, h.describe(), err)
+	}
+
+	capabilities := &InstallationCapabilities{
+		Host:                h.config.Name,
+		Owner:               org,
+		InstallationID:      installationID,
+		Permissions:         permissionMap(installation.GetPermissions()),
+		RepositorySelection: installation.GetRepositorySelection(),
+		CheckedAt:           time.Now(),
+	}
+
+	if capabilities.RepositorySelection == "selected" {
+		repos, err := f.installationRepositories(ctx, h, installationID)
+		if err != nil {
+			return nil, err
+		}
+		capabilities.Repositories = repos
+	}
+
+	f.mu.Lock()
+	f.capabilityCache[cacheKey] = capabilities
+	f.mu.Unlock()
+
+	f.logger.Info().
+		Str("github_host", h.config.Name).
+		Str("organization", org).
+		Int64("installation_id", installationID).
+		Interface("permissions", capabilities.Permissions).
+		Str("repository_selection", capabilities.RepositorySelection).
+		Int("selected_repositories", len(capabilities.Repositories)).
+		Msg("Fetched GitHub App installation capabilities")
+
+	return capabilities, nil
+}
+
+// CheckPermissions fails with a *PermissionError when the installation for org lacks
+// a required permission or does not include repo
+func (f *ClientFactory) CheckPermissions(ctx context.Context, hostName, org, repo string, required ...Permission) error {
+	capabilities, err := f.Capabilities(ctx, hostName, org)
+	if err != nil {
+		return err
+	}
+
+	if repo != "" && !capabilities.CanAccess(repo) {
+		return &PermissionError{
+			Host:                  capabilities.Host,
+			Owner:                 org,
+			Repo:                  repo,
+			RepositoryNotSelected: true,
+		}
+	}
+
+	missing := capabilities.Missing(required...)
+	if len(missing) == 0 {
+		return nil
+	}
+
+	granted := make(map[string]string)
+	for _, p := range missing {
+		granted[p.Name] = capabilities.Permissions[p.Name]
+	}
+	return &PermissionError{
+		Host:    capabilities.Host,
+		Owner:   org,
+		Repo:    repo,
+		Missing: missing,
+		Granted: granted,
+	}
+}
+
+// installationRepositories lists the repositories a selected-repositories installation covers
+func (f *ClientFactory) installationRepositories(ctx context.Context, h *host, installationID int64) (map[string]bool, error) {
+	client, err := f.createInstallationClient(h, installationID)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make(map[string]bool)
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Apps.ListRepos(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories of installation %d on %s: %w", installationID, h.describe(), err)
+		}
+		for _, repo := range page.Repositories {
+			repos[strings.ToLower(repo.GetName())] = true
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return repos, nil
+}
+
+// permissionMap flattens go-github's permissions struct into name to access
+func permissionMap(permissions *github.InstallationPermissions) map[string]string {
+	granted := make(map[string]string)
+	if permissions == nil {
+		return granted
+	}
+	data, err := json.Marshal(permissions)
+	if err != nil {
+		return granted
+	}
+	_ = json.Unmarshal(data, &granted)
+	return granted
+}
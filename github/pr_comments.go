@@ -0,0 +1,194 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+)
+
+// DeploymentCommentMarker identifies the sticky deployment status comment on a pull request
+const DeploymentCommentMarker = "<!-- gh-deploy-wf:deployment-status -->"
+
+// EnvironmentDeployment is the latest deployment of a commit to one environment
+type EnvironmentDeployment struct {
+	Environment    string    `json:"environment"`
+	DeploymentID   int64     `json:"deployment_id"`
+	State          string    `json:"state"`
+	Description    string    `json:"description,omitempty"`
+	EnvironmentURL string    `json:"environment_url,omitempty"`
+	LogURL         string    `json:"log_url,omitempty"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// LatestDeployments returns the newest deployment of sha in every environment with its current status
+// Only sha's deployments are listed, so the comment shows the pushed commit and a new push replaces the table
+func LatestDeployments(ctx context.Context, client DeploymentAPI, owner, repo, sha string) ([]EnvironmentDeployment, error) {
+	opts := &github.DeploymentsListOptions{
+		SHA:         sha,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	// Deployments are listed newest first
+	seen := make(map[string]bool)
+	var newest []*github.Deployment
+	for {
+		deployments, resp, err := client.ListDeployments(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deployments for %s/%s@%s: %w", owner, repo, sha, err)
+		}
+		for _, deployment := range deployments {
+			if !seen[deployment.GetEnvironment()] {
+				seen[deployment.GetEnvironment()] = true
+				newest = append(newest, deployment)
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	var latest []EnvironmentDeployment
+	for _, deployment := range newest {
+		environment := deployment.GetEnvironment()
+		row := EnvironmentDeployment{
+			Environment:  environment,
+			DeploymentID: deployment.GetID(),
+			State:        "pending",
+			UpdatedAt:    deployment.GetUpdatedAt().Time,
+		}
+
+		statuses, _, err := client.ListDeploymentStatuses(ctx, owner, repo, deployment.GetID(), &github.ListOptions{PerPage: 1})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list statuses of deployment %d: %w", deployment.GetID(), err)
+		}
+		if len(statuses) > 0 {
+			status := statuses[0]
+			row.State = status.GetState()
+			row.Description = status.GetDescription()
+			row.EnvironmentURL = status.GetEnvironmentURL()
+			row.LogURL = status.GetLogURL()
+			row.UpdatedAt = status.GetUpdatedAt().Time
+		}
+		latest = append(latest, row)
+	}
+
+	sort.Slice(latest, func(i, j int) bool {
+		return latest[i].Environment < latest[j].Environment
+	})
+	return latest, nil
+}
+
+// RenderDeploymentComment renders the sticky comment body for a commit's deployments
+func RenderDeploymentComment(sha string, deployments []EnvironmentDeployment) string {
+	var b strings.Builder
+	b.WriteString(DeploymentCommentMarker + "\n")
+	fmt.Fprintf(&b, "### Deployments for %s\n\n", shortCommit(sha))
+
+	if len(deployments) == 0 {
+		b.WriteString("No deployments yet.\n")
+		return b.String()
+	}
+
+	b.WriteString("| Environment | State | Environment URL | Logs | Updated |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	for _, d := range deployments {
+		fmt.Fprintf(&b, "| %s | %s %s | %s | %s | %s |\n",
+			escapeCell(d.Environment),
+			stateEmoji(d.State), d.State,
+			markdownLink("Open", d.EnvironmentURL),
+			markdownLink("Logs", d.LogURL),
+			d.UpdatedAt.UTC().Format("2006-01-02 15:04 UTC"),
+		)
+	}
+	return b.String()
+}
+
+// UpsertStickyComment edits the pull request comment containing marker, or creates it
+// Only comments written by author, the App's bot login, are edited, anyone can paste the marker
+func UpsertStickyComment(ctx context.Context, client *github.Client, owner, repo string, number int, author, marker, body string) (int64, bool, error) {
+	opts := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := client.Issues.ListComments(ctx, owner, repo, number, opts)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to list comments of %s/%s#%d: %w", owner, repo, number, err)
+		}
+		for _, comment := range comments {
+			if !strings.EqualFold(comment.GetUser().GetLogin(), author) || !strings.Contains(comment.GetBody(), marker) {
+				continue
+			}
+			if comment.GetBody() == body {
+				return comment.GetID(), false, nil
+			}
+			if _, _, err := client.Issues.EditComment(ctx, owner, repo, comment.GetID(), &github.IssueComment{Body: github.String(body)}); err != nil {
+				return 0, false, fmt.Errorf("failed to edit comment %d on %s/%s#%d: %w", comment.GetID(), owner, repo, number, err)
+			}
+			return comment.GetID(), false, nil
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	comment, _, err := client.Issues.CreateComment(ctx, owner, repo, number, &github.IssueComment{Body: github.String(body)})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to comment on %s/%s#%d: %w", owner, repo, number, err)
+	}
+	return comment.GetID(), true, nil
+}
+
+// OpenPullRequestsForCommit returns the numbers of open pull requests containing sha
+func OpenPullRequestsForCommit(ctx context.Context, client *github.Client, owner, repo, sha string) ([]int, error) {
+	pulls, _, err := client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pull requests for %s/%s@%s: %w", owner, repo, sha, err)
+	}
+
+	var numbers []int
+	for _, pull := range pulls {
+		if pull.GetState() == "open" {
+			numbers = append(numbers, pull.GetNumber())
+		}
+	}
+	return numbers, nil
+}
+
+func stateEmoji(state string) string {
+	switch state {
+	case "success":
+		return "✅"
+	case "failure", "error":
+		return "❌"
+	case "in_progress":
+		return "🚧"
+	case "queued", "pending":
+		return "⏳"
+	case "inactive":
+		return "💤"
+	default:
+		return "❔"
+	}
+}
+
+func markdownLink(text, url string) string {
+	if url == "" {
+		return "—"
+	}
+	return fmt.Sprintf("[%s](%s)", text, url)
+}
+
+func escapeCell(value string) string {
+	return strings.ReplaceAll(value, "|", "\\|")
+}
+
+func shortCommit(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,97 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertStickyCommentOnlyEditsOwnComment(t *testing.T) {
+	var edited []string
+	created := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/web/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.IssueComment{
+			{ID: github.Int64(1), User: &github.User{Login: github.String("octocat")}, Body: github.String("copied " + DeploymentCommentMarker)},
+			{ID: github.Int64(2), User: &github.User{Login: github.String("gh-deploy-wf[bot]")}, Body: github.String(DeploymentCommentMarker + "\nold")},
+		})
+	})
+	mux.HandleFunc("PATCH /repos/acme/web/issues/comments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		edited = append(edited, r.PathValue("id"))
+		_ = json.NewEncoder(w).Encode(&github.IssueComment{ID: github.Int64(2)})
+	})
+	mux.HandleFunc("POST /repos/acme/web/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		created++
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(&github.IssueComment{ID: github.Int64(3)})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	body := DeploymentCommentMarker + "\nnew"
+	id, isNew, err := UpsertStickyComment(context.Background(), client, "acme", "web", 7, "gh-deploy-wf[bot]", DeploymentCommentMarker, body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), id)
+	assert.False(t, isNew)
+	assert.Equal(t, []string{"2"}, edited)
+
+	// Another App's comment is never taken over
+	id, isNew, err = UpsertStickyComment(context.Background(), client, "acme", "web", 7, "other-app[bot]", DeploymentCommentMarker, body)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), id)
+	assert.True(t, isNew)
+	assert.Equal(t, 1, created)
+	assert.Equal(t, []string{"2"}, edited)
+}
+
+func TestLatestDeploymentsPagesThroughDeployments(t *testing.T) {
+	var pages []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /repos/acme/web/deployments", func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		assert.Equal(t, "abc123", r.URL.Query().Get("sha"))
+		if page == "" {
+			w.Header().Set("Link", `<`+r.URL.Path+`?page=2>; rel="next"`)
+			_ = json.NewEncoder(w).Encode([]*github.Deployment{
+				{ID: github.Int64(3), Environment: github.String("staging")},
+				{ID: github.Int64(2), Environment: github.String("staging")},
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode([]*github.Deployment{
+			{ID: github.Int64(1), Environment: github.String("production")},
+		})
+	})
+	mux.HandleFunc("GET /repos/acme/web/deployments/{id}/statuses", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]*github.DeploymentStatus{
+			{State: github.String("success"), Description: github.String("deployment " + r.PathValue("id"))},
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+
+	latest, err := LatestDeployments(context.Background(), client.Repositories, "acme", "web", "abc123")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", "2"}, pages)
+	require.Len(t, latest, 2)
+	assert.Equal(t, "production", latest[0].Environment)
+	assert.Equal(t, int64(1), latest[0].DeploymentID)
+	assert.Equal(t, "staging", latest[1].Environment)
+	assert.Equal(t, "deployment 3", latest[1].Description)
+}
@@ -0,0 +1,54 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Review states accepted by a deployment protection rule callback
+const (
+	ProtectionRuleApproved = "approved"
+	ProtectionRuleRejected = "rejected"
+)
+
+// protectionRuleReview is the body POSTed to a deployment_callback_url
+type protectionRuleReview struct {
+	EnvironmentName string `json:"environment_name"`
+	State           string `json:"state"`
+	Comment         string `json:"comment,omitempty"`
+}
+
+// ReviewProtectionRule approves or rejects a deployment waiting on this App's custom protection rule
+// callbackURL is the deployment_callback_url of the deployment_protection_rule webhook
+func (f *ClientFactory) ReviewProtectionRule(ctx context.Context, hostName, org, callbackURL, environment, state, comment string) error {
+	if state != ProtectionRuleApproved && state != ProtectionRuleRejected {
+		return fmt.Errorf("invalid protection rule review state %q", state)
+	}
+
+	client, err := f.CreateClientForHost(ctx, hostName, org)
+	if err != nil {
+		return err
+	}
+
+	req, err := client.NewRequest(http.MethodPost, callbackURL, &protectionRuleReview{
+		EnvironmentName: environment,
+		State:           state,
+		Comment:         comment,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create protection rule review request: %w", err)
+	}
+
+	if _, err := client.Do(ctx, req, nil); err != nil {
+		return fmt.Errorf("failed to %s deployment to %s: %w", reviewVerb(state), environment, err)
+	}
+	return nil
+}
+
+func reviewVerb(state string) string {
+	if state == ProtectionRuleApproved {
+		return "approve"
+	}
+	return "reject"
+}
@@ -0,0 +1,58 @@
+package github
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// CommitPullRequests returns the pull requests containing sha, open ones first, newest first within a state
+func CommitPullRequests(ctx context.Context, client *github.Client, owner, repo, sha string) ([]payload.PullRequestContext, error) {
+	var pulls []payload.PullRequestContext
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.PullRequests.ListPullRequestsWithCommit(ctx, owner, repo, sha, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pull requests for %s/%s@%s: %w", owner, repo, sha, err)
+		}
+		for _, pull := range page {
+			pulls = append(pulls, pullRequestContext(pull))
+		}
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	sort.SliceStable(pulls, func(i, j int) bool {
+		if open := pulls[i].State == "open"; open != (pulls[j].State == "open") {
+			return open
+		}
+		return pulls[i].Number > pulls[j].Number
+	})
+	return pulls, nil
+}
+
+// pullRequestContext extracts the deployment context of a pull request
+func pullRequestContext(pull *github.PullRequest) payload.PullRequestContext {
+	state := pull.GetState()
+	if pull.MergedAt != nil {
+		state = "merged"
+	}
+	result := payload.PullRequestContext{
+		Number:     pull.GetNumber(),
+		Title:      pull.GetTitle(),
+		HeadBranch: pull.GetHead().GetRef(),
+		Author:     pull.GetUser().GetLogin(),
+		State:      state,
+		URL:        pull.GetHTMLURL(),
+	}
+	for _, label := range pull.Labels {
+		result.Labels = append(result.Labels, label.GetName())
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/rs/zerolog"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// RateLimitRetrier repeats REST reads that hit the primary or secondary rate limit or a 5xx
+// Primary limits wait for the reset, everything else backs off per GITHUB_RATE_LIMIT_*
+type RateLimitRetrier struct {
+	config config.RateLimitConfig
+	logger zerolog.Logger
+}
+
+// NewRateLimitRetrier creates a retrier from the rate limit configuration
+func NewRateLimitRetrier(cfg config.RateLimitConfig, logger zerolog.Logger) *RateLimitRetrier {
+	return &RateLimitRetrier{config: cfg, logger: logger}
+}
+
+// Do calls fn until it succeeds, fails with a non-retryable error or runs out of retries
+func (r *RateLimitRetrier) Do(ctx context.Context, fn func() (*github.Response, error)) error {
+	backoff := r.config.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := fn()
+		if err == nil {
+			return nil
+		}
+
+		wait, retryable := r.retryAfter(err, resp, backoff)
+		if !retryable || attempt >= r.config.MaxRetries {
+			return err
+		}
+
+		r.logger.Warn().
+			Err(err).
+			Int("attempt", attempt+1).
+			Dur("wait", wait).
+			Msg("GitHub read throttled or failed, retrying")
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		backoff = time.Duration(float64(backoff) * r.config.BackoffMultiplier)
+		if backoff > r.config.MaxBackoff {
+			backoff = r.config.MaxBackoff
+		}
+	}
+}
+
+// retryAfter decides whether err is worth retrying and how long to wait first
+func (r *RateLimitRetrier) retryAfter(err error, resp *github.Response, backoff time.Duration) (time.Duration, bool) {
+	var rateLimitErr *github.RateLimitError
+	if errors.As(err, &rateLimitErr) {
+		// The primary limit only recovers at the reset, however long that is
+		wait := time.Until(rateLimitErr.Rate.Reset.Time) + time.Second
+		if wait < backoff {
+			wait = backoff
+		}
+		return wait, true
+	}
+
+	var abuseErr *github.AbuseRateLimitError
+	if errors.As(err, &abuseErr) {
+		if retryAfter := abuseErr.GetRetryAfter(); retryAfter > 0 {
+			return retryAfter, true
+		}
+		return backoff, true
+	}
+
+	if resp != nil && resp.StatusCode >= http.StatusInternalServerError {
+		return backoff, true
+	}
+	return 0, false
+}
+
+// RateLimitRetrier returns a retrier using the factory's GITHUB_RATE_LIMIT_* settings
+func (f *ClientFactory) RateLimitRetrier() *RateLimitRetrier {
+	return NewRateLimitRetrier(f.config.RateLimit, f.logger)
+}
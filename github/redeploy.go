@@ -0,0 +1,48 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v58/github"
+)
+
+// LatestDeployment returns the newest deployment to an environment
+func LatestDeployment(ctx context.Context, client DeploymentAPI, owner, repo, environment string) (*github.Deployment, error) {
+	deployments, _, err := client.ListDeployments(ctx, owner, repo, &github.DeploymentsListOptions{
+		Environment: environment,
+		ListOptions: github.ListOptions{PerPage: 1},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s deployments for %s/%s: %w", environment, owner, repo, err)
+	}
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("%w for %s/%s in %s environment", ErrDeploymentNotFound, owner, repo, environment)
+	}
+	return deployments[0], nil
+}
+
+// LastActiveStatus returns the newest status of a deployment and the newest one that isn't inactive
+// Either is nil when the deployment has no such status
+func LastActiveStatus(ctx context.Context, client DeploymentAPI, owner, repo string, deploymentID int64) (latest, active *github.DeploymentStatus, err error) {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		statuses, resp, err := client.ListDeploymentStatuses(ctx, owner, repo, deploymentID, opts)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to list statuses of deployment %d: %w", deploymentID, err)
+		}
+		// GitHub lists statuses newest first
+		for _, status := range statuses {
+			if latest == nil {
+				latest = status
+			}
+			if status.GetState() != "inactive" {
+				return latest, status, nil
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return latest, nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
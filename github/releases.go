@@ -0,0 +1,351 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// Limits on what a single release reads back from GitHub
+const (
+	maxReleaseCommits      = 1000
+	maxReleasePullRequests = 50
+)
+
+// ReleaseTagData is the data available to a release tag template
+type ReleaseTagData struct {
+	Environment  string
+	DeploymentID int64
+	SHA          string
+	ShortSHA     string
+	Date         string // YYYY.MM.DD in UTC
+}
+
+// NewReleaseTagData describes a deployment for the tag template
+func NewReleaseTagData(deployment *github.Deployment, at time.Time) ReleaseTagData {
+	return ReleaseTagData{
+		Environment:  deployment.GetEnvironment(),
+		DeploymentID: deployment.GetID(),
+		SHA:          deployment.GetSHA(),
+		ShortSHA:     shortCommit(deployment.GetSHA()),
+		Date:         at.UTC().Format("2006.01.02"),
+	}
+}
+
+// ReleaseTagName renders a tag name from a text/template
+func ReleaseTagName(tmpl string, data ReleaseTagData) (string, error) {
+	parsed, err := template.New("tag").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid release tag template: %w", err)
+	}
+	var b bytes.Buffer
+	if err := parsed.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render release tag: %w", err)
+	}
+	name := strings.TrimSpace(b.String())
+	if name == "" || strings.ContainsAny(name, " ~^:?*[\\") || strings.Contains(name, "..") {
+		return "", fmt.Errorf("release tag template rendered invalid tag name %q", name)
+	}
+	return name, nil
+}
+
+// PreviousSuccessfulDeployment returns the newest deployment to the environment created before
+// current that ever reached success, nil when there is none
+func PreviousSuccessfulDeployment(ctx context.Context, client DeploymentAPI, owner, repo string, current *github.Deployment) (*github.Deployment, error) {
+	return LastSuccessfulDeployment(ctx, client, owner, repo, current.GetEnvironment(), current.GetCreatedAt().Time, current.GetID())
+}
+
+// LastSuccessfulDeployment returns the newest application deployment to the environment that ever reached
+// success, created before the given time unless it is zero and other than exclude, nil when there is none
+// Earlier successes are usually inactive by now, so the whole timeline is checked, not only the latest status
+func LastSuccessfulDeployment(ctx context.Context, client DeploymentAPI, owner, repo, environment string, before time.Time, exclude int64) (*github.Deployment, error) {
+	opts := &github.DeploymentsListOptions{
+		Environment: environment,
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+	for {
+		deployments, resp, err := client.ListDeployments(ctx, owner, repo, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s deployments for %s/%s: %w", environment, owner, repo, err)
+		}
+		for _, deployment := range deployments {
+			if deployment.GetID() == exclude || !config.IsApplicationTask(deployment.GetTask()) ||
+				(!before.IsZero() && !deployment.GetCreatedAt().Time.Before(before)) {
+				continue
+			}
+			succeeded, err := everSucceeded(ctx, client, owner, repo, deployment.GetID())
+			if err != nil {
+				return nil, err
+			}
+			if succeeded {
+				return deployment, nil
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return nil, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// everSucceeded reports whether any status of the deployment is success
+func everSucceeded(ctx context.Context, client DeploymentAPI, owner, repo string, deploymentID int64) (bool, error) {
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		statuses, resp, err := client.ListDeploymentStatuses(ctx, owner, repo, deploymentID, opts)
+		if err != nil {
+			return false, fmt.Errorf("failed to list statuses of deployment %d: %w", deploymentID, err)
+		}
+		for _, status := range statuses {
+			if status.GetState() == "success" {
+				return true, nil
+			}
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return false, nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
+
+// ReleaseCommit is one commit shipped by a release
+type ReleaseCommit struct {
+	SHA     string `json:"sha"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	HTMLURL string `json:"html_url,omitempty"`
+}
+
+// ReleasePullRequest is one merged pull request shipped by a release
+type ReleasePullRequest struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Author  string `json:"author"`
+	HTMLURL string `json:"html_url,omitempty"`
+}
+
+// ReleaseChanges is what changed between two deployed commits
+type ReleaseChanges struct {
+	BaseSHA      string               `json:"base_sha"`
+	HeadSHA      string               `json:"head_sha"`
+	CompareURL   string               `json:"compare_url,omitempty"`
+	TotalCommits int                  `json:"total_commits"`
+	Commits      []ReleaseCommit      `json:"commits"`
+	PullRequests []ReleasePullRequest `json:"pull_requests"`
+	Authors      []string             `json:"authors"` // Commit authors, sorted
+}
+
+// Merge commits and squash merges name their pull request in the first line
+var pullRequestReference = regexp.MustCompile(`^Merge pull request #(\d+)|\(#(\d+)\)$`)
+
+// CompareReleaseChanges lists the commits and merged pull requests from base to head
+func CompareReleaseChanges(ctx context.Context, client *github.Client, owner, repo, base, head string) (*ReleaseChanges, error) {
+	changes := &ReleaseChanges{BaseSHA: base, HeadSHA: head}
+
+	var numbers []int
+	seen := make(map[int]bool)
+	authors := make(map[string]bool)
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		comparison, resp, err := client.Repositories.CompareCommits(ctx, owner, repo, base, head, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare %s...%s in %s/%s: %w", shortCommit(base), shortCommit(head), owner, repo, err)
+		}
+		changes.CompareURL = comparison.GetHTMLURL()
+		changes.TotalCommits = comparison.GetTotalCommits()
+
+		for _, commit := range comparison.Commits {
+			title, _, _ := strings.Cut(commit.GetCommit().GetMessage(), "\n")
+			author := commit.GetAuthor().GetLogin()
+			if author == "" {
+				author = commit.GetCommit().GetAuthor().GetName()
+			}
+			if author != "" && !authors[author] {
+				authors[author] = true
+				changes.Authors = append(changes.Authors, author)
+			}
+			changes.Commits = append(changes.Commits, ReleaseCommit{
+				SHA:     commit.GetSHA(),
+				Title:   title,
+				Author:  author,
+				HTMLURL: commit.GetHTMLURL(),
+			})
+
+			if match := pullRequestReference.FindStringSubmatch(title); match != nil {
+				number, _ := strconv.Atoi(match[1] + match[2])
+				if number > 0 && !seen[number] {
+					seen[number] = true
+					numbers = append(numbers, number)
+				}
+			}
+		}
+
+		if resp == nil || resp.NextPage == 0 || len(changes.Commits) >= maxReleaseCommits {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	sort.Strings(changes.Authors)
+
+	if len(numbers) > maxReleasePullRequests {
+		numbers = numbers[len(numbers)-maxReleasePullRequests:]
+	}
+	for _, number := range numbers {
+		pr, _, err := client.PullRequests.Get(ctx, owner, repo, number)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get pull request #%d in %s/%s: %w", number, owner, repo, err)
+		}
+		if !pr.GetMerged() {
+			continue
+		}
+		changes.PullRequests = append(changes.PullRequests, ReleasePullRequest{
+			Number:  number,
+			Title:   pr.GetTitle(),
+			Author:  pr.GetUser().GetLogin(),
+			HTMLURL: pr.GetHTMLURL(),
+		})
+	}
+
+	return changes, nil
+}
+
+// RenderReleaseNotes renders the release body: the deployment, the Harness execution and the changes
+// since the previous successful deployment, changes is nil for the first release of an environment
+func RenderReleaseNotes(deployment *github.Deployment, logURL string, previous *github.Deployment, changes *ReleaseChanges) string {
+	payload := DeploymentPayload(deployment)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Deployed to **%s** as deployment %d.\n\n", deployment.GetEnvironment(), deployment.GetID())
+	fmt.Fprintf(&b, "- **Commit:** `%s`\n", shortCommit(deployment.GetSHA()))
+	if executionID := payload.ExecutionID(); executionID != "" {
+		if logURL != "" {
+			fmt.Fprintf(&b, "- **Harness execution:** [%s](%s)\n", executionID, logURL)
+		} else {
+			fmt.Fprintf(&b, "- **Harness execution:** %s\n", executionID)
+		}
+	}
+	for _, artifact := range payload.Artifacts {
+		if artifact.Digest != "" {
+			fmt.Fprintf(&b, "- **Image:** `%s` (`%s`)\n", artifact.Image, artifact.Digest)
+		} else {
+			fmt.Fprintf(&b, "- **Image:** `%s`\n", artifact.Image)
+		}
+	}
+	if previous != nil {
+		fmt.Fprintf(&b, "- **Previous deployment:** %d (`%s`)\n", previous.GetID(), shortCommit(previous.GetSHA()))
+	}
+
+	if changes == nil {
+		b.WriteString("\nFirst release of this environment, no previous successful deployment to compare with.\n")
+		return b.String()
+	}
+
+	if len(changes.PullRequests) > 0 {
+		b.WriteString("\n## Pull requests\n\n")
+		for _, pr := range changes.PullRequests {
+			fmt.Fprintf(&b, "- %s (#%d) by @%s\n", pr.Title, pr.Number, pr.Author)
+		}
+	}
+
+	b.WriteString("\n## Commits\n\n")
+	if len(changes.Commits) == 0 {
+		b.WriteString("No new commits since the previous deployment.\n")
+	}
+	for _, commit := range changes.Commits {
+		fmt.Fprintf(&b, "- `%s` %s (%s)\n", shortCommit(commit.SHA), commit.Title, commit.Author)
+	}
+	if changes.TotalCommits > len(changes.Commits) {
+		fmt.Fprintf(&b, "- … and %d more\n", changes.TotalCommits-len(changes.Commits))
+	}
+	if changes.CompareURL != "" {
+		fmt.Fprintf(&b, "\n**Full changelog:** %s\n", changes.CompareURL)
+	}
+	return b.String()
+}
+
+// EnsureReleaseTag creates an annotated tag on sha unless it already exists there
+// A tag with the same name on another commit is an error, tags are never moved
+func EnsureReleaseTag(ctx context.Context, client *github.Client, owner, repo, tag, sha, message string) (bool, error) {
+	ref, resp, err := client.Git.GetRef(ctx, owner, repo, "tags/"+tag)
+	if err == nil {
+		target := ref.GetObject().GetSHA()
+		if ref.GetObject().GetType() == "tag" {
+			annotated, _, err := client.Git.GetTag(ctx, owner, repo, target)
+			if err != nil {
+				return false, fmt.Errorf("failed to get tag %s in %s/%s: %w", tag, owner, repo, err)
+			}
+			target = annotated.GetObject().GetSHA()
+		}
+		if target != sha {
+			return false, fmt.Errorf("tag %s in %s/%s already points to %s, not %s", tag, owner, repo, shortCommit(target), shortCommit(sha))
+		}
+		return false, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return false, fmt.Errorf("failed to get tag %s in %s/%s: %w", tag, owner, repo, err)
+	}
+
+	annotated, _, err := client.Git.CreateTag(ctx, owner, repo, &github.Tag{
+		Tag:     github.String(tag),
+		Message: github.String(message),
+		Object: &github.GitObject{
+			Type: github.String("commit"),
+			SHA:  github.String(sha),
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create tag %s in %s/%s: %w", tag, owner, repo, err)
+	}
+
+	_, _, err = client.Git.CreateRef(ctx, owner, repo, &github.Reference{
+		Ref:    github.String("refs/tags/" + tag),
+		Object: &github.GitObject{SHA: annotated.SHA},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to create ref for tag %s in %s/%s: %w", tag, owner, repo, err)
+	}
+	return true, nil
+}
+
+// EnsureRelease creates the GitHub Release of a tag unless one exists
+// Draft releases can't be fetched by tag, so they are searched in the newest releases
+func EnsureRelease(ctx context.Context, client *github.Client, owner, repo string, release *github.RepositoryRelease) (*github.RepositoryRelease, bool, error) {
+	tag := release.GetTagName()
+	existing, resp, err := client.Repositories.GetReleaseByTag(ctx, owner, repo, tag)
+	if err == nil {
+		return existing, false, nil
+	}
+	if resp == nil || resp.StatusCode != http.StatusNotFound {
+		return nil, false, fmt.Errorf("failed to get release %s in %s/%s: %w", tag, owner, repo, err)
+	}
+
+	if release.GetDraft() {
+		releases, _, err := client.Repositories.ListReleases(ctx, owner, repo, &github.ListOptions{PerPage: 100})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to list releases in %s/%s: %w", owner, repo, err)
+		}
+		for _, candidate := range releases {
+			if candidate.GetTagName() == tag {
+				return candidate, false, nil
+			}
+		}
+	}
+
+	created, _, err := client.Repositories.CreateRelease(ctx, owner, repo, release)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create release %s in %s/%s: %w", tag, owner, repo, err)
+	}
+	return created, true, nil
+}
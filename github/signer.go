@@ -0,0 +1,190 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/rs/zerolog"
+)
+
+// AppSigner signs GitHub App JWTs for the app-auth path of ClientFactory
+// ClientFactory uses *KeySet (PEM files) or SidecarSigner (HTTP signing sidecar);
+// CryptoSigner wraps a KMS/PKCS#11 crypto.Signer for use with ghinstallation.WithSigner
+type AppSigner interface {
+	// Sign signs the JWT claims with RS256 (implements ghinstallation.Signer)
+	Sign(claims jwt.Claims) (string, error)
+	// KeyID identifies the signing key in logs, e.g. a fingerprint or KMS key ARN
+	KeyID() string
+}
+
+// KeyID returns the fingerprint of the current key
+func (ks *KeySet) KeyID() string {
+	if key := ks.Current(); key != nil {
+		return key.Fingerprint
+	}
+	return ""
+}
+
+// signingInput returns the unsigned "header.payload" part of an RS256 JWT
+func signingInput(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SigningString()
+}
+
+// CryptoSigner signs App JWTs with a crypto.Signer whose private key stays in
+// an HSM or cloud KMS, as exposed by PKCS#11 and KMS client libraries
+type CryptoSigner struct {
+	signer crypto.Signer
+	keyID  string
+}
+
+// NewCryptoSigner wraps an RSA crypto.Signer, keyID is used for logging
+func NewCryptoSigner(signer crypto.Signer, keyID string) (*CryptoSigner, error) {
+	publicKey, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App JWTs require an RSA key, got %T", signer.Public())
+	}
+	if keyID == "" {
+		fingerprint, err := publicKeyFingerprint(publicKey)
+		if err != nil {
+			return nil, err
+		}
+		keyID = fingerprint
+	}
+	return &CryptoSigner{signer: signer, keyID: keyID}, nil
+}
+
+// Sign hashes the signing input and has the crypto.Signer produce a PKCS#1 v1.5 signature
+func (s *CryptoSigner) Sign(claims jwt.Claims) (string, error) {
+	input, err := signingInput(claims)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(input))
+
+	signature, err := s.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("remote signer %s failed: %w", s.keyID, err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// KeyID returns the configured key identifier
+func (s *CryptoSigner) KeyID() string {
+	return s.keyID
+}
+
+// SignRequest is the body POSTed to <sidecar>/v1/sign
+type SignRequest struct {
+	Algorithm string `json:"algorithm"` // always RS256
+	Digest    string `json:"digest"`    // base64 SHA-256 of the JWT signing input
+}
+
+// SignResponse is the sidecar's answer to a SignRequest
+type SignResponse struct {
+	Signature string `json:"signature"` // base64 PKCS#1 v1.5 signature
+	KeyID     string `json:"key_id,omitempty"`
+}
+
+// SidecarSigner signs App JWTs through an HTTP signing sidecar
+// Only the SHA-256 digest of the signing input is sent, never the claims or the key
+type SidecarSigner struct {
+	url        string
+	token      string
+	httpClient *http.Client
+	logger     zerolog.Logger
+
+	mu    sync.RWMutex
+	keyID string
+}
+
+// NewSidecarSigner creates a signer for the sidecar at baseURL
+func NewSidecarSigner(baseURL string, token []byte, keyID string, timeout time.Duration, logger zerolog.Logger) *SidecarSigner {
+	return &SidecarSigner{
+		url:        strings.TrimSuffix(baseURL, "/") + "/v1/sign",
+		token:      strings.TrimSpace(string(token)),
+		keyID:      keyID,
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
+	}
+}
+
+// Sign sends the digest of the signing input to the sidecar and assembles the JWT
+func (s *SidecarSigner) Sign(claims jwt.Claims) (string, error) {
+	input, err := signingInput(claims)
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256([]byte(input))
+
+	body, err := json.Marshal(SignRequest{
+		Algorithm: "RS256",
+		Digest:    base64.StdEncoding.EncodeToString(digest[:]),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// ghinstallation.Signer has no context, the client timeout bounds the call
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create signing request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("signing sidecar request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("signing sidecar returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+
+	var signed SignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signed); err != nil {
+		return "", fmt.Errorf("failed to decode signing sidecar response: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(signed.Signature)
+	if err != nil {
+		return "", fmt.Errorf("signing sidecar returned an invalid signature: %w", err)
+	}
+
+	// Follow key rotations on the sidecar side
+	s.mu.Lock()
+	if signed.KeyID != "" && signed.KeyID != s.keyID {
+		s.logger.Info().
+			Str("previous_key_id", s.keyID).
+			Str("key_id", signed.KeyID).
+			Msg("Signing sidecar reported a new key")
+		s.keyID = signed.KeyID
+	}
+	s.mu.Unlock()
+
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// KeyID returns the configured key identifier, or the last one the sidecar reported
+func (s *SidecarSigner) KeyID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.keyID
+}
@@ -0,0 +1,96 @@
+package github_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v4"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/github/fake"
+)
+
+func appClaims() jwt.RegisteredClaims {
+	now := time.Now()
+	return jwt.RegisteredClaims{
+		Issuer:    "12345",
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}
+}
+
+// verify checks the token is an RS256 JWT signed by key and returns its issuer
+func verify(t *testing.T, token string, key *rsa.PublicKey) string {
+	t.Helper()
+	claims := &jwt.RegisteredClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(token *jwt.Token) (interface{}, error) {
+		return key, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	require.NoError(t, err)
+	require.True(t, parsed.Valid)
+	return claims.Issuer
+}
+
+func TestCryptoSigner(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	signer, err := githubClient.NewCryptoSigner(key, "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, signer.KeyID(), "the key ID defaults to the public key fingerprint")
+
+	token, err := signer.Sign(appClaims())
+	require.NoError(t, err)
+	assert.Equal(t, "12345", verify(t, token, &key.PublicKey))
+
+	named, err := githubClient.NewCryptoSigner(key, "arn:aws:kms:us-east-1:111122223333:key/app")
+	require.NoError(t, err)
+	assert.Equal(t, "arn:aws:kms:us-east-1:111122223333:key/app", named.KeyID())
+}
+
+func TestCryptoSignerRequiresRSA(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = githubClient.NewCryptoSigner(key, "")
+	assert.ErrorContains(t, err, "require an RSA key")
+}
+
+func TestSidecarSigner(t *testing.T) {
+	server, err := fake.NewSignerServer(nil)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	server.Token = "sidecar-token"
+
+	signer := githubClient.NewSidecarSigner(server.URL+"/", []byte("sidecar-token\n"), "configured", time.Second, zerolog.Nop())
+	token, err := signer.Sign(appClaims())
+	require.NoError(t, err)
+	assert.Equal(t, "12345", verify(t, token, server.PublicKey()))
+	assert.Equal(t, "fake-signer", signer.KeyID(), "the key the sidecar reports replaces the configured one")
+
+	// A key rotated on the sidecar is followed
+	server.KeyID = "rotated"
+	_, err = signer.Sign(appClaims())
+	require.NoError(t, err)
+	assert.Equal(t, "rotated", signer.KeyID())
+	assert.Equal(t, int64(2), server.Requests())
+}
+
+func TestSidecarSignerRejected(t *testing.T) {
+	server, err := fake.NewSignerServer(nil)
+	require.NoError(t, err)
+	t.Cleanup(server.Close)
+	server.Token = "sidecar-token"
+
+	signer := githubClient.NewSidecarSigner(server.URL, []byte("wrong"), "configured", time.Second, zerolog.Nop())
+	_, err = signer.Sign(appClaims())
+	assert.ErrorContains(t, err, "status 401")
+	assert.Equal(t, "configured", signer.KeyID())
+}
@@ -0,0 +1,113 @@
+package github
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-github/v58/github"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// PullRequestOf returns the pull request a deployment belongs to, 0 when it has none
+// The payload's pull_request wins, deployments to a pr-<n> environment belong to PR n
+func PullRequestOf(deployment *github.Deployment) int {
+	if number := DeploymentPayload(deployment).PullRequest; number > 0 {
+		return number
+	}
+	return config.PRNumberFromEnvironment(deployment.GetEnvironment())
+}
+
+// PullRequestDeployments lists every deployment in the repository belonging to the pull request
+func PullRequestDeployments(ctx context.Context, client DeploymentAPI, owner, repo string, number int) ([]*github.Deployment, error) {
+	var matched []*github.Deployment
+	err := eachDeployment(ctx, client, owner, repo, func(deployment *github.Deployment) {
+		if PullRequestOf(deployment) == number {
+			matched = append(matched, deployment)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matched, nil
+}
+
+// ActivePullRequests returns the pull requests whose newest deployment is not inactive yet
+// Teardown deactivates a pull request's deployments oldest first, so only the newest one needs a status lookup
+func ActivePullRequests(ctx context.Context, client DeploymentAPI, owner, repo string) ([]int, error) {
+	// Deployments are listed newest first
+	seen := make(map[int]bool)
+	var newest []*github.Deployment
+	err := eachDeployment(ctx, client, owner, repo, func(deployment *github.Deployment) {
+		number := PullRequestOf(deployment)
+		if number > 0 && !seen[number] {
+			seen[number] = true
+			newest = append(newest, deployment)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var numbers []int
+	for _, deployment := range newest {
+		state, err := latestDeploymentState(ctx, client, owner, repo, deployment.GetID())
+		if err != nil {
+			return nil, err
+		}
+		if state != "inactive" {
+			numbers = append(numbers, PullRequestOf(deployment))
+		}
+	}
+	return numbers, nil
+}
+
+// DeactivateDeployment marks a deployment inactive, returning false when it already was
+func DeactivateDeployment(ctx context.Context, client DeploymentAPI, owner, repo string, deploymentID int64, description string) (bool, error) {
+	state, err := latestDeploymentState(ctx, client, owner, repo, deploymentID)
+	if err != nil {
+		return false, err
+	}
+	if state == "inactive" {
+		return false, nil
+	}
+
+	_, _, err = client.CreateDeploymentStatus(ctx, owner, repo, deploymentID, &github.DeploymentStatusRequest{
+		State:       github.String("inactive"),
+		Description: github.String(description),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to mark deployment %d inactive: %w", deploymentID, err)
+	}
+	return true, nil
+}
+
+// latestDeploymentState returns the state of the newest status, pending when there is none
+func latestDeploymentState(ctx context.Context, client DeploymentAPI, owner, repo string, deploymentID int64) (string, error) {
+	statuses, _, err := client.ListDeploymentStatuses(ctx, owner, repo, deploymentID, &github.ListOptions{PerPage: 1})
+	if err != nil {
+		return "", fmt.Errorf("failed to list statuses of deployment %d: %w", deploymentID, err)
+	}
+	if len(statuses) == 0 {
+		return "pending", nil
+	}
+	return statuses[0].GetState(), nil
+}
+
+// eachDeployment pages through all deployments of a repository
+func eachDeployment(ctx context.Context, client DeploymentAPI, owner, repo string, fn func(*github.Deployment)) error {
+	opts := &github.DeploymentsListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		deployments, resp, err := client.ListDeployments(ctx, owner, repo, opts)
+		if err != nil {
+			return fmt.Errorf("failed to list deployments for %s/%s: %w", owner, repo, err)
+		}
+		for _, deployment := range deployments {
+			fn(deployment)
+		}
+		if resp == nil || resp.NextPage == 0 {
+			return nil
+		}
+		opts.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,63 @@
+package github_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/github/fake"
+)
+
+// statusCounter counts the status lookups made through it
+type statusCounter struct {
+	*fake.Deployments
+	lookups int
+}
+
+func (c *statusCounter) ListDeploymentStatuses(ctx context.Context, owner, repo string, deploymentID int64, opts *github.ListOptions) ([]*github.DeploymentStatus, *github.Response, error) {
+	c.lookups++
+	return c.Deployments.ListDeploymentStatuses(ctx, owner, repo, deploymentID, opts)
+}
+
+func TestActivePullRequestsChecksNewestDeploymentOnly(t *testing.T) {
+	d := fake.NewDeployments()
+	deploy(t, d, "abc123", "production", "success")
+	for i := 0; i < 5; i++ {
+		deploy(t, d, "def456", "pr-7", "success")
+	}
+	deploy(t, d, "fed654", "pr-8", "success", "inactive")
+	deploy(t, d, "cba321", "pr-9", "in_progress")
+
+	client := &statusCounter{Deployments: d}
+	numbers, err := githubClient.ActivePullRequests(context.Background(), client, "acme", "web")
+	require.NoError(t, err)
+	assert.Equal(t, []int{9, 7}, numbers)
+	assert.Equal(t, 3, client.lookups)
+}
+
+func TestActivePullRequestsAfterTeardown(t *testing.T) {
+	d := fake.NewDeployments()
+	deploy(t, d, "abc123", "pr-7", "success")
+	deploy(t, d, "def456", "pr-7", "failure")
+
+	deployments, err := githubClient.PullRequestDeployments(context.Background(), d, "acme", "web", 7)
+	require.NoError(t, err)
+	require.Len(t, deployments, 2)
+
+	// Only the older deployment was deactivated, the pull request still needs a teardown
+	_, err = githubClient.DeactivateDeployment(context.Background(), d, "acme", "web", deployments[1].GetID(), "closed")
+	require.NoError(t, err)
+	numbers, err := githubClient.ActivePullRequests(context.Background(), d, "acme", "web")
+	require.NoError(t, err)
+	assert.Equal(t, []int{7}, numbers)
+
+	_, err = githubClient.DeactivateDeployment(context.Background(), d, "acme", "web", deployments[0].GetID(), "closed")
+	require.NoError(t, err)
+	numbers, err = githubClient.ActivePullRequests(context.Background(), d, "acme", "web")
+	require.NoError(t, err)
+	assert.Empty(t, numbers)
+}
@@ -0,0 +1,71 @@
+package github
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// newHostTransport builds the base HTTP transport for a host from its network options
+// Both the App transport and the installation transports of the host wrap it
+func newHostTransport(cfg config.TransportConfig, material config.TLSMaterial) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	// Trust the system roots plus any configured CA bundles
+	if len(material.CABundles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		for i, bundle := range material.CABundles {
+			if !pool.AppendCertsFromPEM(bundle) {
+				return nil, fmt.Errorf("CA bundle %d contains no PEM certificates", i+1)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// Present a client certificate for mutual TLS
+	if len(material.ClientCert) > 0 {
+		cert, err := tls.X509KeyPair(material.ClientCert, material.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// An explicit proxy replaces the environment proxy settings
+	if cfg.ProxyURL != "" {
+		if _, err := url.Parse(cfg.ProxyURL); err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  cfg.ProxyURL,
+			HTTPSProxy: cfg.ProxyURL,
+			NoProxy:    cfg.NoProxy,
+		}).ProxyFunc()
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	return transport, nil
+}
@@ -1,6 +1,7 @@
 package logging
 
 import (
+	"io"
 	"os"
 	"time"
 
@@ -10,6 +11,11 @@ import (
 
 // InitLogger initializes zerolog with the specified configuration
 func InitLogger(level string, format string) {
+	InitLoggerTo(os.Stdout, level, format)
+}
+
+// InitLoggerTo initializes zerolog writing to out, e.g. stderr for commands that print results on stdout
+func InitLoggerTo(out io.Writer, level string, format string) {
 	// Set time format
 	zerolog.TimeFieldFormat = time.RFC3339Nano
 	
@@ -23,12 +29,12 @@ func InitLogger(level string, format string) {
 	// Configure output format
 	if format == "console" {
 		log.Logger = log.Output(zerolog.ConsoleWriter{
-			Out:        os.Stdout,
+			Out:        out,
 			TimeFormat: time.RFC3339,
 		})
 	} else {
 		// JSON format (default)
-		log.Logger = zerolog.New(os.Stdout).With().
+		log.Logger = zerolog.New(out).With().
 			Timestamp().
 			Caller().
 			Logger()
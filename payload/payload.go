@@ -0,0 +1,343 @@
+// Package payload defines the versioned JSON payload stored on every GitHub deployment
+//
+// Writers build a Payload, Validate it and send it as the deployment's payload. Readers,
+// including tools outside this repository, call Decode on the raw payload, which also
+// understands the flat key/value payloads written before the schema existed.
+package payload
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version written by this package
+const SchemaVersion = 1
+
+// ErrUnsupportedVersion is returned for payloads written by a newer schema
+var ErrUnsupportedVersion = errors.New("unsupported deployment payload schema version")
+
+// Payload is the deployment payload, schema version 1
+//
+//	{
+//	  "schema_version": 1,
+//	  "artifacts": [{"name": "api", "image": "ghcr.io/acme/api:1.4.2", "digest": "sha256:…"}],
+//	  "services": ["api", "worker"],
+//	  "harness": {"pipeline_id": "deploy-api", "execution_id": "a1b2c3", "stage_id": "prod"},
+//	  "trigger": {"actor": "octocat", "source": "temporal-workflow", "created_at": "2026-10-18T12:00:00Z"},
+//	  "links": {"dashboard": "https://grafana.example.com/d/api"},
+//	  "pull_request": 42,
+//	  "pull_requests": [{"number": 42, "title": "Add search", "head_branch": "search", "author": "octocat", "labels": ["preview"], "state": "open"}],
+//	  "changelog": {"previous_deployment_id": 1234, "base_sha": "9f8e7d…", "total_commits": 3, "pull_requests": [40, 42], "authors": ["hubot", "octocat"]}
+//	}
+type Payload struct {
+	// 0 for legacy payloads written before the schema, SchemaVersion otherwise
+	SchemaVersion int `json:"schema_version"`
+
+	Artifacts []Artifact `json:"artifacts,omitempty"`
+
+	// Services changed by the deployment
+	Services []string `json:"services,omitempty"`
+
+	Harness *Harness `json:"harness,omitempty"`
+	Trigger Trigger  `json:"trigger"`
+
+	// Named links, e.g. dashboard or runbook
+	Links map[string]string `json:"links,omitempty"`
+
+	// Pull request of a transient deployment, torn down when it closes
+	PullRequest int `json:"pull_request,omitempty"`
+
+	// Pull requests containing the commit, open ones first
+	PullRequests []PullRequestContext `json:"pull_requests,omitempty"`
+
+	// What changed since the environment's last successful deployment
+	Changelog *Changelog `json:"changelog,omitempty"`
+
+	// Deployment this one was copied from by a redeploy
+	RedeployOf int64 `json:"redeploy_of,omitempty"`
+
+	// Ref the copied deployment was created from, the redeploy itself targets its commit
+	RedeployRef string `json:"redeploy_ref,omitempty"`
+
+	// Free-form values without a schema field
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Artifact is one deployed container image
+type Artifact struct {
+	Name   string `json:"name,omitempty"`
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"` // sha256:<64 hex>
+}
+
+// PullRequestContext describes a pull request containing the deployed commit
+type PullRequestContext struct {
+	Number     int      `json:"number"`
+	Title      string   `json:"title,omitempty"`
+	HeadBranch string   `json:"head_branch,omitempty"`
+	Author     string   `json:"author,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	State      string   `json:"state,omitempty"` // open, closed or merged
+	URL        string   `json:"url,omitempty"`
+}
+
+// Changelog summarizes the changes since the environment's last successful deployment
+type Changelog struct {
+	PreviousDeploymentID int64    `json:"previous_deployment_id"`
+	BaseSHA              string   `json:"base_sha"`
+	TotalCommits         int      `json:"total_commits"`
+	PullRequests         []int    `json:"pull_requests,omitempty"`
+	Authors              []string `json:"authors,omitempty"`
+	CompareURL           string   `json:"compare_url,omitempty"`
+}
+
+// Harness identifies the pipeline execution that deployed
+type Harness struct {
+	PipelineID  string `json:"pipeline_id,omitempty"`
+	ExecutionID string `json:"execution_id,omitempty"`
+	StageID     string `json:"stage_id,omitempty"`
+}
+
+// Trigger records who or what started the deployment
+type Trigger struct {
+	Actor     string    `json:"actor,omitempty"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+var digestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// Validate checks a payload before it is written
+func (p *Payload) Validate() error {
+	if p.SchemaVersion != SchemaVersion {
+		return fmt.Errorf("payload schema_version must be %d, got %d", SchemaVersion, p.SchemaVersion)
+	}
+	if p.Trigger.Source == "" {
+		return fmt.Errorf("payload trigger.source is required")
+	}
+	for i, artifact := range p.Artifacts {
+		if artifact.Image == "" {
+			return fmt.Errorf("payload artifact %d needs an image", i+1)
+		}
+		if artifact.Digest != "" && !digestPattern.MatchString(artifact.Digest) {
+			return fmt.Errorf("payload artifact %s: digest %q is not sha256:<64 hex>", artifact.Image, artifact.Digest)
+		}
+	}
+	seen := make(map[string]bool)
+	for _, service := range p.Services {
+		if strings.TrimSpace(service) == "" || strings.Contains(service, ",") {
+			return fmt.Errorf("payload service %q is not a valid name", service)
+		}
+		if seen[service] {
+			return fmt.Errorf("payload service %s is listed twice", service)
+		}
+		seen[service] = true
+	}
+	for name, link := range p.Links {
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("payload link %s: %q is not an http(s) URL", name, link)
+		}
+	}
+	for _, pull := range p.PullRequests {
+		if pull.Number <= 0 {
+			return fmt.Errorf("payload pull_requests entry needs a positive number, got %d", pull.Number)
+		}
+	}
+	if p.PullRequest < 0 {
+		return fmt.Errorf("payload pull_request must be positive, got %d", p.PullRequest)
+	}
+	return nil
+}
+
+// Decode reads a deployment payload of any schema version
+// Legacy flat payloads are upgraded field by field and keep SchemaVersion 0
+func Decode(data []byte) (*Payload, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || string(data) == "null" || string(data) == `""` {
+		return &Payload{}, nil
+	}
+
+	// GitHub returns a payload created from a JSON string as that string
+	if data[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(data, &encoded); err != nil {
+			return nil, fmt.Errorf("deployment payload is not a JSON string: %w", err)
+		}
+		data = bytes.TrimSpace([]byte(encoded))
+		if len(data) == 0 || data[0] == '"' {
+			return nil, fmt.Errorf("deployment payload string does not contain a JSON object")
+		}
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("deployment payload is not a JSON object: %w", err)
+	}
+
+	if version, exists := raw["schema_version"]; exists {
+		var v int
+		if err := json.Unmarshal(version, &v); err != nil {
+			return nil, fmt.Errorf("deployment payload schema_version is not a number: %w", err)
+		}
+		if v > SchemaVersion {
+			return nil, fmt.Errorf("%w: %d (newest known is %d)", ErrUnsupportedVersion, v, SchemaVersion)
+		}
+		if v > 0 {
+			var p Payload
+			if err := json.Unmarshal(data, &p); err != nil {
+				return nil, fmt.Errorf("invalid deployment payload: %w", err)
+			}
+			return &p, nil
+		}
+	}
+
+	return decodeLegacy(raw), nil
+}
+
+// New returns a current-version payload started by source at createdAt
+// Flat values, e.g. a Harness step's payload map, are upgraded like a legacy payload
+func New(source string, createdAt time.Time, values map[string]string) *Payload {
+	raw := make(map[string]json.RawMessage, len(values))
+	for key, value := range values {
+		raw[key], _ = json.Marshal(value)
+	}
+	p := decodeLegacy(raw)
+	p.SchemaVersion = SchemaVersion
+	p.Trigger.Source = source
+	p.Trigger.CreatedAt = createdAt.UTC()
+	return p
+}
+
+// decodeLegacy maps the flat keys written before the schema onto its fields
+func decodeLegacy(raw map[string]json.RawMessage) *Payload {
+	p := &Payload{}
+	harness := &Harness{}
+	for key, value := range raw {
+		text := legacyString(value)
+		switch key {
+		case "schema_version":
+		case "harness_execution_id":
+			harness.ExecutionID = text
+		case "harness_pipeline_id":
+			harness.PipelineID = text
+		case "harness_stage_id":
+			harness.StageID = text
+		case "triggered_by":
+			p.Trigger.Source = text
+		case "created_at":
+			p.Trigger.CreatedAt, _ = time.Parse(time.RFC3339, text)
+		case "pull_request":
+			p.PullRequest, _ = strconv.Atoi(text)
+		case "redeploy_of":
+			p.RedeployOf, _ = strconv.ParseInt(text, 10, 64)
+		case "services":
+			var services []string
+			if err := json.Unmarshal(value, &services); err != nil {
+				services = strings.Split(text, ",")
+			}
+			for _, service := range services {
+				if service = strings.TrimSpace(service); service != "" {
+					p.Services = append(p.Services, service)
+				}
+			}
+		default:
+			if p.Extra == nil {
+				p.Extra = make(map[string]string)
+			}
+			p.Extra[key] = text
+		}
+	}
+	if *harness != (Harness{}) {
+		p.Harness = harness
+	}
+	return p
+}
+
+// legacyString renders a flat payload value, numbers without exponent
+func legacyString(value json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		return s
+	}
+	var n json.Number
+	if err := json.Unmarshal(value, &n); err == nil {
+		return n.String()
+	}
+	return string(value)
+}
+
+// Value looks a field up by name for correlation, e.g. harness.execution_id or extra.branch
+// Legacy flat names such as harness_execution_id and bare extra keys are accepted too
+func (p *Payload) Value(key string) (string, bool) {
+	harness := p.Harness
+	if harness == nil {
+		harness = &Harness{}
+	}
+	var value string
+	switch key {
+	case "harness.execution_id", "harness_execution_id":
+		value = harness.ExecutionID
+	case "harness.pipeline_id", "harness_pipeline_id":
+		value = harness.PipelineID
+	case "harness.stage_id", "harness_stage_id":
+		value = harness.StageID
+	case "trigger.actor":
+		value = p.Trigger.Actor
+	case "trigger.source", "triggered_by":
+		value = p.Trigger.Source
+	case "pull_request":
+		if p.PullRequest > 0 {
+			value = strconv.Itoa(p.PullRequest)
+		}
+	case "redeploy_of":
+		if p.RedeployOf > 0 {
+			value = strconv.FormatInt(p.RedeployOf, 10)
+		}
+	case "redeploy_ref":
+		value = p.RedeployRef
+	case "services":
+		value = strings.Join(p.Services, ",")
+	default:
+		if strings.HasPrefix(key, "links.") {
+			value = p.Links[strings.TrimPrefix(key, "links.")]
+		} else {
+			value = p.Extra[strings.TrimPrefix(key, "extra.")]
+		}
+	}
+	return value, value != ""
+}
+
+// ExecutionID returns the Harness execution ID, empty when there is none
+func (p *Payload) ExecutionID() string {
+	if p.Harness == nil {
+		return ""
+	}
+	return p.Harness.ExecutionID
+}
+
+// PipelineID returns the Harness pipeline ID, empty when there is none
+func (p *Payload) PipelineID() string {
+	if p.Harness == nil {
+		return ""
+	}
+	return p.Harness.PipelineID
+}
+
+// LinkNames returns the link names in order
+func (p *Payload) LinkNames() []string {
+	names := make([]string, 0, len(p.Links))
+	for name := range p.Links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
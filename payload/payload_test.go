@@ -0,0 +1,178 @@
+package payload
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	createdAt := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    *Payload
+		wantErr error
+	}{
+		{
+			name: "empty",
+			data: "",
+			want: &Payload{},
+		},
+		{
+			name: "null",
+			data: "null",
+			want: &Payload{},
+		},
+		{
+			name: "legacy flat",
+			data: `{"harness_execution_id": "a1b2c3", "harness_pipeline_id": "deploy-api", "triggered_by": "harness",
+				"created_at": "2026-10-18T12:00:00Z", "pull_request": "42", "services": "api, worker", "branch": "main", "attempt": 3}`,
+			want: &Payload{
+				Harness:     &Harness{PipelineID: "deploy-api", ExecutionID: "a1b2c3"},
+				Trigger:     Trigger{Source: "harness", CreatedAt: createdAt},
+				PullRequest: 42,
+				Services:    []string{"api", "worker"},
+				Extra:       map[string]string{"branch": "main", "attempt": "3"},
+			},
+		},
+		{
+			name: "legacy with services array",
+			data: `{"services": ["api", "worker"], "redeploy_of": 1234}`,
+			want: &Payload{Services: []string{"api", "worker"}, RedeployOf: 1234},
+		},
+		{
+			name: "explicit schema version 0 is legacy",
+			data: `{"schema_version": 0, "triggered_by": "harness"}`,
+			want: &Payload{Trigger: Trigger{Source: "harness"}},
+		},
+		{
+			name: "version 1",
+			data: `{"schema_version": 1, "trigger": {"source": "temporal-workflow", "created_at": "2026-10-18T12:00:00Z"},
+				"harness": {"execution_id": "a1b2c3"}, "links": {"dashboard": "https://grafana.example.com/d/api"}}`,
+			want: &Payload{
+				SchemaVersion: 1,
+				Harness:       &Harness{ExecutionID: "a1b2c3"},
+				Trigger:       Trigger{Source: "temporal-workflow", CreatedAt: createdAt},
+				Links:         map[string]string{"dashboard": "https://grafana.example.com/d/api"},
+			},
+		},
+		{
+			name: "string encoded",
+			data: `"{\"schema_version\": 1, \"trigger\": {\"source\": \"harness\", \"created_at\": \"2026-10-18T12:00:00Z\"}, \"pull_request\": 7}"`,
+			want: &Payload{SchemaVersion: 1, Trigger: Trigger{Source: "harness", CreatedAt: createdAt}, PullRequest: 7},
+		},
+		{
+			name: "string encoded legacy",
+			data: `"{\"harness_execution_id\": \"a1b2c3\"}"`,
+			want: &Payload{Harness: &Harness{ExecutionID: "a1b2c3"}},
+		},
+		{
+			name:    "newer schema version",
+			data:    `{"schema_version": 2, "trigger": {"source": "harness"}}`,
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "string encoded newer schema version",
+			data:    `"{\"schema_version\": 2}"`,
+			wantErr: ErrUnsupportedVersion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode([]byte(tt.data))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDecodeRejectsInvalidPayloads(t *testing.T) {
+	for _, data := range []string{
+		`[1, 2]`,
+		`"not json"`,
+		`"\"nested\""`,
+		`{"schema_version": "one"}`,
+		`{"schema_version": 1, "pull_request": "seven"}`,
+	} {
+		_, err := Decode([]byte(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	p := New("temporal-workflow", time.Date(2026, 10, 18, 14, 0, 0, 0, time.FixedZone("CEST", 2*3600)), map[string]string{
+		"harness_execution_id": "a1b2c3",
+		"services":             "api,worker",
+		"pull_request":         "42",
+		"branch":               "main",
+	})
+	p.Artifacts = []Artifact{{Name: "api", Image: "ghcr.io/acme/api:1.4.2"}}
+	p.Links = map[string]string{"dashboard": "https://grafana.example.com/d/api"}
+	p.Changelog = &Changelog{PreviousDeploymentID: 1234, BaseSHA: "9f8e7d", TotalCommits: 3}
+	p.RedeployOf = 99
+	p.RedeployRef = "main"
+	require.NoError(t, p.Validate())
+	assert.Equal(t, SchemaVersion, p.SchemaVersion)
+	assert.Equal(t, time.UTC, p.Trigger.CreatedAt.Location())
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	decoded, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, p, decoded)
+}
+
+func TestValue(t *testing.T) {
+	p := &Payload{
+		Harness:     &Harness{PipelineID: "deploy-api", ExecutionID: "a1b2c3", StageID: "prod"},
+		Trigger:     Trigger{Actor: "octocat", Source: "harness"},
+		Services:    []string{"api", "worker"},
+		Links:       map[string]string{"dashboard": "https://grafana.example.com/d/api"},
+		PullRequest: 42,
+		RedeployOf:  1234,
+		RedeployRef: "main",
+		Extra:       map[string]string{"branch": "main"},
+	}
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"harness.execution_id", "a1b2c3"},
+		{"harness_execution_id", "a1b2c3"},
+		{"harness.pipeline_id", "deploy-api"},
+		{"harness_stage_id", "prod"},
+		{"trigger.actor", "octocat"},
+		{"triggered_by", "harness"},
+		{"services", "api,worker"},
+		{"links.dashboard", "https://grafana.example.com/d/api"},
+		{"pull_request", "42"},
+		{"redeploy_of", "1234"},
+		{"redeploy_ref", "main"},
+		{"extra.branch", "main"},
+		{"branch", "main"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			value, ok := p.Value(tt.key)
+			assert.True(t, ok)
+			assert.Equal(t, tt.want, value)
+		})
+	}
+
+	// Unset fields are reported missing, also on an empty payload
+	for _, key := range []string{"links.runbook", "extra.missing", "harness.execution_id", "pull_request", "redeploy_of"} {
+		_, ok := (&Payload{}).Value(key)
+		assert.False(t, ok, key)
+	}
+}
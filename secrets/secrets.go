@@ -3,6 +3,8 @@ package secrets
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 )
 
 // LoadFromFile loads a secret from a file path
@@ -30,4 +32,51 @@ func GetSecretPath(envVar, defaultPath string) string {
 		return path
 	}
 	return defaultPath
-}
\ No newline at end of file
+}
+
+// ResolvePaths expands a secret location into file paths
+// The location may be a single file, a directory (all *.pem files inside) or a glob pattern
+func ResolvePaths(location string) ([]string, error) {
+	if location == "" {
+		return nil, fmt.Errorf("secret location is empty")
+	}
+	
+	pattern := location
+	if info, err := os.Stat(location); err == nil {
+		if !info.IsDir() {
+			return []string{location}, nil
+		}
+		pattern = filepath.Join(location, "*.pem")
+	}
+	
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret pattern %s: %w", location, err)
+	}
+	sort.Strings(matches)
+	
+	return matches, nil
+}
+
+// LoadFromGlob loads every secret file matched by a file, directory or glob location
+// The result maps file path to contents and is never empty on success
+func LoadFromGlob(location string) (map[string][]byte, error) {
+	paths, err := ResolvePaths(location)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no secret files match %s", location)
+	}
+	
+	files := make(map[string][]byte, len(paths))
+	for _, path := range paths {
+		data, err := LoadFromFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = data
+	}
+	
+	return files, nil
+}
@@ -55,11 +55,12 @@ func main() {
 		LogURL:         "https://ci.example.com/builds/test-123",
 		EnvironmentURL: "https://pr-test.preview.example.com",
 
+		TriggeredBy: "test-client",
+
 		// Custom payload
 		Payload: map[string]string{
-			"triggered_by": "test-client",
-			"test_mode":    "true",
-			"branch":       "main",
+			"test_mode": "true",
+			"branch":    "main",
 		},
 	}
 
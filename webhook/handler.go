@@ -0,0 +1,209 @@
+// Package webhook receives GitHub App webhooks and starts the matching workflows
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v58/github"
+	"github.com/rs/zerolog"
+	"go.temporal.io/sdk/client"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/workflows"
+)
+
+// WorkflowStarter is the part of the Temporal client the handler uses
+type WorkflowStarter interface {
+	ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error)
+}
+
+// Handler verifies webhook signatures and turns events into workflow executions
+type Handler struct {
+	secret       []byte
+	starter      WorkflowStarter
+	taskQueue    string
+	hosts        []config.GitHubHostConfig
+	environments *config.EnvironmentsSpec
+	teardown     config.TeardownConfig
+	logger       zerolog.Logger
+}
+
+// NewHandler creates a webhook handler
+// environments provides the gate policies, with nil protection rule requests fail instead of being approved
+func NewHandler(cfg *config.Config, environments *config.EnvironmentsSpec, starter WorkflowStarter, logger zerolog.Logger) *Handler {
+	return &Handler{
+		secret:       cfg.Secrets.WebhookSecret,
+		starter:      starter,
+		taskQueue:    cfg.Temporal.TaskQueue,
+		hosts:        cfg.GitHub.Hosts,
+		environments: environments,
+		teardown:     cfg.Teardown,
+		logger:       logger,
+	}
+}
+
+// ServeHTTP handles a single webhook delivery
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	eventType := github.WebHookType(r)
+	deliveryID := github.DeliveryID(r)
+	logger := h.logger.With().
+		Str("event", eventType).
+		Str("delivery_id", deliveryID).
+		Logger()
+
+	payload, err := github.ValidatePayload(r, h.secret)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Rejected webhook with invalid signature")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		logger.Warn().Err(err).Msg("Failed to parse webhook payload")
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	hostName := h.resolveHost(r)
+
+	switch e := event.(type) {
+	case *github.DeploymentProtectionRuleEvent:
+		err = h.handleDeploymentProtectionRule(r.Context(), hostName, deliveryID, e)
+	case *github.PullRequestEvent:
+		err = h.handlePullRequest(r.Context(), hostName, deliveryID, e)
+	default:
+		logger.Debug().Msg("Ignoring webhook event")
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Str("github_host", hostName).Msg("Failed to handle webhook")
+		http.Error(w, "failed to handle webhook", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleDeploymentProtectionRule starts the gate workflow for a deployment waiting on this App
+func (h *Handler) handleDeploymentProtectionRule(ctx context.Context, hostName, deliveryID string, event *github.DeploymentProtectionRuleEvent) error {
+	if event.GetAction() != "requested" {
+		return nil
+	}
+	if h.environments == nil {
+		return fmt.Errorf("no gate policies loaded, refusing to evaluate the protection rule")
+	}
+
+	environment := event.GetEnvironment()
+	input := workflows.DeploymentGateInput{
+		GithubHost:   hostName,
+		GithubOwner:  event.GetRepo().GetOwner().GetLogin(),
+		GithubRepo:   event.GetRepo().GetName(),
+		Environment:  environment,
+		Ref:          event.GetDeployment().GetRef(),
+		CommitSHA:    event.GetDeployment().GetSHA(),
+		DeploymentID: event.GetDeployment().GetID(),
+		Event:        event.GetEvent(),
+		CallbackURL:  event.GetDeploymentCallbackURL(),
+	}
+	if spec := h.environments.Environment(environment); spec != nil {
+		input.Policy = spec.Gate
+	}
+	if input.CallbackURL == "" {
+		return fmt.Errorf("deployment_protection_rule event for %s/%s has no callback URL", input.GithubOwner, input.GithubRepo)
+	}
+
+	// Redeliveries of the same request join the running workflow
+	options := client.StartWorkflowOptions{
+		ID:        fmt.Sprintf("deployment-gate-%s-%s-%d-%s", input.GithubOwner, input.GithubRepo, input.DeploymentID, environment),
+		TaskQueue: h.taskQueue,
+	}
+
+	run, err := h.starter.ExecuteWorkflow(ctx, options, workflows.DeploymentProtectionRuleWorkflow, input)
+	if err != nil {
+		return fmt.Errorf("failed to start deployment gate workflow: %w", err)
+	}
+
+	h.logger.Info().
+		Str("delivery_id", deliveryID).
+		Str("workflow_id", run.GetID()).
+		Str("run_id", run.GetRunID()).
+		Str("github_host", hostName).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Str("environment", environment).
+		Int64("deployment_id", input.DeploymentID).
+		Bool("has_policy", input.Policy != nil).
+		Msg("Started deployment gate workflow")
+
+	return nil
+}
+
+// handlePullRequest starts the teardown of a closed pull request's transient environments
+func (h *Handler) handlePullRequest(ctx context.Context, hostName, deliveryID string, event *github.PullRequestEvent) error {
+	if event.GetAction() != "closed" {
+		return nil
+	}
+
+	input := workflows.PullRequestTeardownInput{
+		GithubHost:        hostName,
+		GithubOwner:       event.GetRepo().GetOwner().GetLogin(),
+		GithubRepo:        event.GetRepo().GetName(),
+		PullRequest:       event.GetNumber(),
+		Merged:            event.GetPullRequest().GetMerged(),
+		DeleteDeployments: h.teardown.DeleteDeployments,
+		DeleteEnvironment: h.teardown.DeleteEnvironments,
+	}
+
+	// The periodic scan uses the same ID, whichever starts first does the teardown
+	options := client.StartWorkflowOptions{
+		ID:        workflows.TeardownWorkflowID(input.GithubOwner, input.GithubRepo, input.PullRequest),
+		TaskQueue: h.taskQueue,
+	}
+
+	run, err := h.starter.ExecuteWorkflow(ctx, options, workflows.PullRequestTeardownWorkflow, input)
+	if err != nil {
+		return fmt.Errorf("failed to start pull request teardown workflow: %w", err)
+	}
+
+	h.logger.Info().
+		Str("delivery_id", deliveryID).
+		Str("workflow_id", run.GetID()).
+		Str("run_id", run.GetRunID()).
+		Str("github_host", hostName).
+		Str("github_owner", input.GithubOwner).
+		Str("github_repo", input.GithubRepo).
+		Int("pull_request", input.PullRequest).
+		Bool("merged", input.Merged).
+		Msg("Started pull request teardown workflow")
+
+	return nil
+}
+
+// resolveHost maps the delivering GitHub instance to a registered host name
+// Enterprise Server sends X-GitHub-Enterprise-Host, GitHub.com sends nothing
+func (h *Handler) resolveHost(r *http.Request) string {
+	enterpriseHost := strings.ToLower(r.Header.Get("X-GitHub-Enterprise-Host"))
+	for _, host := range h.hosts {
+		if enterpriseHost == "" && !host.IsEnterprise() {
+			return host.Name
+		}
+		if enterpriseHost != "" && host.IsEnterprise() {
+			if u, err := url.Parse(host.URL); err == nil && strings.ToLower(u.Hostname()) == enterpriseHost {
+				return host.Name
+			}
+		}
+	}
+	// Fall back to owner routing
+	return ""
+}
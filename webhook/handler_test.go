@@ -0,0 +1,157 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/client"
+
+	"github.com/imranansari/gh-deploy-wf/config"
+	"github.com/imranansari/gh-deploy-wf/workflows"
+)
+
+const testSecret = "webhook-secret"
+
+// startedWorkflow is a workflow execution the handler requested
+type startedWorkflow struct {
+	options client.StartWorkflowOptions
+	input   interface{}
+}
+
+// recordingStarter records executions instead of starting them
+type recordingStarter struct {
+	started []startedWorkflow
+}
+
+func (s *recordingStarter) ExecuteWorkflow(ctx context.Context, options client.StartWorkflowOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	s.started = append(s.started, startedWorkflow{options: options, input: args[0]})
+	return fakeRun{id: options.ID}, nil
+}
+
+type fakeRun struct {
+	client.WorkflowRun
+	id string
+}
+
+func (r fakeRun) GetID() string    { return r.id }
+func (r fakeRun) GetRunID() string { return "run-1" }
+
+func newTestHandler(environments *config.EnvironmentsSpec) (*Handler, *recordingStarter) {
+	starter := &recordingStarter{}
+	cfg := &config.Config{}
+	cfg.Secrets.WebhookSecret = []byte(testSecret)
+	cfg.Temporal.TaskQueue = "deployments"
+	cfg.GitHub.Hosts = []config.GitHubHostConfig{
+		{Name: "ghes-east", URL: "https://github.east.example.com"},
+		{Name: "dotcom"},
+	}
+	return NewHandler(cfg, environments, starter, zerolog.Nop()), starter
+}
+
+func delivery(event, body string, secret string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", "delivery-1")
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	req.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func serve(handler *Handler, req *http.Request) int {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Code
+}
+
+const protectionRuleEvent = `{
+	"action": "requested",
+	"environment": "production",
+	"event": "deployment",
+	"deployment_callback_url": "https://api.github.com/repos/acme/web/actions/runs/1/deployment_protection_rule",
+	"deployment": {"id": 42, "ref": "main", "sha": "abc123"},
+	"repository": {"name": "web", "owner": {"login": "acme"}}
+}`
+
+func TestHandlerRejectsInvalidSignatures(t *testing.T) {
+	handler, starter := newTestHandler(&config.EnvironmentsSpec{})
+
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, delivery("deployment_protection_rule", protectionRuleEvent, "wrong-secret")))
+
+	unsigned := delivery("deployment_protection_rule", protectionRuleEvent, testSecret)
+	unsigned.Header.Del("X-Hub-Signature-256")
+	assert.Equal(t, http.StatusUnauthorized, serve(handler, unsigned))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, serve(handler, httptest.NewRequest(http.MethodGet, "/webhook", nil)))
+	assert.Empty(t, starter.started)
+}
+
+func TestHandlerStartsGateWorkflow(t *testing.T) {
+	gate := &config.GatePolicy{AllowedBranches: []string{"main"}}
+	handler, starter := newTestHandler(&config.EnvironmentsSpec{Environments: []config.EnvironmentSpec{{Name: "production", Gate: gate}}})
+
+	assert.Equal(t, http.StatusAccepted, serve(handler, delivery("deployment_protection_rule", protectionRuleEvent, testSecret)))
+	require.Len(t, starter.started, 1)
+	assert.Equal(t, "deployment-gate-acme-web-42-production", starter.started[0].options.ID)
+	assert.Equal(t, "deployments", starter.started[0].options.TaskQueue)
+
+	input := starter.started[0].input.(workflows.DeploymentGateInput)
+	assert.Equal(t, "dotcom", input.GithubHost)
+	assert.Equal(t, "abc123", input.CommitSHA)
+	assert.Same(t, gate, input.Policy)
+}
+
+func TestHandlerRefusesGateWithoutPolicies(t *testing.T) {
+	handler, starter := newTestHandler(nil)
+
+	assert.Equal(t, http.StatusInternalServerError, serve(handler, delivery("deployment_protection_rule", protectionRuleEvent, testSecret)))
+	assert.Empty(t, starter.started)
+}
+
+func TestHandlerRoutesPullRequestEvents(t *testing.T) {
+	handler, starter := newTestHandler(&config.EnvironmentsSpec{})
+	handler.teardown.DeleteDeployments = true
+
+	opened := `{"action": "opened", "number": 7, "pull_request": {}, "repository": {"name": "web", "owner": {"login": "acme"}}}`
+	assert.Equal(t, http.StatusAccepted, serve(handler, delivery("pull_request", opened, testSecret)))
+	assert.Empty(t, starter.started)
+
+	closed := `{"action": "closed", "number": 7, "pull_request": {"merged": true}, "repository": {"name": "web", "owner": {"login": "acme"}}}`
+	req := delivery("pull_request", closed, testSecret)
+	req.Header.Set("X-GitHub-Enterprise-Host", "GitHub.East.Example.com")
+	assert.Equal(t, http.StatusAccepted, serve(handler, req))
+	require.Len(t, starter.started, 1)
+	assert.Equal(t, workflows.TeardownWorkflowID("acme", "web", 7), starter.started[0].options.ID)
+
+	input := starter.started[0].input.(workflows.PullRequestTeardownInput)
+	assert.Equal(t, "ghes-east", input.GithubHost)
+	assert.True(t, input.Merged)
+	assert.True(t, input.DeleteDeployments)
+
+	assert.Equal(t, http.StatusNoContent, serve(handler, delivery("push", `{"ref": "refs/heads/main"}`, testSecret)))
+	assert.Len(t, starter.started, 1)
+}
+
+func TestResolveHost(t *testing.T) {
+	handler, _ := newTestHandler(&config.EnvironmentsSpec{})
+
+	req := httptest.NewRequest(http.MethodPost, "/webhook", nil)
+	assert.Equal(t, "dotcom", handler.resolveHost(req))
+
+	req.Header.Set("X-GitHub-Enterprise-Host", "github.east.example.com")
+	assert.Equal(t, "ghes-east", handler.resolveHost(req))
+
+	// An unregistered instance falls back to owner routing
+	req.Header.Set("X-GitHub-Enterprise-Host", "github.west.example.com")
+	assert.Equal(t, "", handler.resolveHost(req))
+}
@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"expvar"
+	"flag"
+	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,8 +21,10 @@ import (
 )
 
 func main() {
-	// Load configuration
-	cfg, err := config.Load()
+	// Load configuration, -config and -set take precedence over the environment
+	options := config.BindFlags(flag.CommandLine)
+	flag.Parse()
+	cfg, err := config.LoadWithOptions(*options)
 	if err != nil {
 		panic("Failed to load configuration: " + err.Error())
 	}
@@ -30,10 +37,24 @@ func main() {
 		Str("environment", cfg.App.Environment).
 		Str("temporal_host", cfg.Temporal.HostPort).
 		Str("task_queue", cfg.Temporal.TaskQueue).
-		Str("github_enterprise_url", cfg.GitHub.EnterpriseURL).
-		Bool("using_enterprise", cfg.GitHub.EnterpriseURL != "").
+		Int("github_hosts", len(cfg.GitHub.Hosts)).
 		Msg("Starting GitHub Deployment Tracker Worker")
 	
+	for _, host := range cfg.GitHub.Hosts {
+		logger.Info().
+			Str("github_host", host.Name).
+			Str("github_url", host.URL).
+			Bool("using_enterprise", host.IsEnterprise()).
+			Int64("app_id", host.AppID).
+			Strs("owners", host.Owners).
+			Bool("default", host.Default).
+			Str("proxy_url", host.Transport.ProxyURL).
+			Int("ca_bundles", len(host.Transport.CABundleFiles)).
+			Bool("client_certificate", host.Transport.ClientCertPath != "").
+			Str("signer", host.Signer.Type).
+			Msg("Registered GitHub host")
+	}
+	
 	// Create Temporal client
 	temporalClient, err := createTemporalClient(cfg.Temporal)
 	if err != nil {
@@ -42,7 +63,31 @@ func main() {
 	defer temporalClient.Close()
 	
 	// Create GitHub client factory
-	githubFactory := githubClient.NewClientFactory(cfg.GitHub, cfg.Secrets.GitHubPrivateKey, logger)
+	githubFactory, err := githubClient.NewClientFactory(cfg.GitHub, cfg.Secrets, logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to create GitHub client factory")
+	}
+	
+	// Pick up rotated App private keys without a restart
+	if cfg.GitHub.KeyReloadInterval > 0 {
+		watchCtx, stopWatching := context.WithCancel(context.Background())
+		defer stopWatching()
+		githubFactory.WatchKeys(watchCtx, cfg.GitHub.KeyReloadInterval)
+	}
+	
+	// Expose GitHub cache metrics at /debug/vars
+	if cfg.App.MetricsEnabled {
+		expvar.Publish("github_http_cache", expvar.Func(func() interface{} {
+			return githubFactory.CacheStats()
+		}))
+		go func() {
+			addr := fmt.Sprintf(":%d", cfg.App.MetricsPort)
+			logger.Info().Str("addr", addr).Msg("Serving metrics")
+			if err := http.ListenAndServe(addr, expvar.Handler()); err != nil {
+				logger.Error().Err(err).Msg("Metrics server stopped")
+			}
+		}()
+	}
 	
 	// Test GitHub authentication - we'll test with a known org during first workflow execution
 	logger.Info().Msg("GitHub App authentication configured - installation IDs will be resolved dynamically per organization")
@@ -57,12 +102,63 @@ func main() {
 	// Register workflows
 	w.RegisterWorkflow(workflows.GitHubDeploymentWorkflow)
 	w.RegisterWorkflow(workflows.UpdateDeploymentWorkflow)
+	w.RegisterWorkflow(workflows.ReconcileEnvironmentsWorkflow)
+	w.RegisterWorkflow(workflows.DeploymentProtectionRuleWorkflow)
+	w.RegisterWorkflow(workflows.PullRequestTeardownWorkflow)
+	w.RegisterWorkflow(workflows.TransientEnvironmentScanWorkflow)
+	w.RegisterWorkflow(workflows.RedeployWorkflow)
+	
+	// Release, verification and deployment policies are optional, without environments.yaml
+	// no deployment is released or verified unless the workflow input asks for it
+	var environments *config.EnvironmentsSpec
+	if _, err := os.Stat(cfg.GitHub.EnvironmentsFile); err == nil {
+		environments, err = config.LoadEnvironmentsSpec(cfg.GitHub.EnvironmentsFile)
+		if err != nil {
+			logger.Fatal().Err(err).Msg("Failed to load environments file")
+		}
+	} else {
+		logger.Warn().
+			Str("file", cfg.GitHub.EnvironmentsFile).
+			Msg("Environments file not found, deployments use the default policy and will not be released or verified")
+	}
+	if len(cfg.Overrides) > 0 {
+		environments = environments.WithOverrides(cfg.Overrides)
+		logger.Info().
+			Int("overrides", len(cfg.Overrides)).
+			Msg("Applying configuration file overrides")
+	}
 	
 	// Register activities
-	githubActivities := activities.NewGitHubActivities(githubFactory)
+	githubActivities := activities.NewGitHubActivities(githubFactory, environments)
 	w.RegisterActivity(githubActivities.CreateGitHubDeployment)
 	w.RegisterActivity(githubActivities.UpdateGitHubDeploymentStatus)
 	w.RegisterActivity(githubActivities.FindGitHubDeployment)
+	w.RegisterActivity(githubActivities.ApplyGitHubEnvironment)
+	w.RegisterActivity(githubActivities.DeleteGitHubEnvironment)
+	w.RegisterActivity(githubActivities.UpdatePullRequestDeploymentComment)
+	w.RegisterActivity(githubActivities.ResolveCommitPullRequests)
+	w.RegisterActivity(githubActivities.ComputeDeploymentChangelog)
+	w.RegisterActivity(githubActivities.SyncDeploymentCheckRun)
+	w.RegisterActivity(githubActivities.TeardownPullRequestDeployments)
+	w.RegisterActivity(githubActivities.FindClosedPullRequests)
+	w.RegisterActivity(githubActivities.GetGitHubDeployment)
+	w.RegisterActivity(githubActivities.RedeployGitHubDeployment)
+	w.RegisterActivity(githubActivities.MarkGitHubDeploymentInactive)
+	w.RegisterActivity(githubActivities.GetDeploymentPolicy)
+	w.RegisterActivity(githubActivities.RenderDeploymentURLs)
+	
+	gateActivities := activities.NewGateActivities(githubActivities, cfg.Gate)
+	w.RegisterActivity(gateActivities.EvaluateDeploymentGate)
+	w.RegisterActivity(gateActivities.ReviewDeploymentProtectionRule)
+	
+	releaseActivities := activities.NewReleaseActivities(githubActivities, environments)
+	w.RegisterActivity(releaseActivities.CreateDeploymentRelease)
+	
+	verificationActivities := activities.NewVerificationActivities(environments)
+	w.RegisterActivity(verificationActivities.VerifyDeployment)
+	
+	teardownActivities := activities.NewTeardownActivities(cfg.Teardown, cfg.Overrides, githubActivities)
+	w.RegisterActivity(teardownActivities.EmitTeardownEvent)
 	
 	// Run worker
 	logger.Info().Msg("Starting Temporal worker")
@@ -0,0 +1,46 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+)
+
+// ChangelogQuery returns the deployment workflow's changelog, nil until it is computed
+const ChangelogQuery = "changelog"
+
+// computeChangelog lists what the commit changes since the environment's last successful deployment
+// The changelog is informational, so failures are logged and the deployment goes ahead without it
+func computeChangelog(ctx workflow.Context, input activities.DeploymentChangelogInput) *activities.DeploymentChangelog {
+	if !stepAdded(ctx, changeChangelog) {
+		return nil
+	}
+
+	// Comparing long histories takes longer than a status update
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 3 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        10 * time.Second,
+			MaximumAttempts:        2,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	})
+
+	var changelog *activities.DeploymentChangelog
+	if err := workflow.ExecuteActivity(ctx, "ComputeDeploymentChangelog", input).Get(ctx, &changelog); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to compute deployment changelog",
+			"error", err,
+			"github_owner", input.GithubOwner,
+			"github_repo", input.GithubRepo,
+			"environment", input.Environment,
+			"commit", input.CommitSHA)
+		return nil
+	}
+	return changelog
+}
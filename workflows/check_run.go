@@ -0,0 +1,35 @@
+package workflows
+
+import (
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+)
+
+// syncCheckRun mirrors the deployment's current state as a deploy/<environment> check run
+// Failures are logged only, the deployment status stays the source of truth
+func syncCheckRun(ctx workflow.Context, host, owner, repo, sha string, deploymentID int64, details []githubClient.CheckAnnotation) {
+	if !stepAdded(ctx, changeCheckRun) {
+		return
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, reportingActivityOptions())
+
+	input := activities.SyncCheckRunInput{
+		GithubHost:     host,
+		GithubOwner:    owner,
+		GithubRepo:     repo,
+		CommitSHA:      sha,
+		DeploymentID:   deploymentID,
+		FailureDetails: details,
+	}
+	if err := workflow.ExecuteActivity(ctx, "SyncDeploymentCheckRun", input).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to sync deployment check run",
+			"error", err,
+			"deployment_id", deploymentID,
+			"github_owner", owner,
+			"github_repo", repo,
+			"commit", sha)
+	}
+}
@@ -0,0 +1,56 @@
+package workflows
+
+import (
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// deploymentPolicy reads the environment's deployment policy from environments.yaml and the
+// configuration file's overrides
+// Without one, or when it can't be read, the built-in defaults apply
+func deploymentPolicy(ctx workflow.Context, input activities.GetDeploymentPolicyInput) *config.DeploymentPolicy {
+	if !stepAdded(ctx, changeDeploymentPolicy) {
+		return nil
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, reportingActivityOptions())
+
+	var policy *config.DeploymentPolicy
+	if err := workflow.ExecuteActivity(ctx, "GetDeploymentPolicy", input).Get(ctx, &policy); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to read deployment policy, using defaults",
+			"error", err,
+			"github_owner", input.GithubOwner,
+			"github_repo", input.GithubRepo,
+			"environment", input.Environment)
+		return nil
+	}
+	return policy
+}
+
+// withRetrySettings returns the activity options with the policy's retry settings applied
+func withRetrySettings(options workflow.ActivityOptions, policy *config.DeploymentPolicy) workflow.ActivityOptions {
+	if policy == nil || policy.Retry == nil || options.RetryPolicy == nil {
+		return options
+	}
+
+	retry := *options.RetryPolicy
+	if policy.Retry.MaxAttempts > 0 {
+		retry.MaximumAttempts = policy.Retry.MaxAttempts
+	}
+	if policy.Retry.InitialInterval > 0 {
+		retry.InitialInterval = policy.Retry.InitialInterval
+	}
+	if policy.Retry.MaxInterval > 0 {
+		retry.MaximumInterval = policy.Retry.MaxInterval
+	}
+	if policy.Retry.BackoffCoefficient > 0 {
+		retry.BackoffCoefficient = policy.Retry.BackoffCoefficient
+	}
+	if retry.MaximumInterval < retry.InitialInterval {
+		retry.MaximumInterval = retry.InitialInterval
+	}
+	options.RetryPolicy = &retry
+	return options
+}
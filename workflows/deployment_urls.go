@@ -0,0 +1,30 @@
+package workflows
+
+import (
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+)
+
+// renderDeploymentURLs fills in the log and environment URLs the event omitted from the
+// templates in environments.yaml, keeping the event's URLs when rendering fails
+func renderDeploymentURLs(ctx workflow.Context, input activities.RenderDeploymentURLsInput) (string, string) {
+	if !stepAdded(ctx, changeURLTemplates) {
+		return input.LogURL, input.EnvironmentURL
+	}
+	if input.LogURL != "" && input.EnvironmentURL != "" {
+		return input.LogURL, input.EnvironmentURL
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, reportingActivityOptions())
+
+	var urls activities.DeploymentURLs
+	if err := workflow.ExecuteActivity(ctx, "RenderDeploymentURLs", input).Get(ctx, &urls); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to render deployment URLs, using the event's",
+			"error", err,
+			"deployment_id", input.DeploymentID,
+			"environment", input.Environment)
+		return input.LogURL, input.EnvironmentURL
+	}
+	return urls.LogURL, urls.EnvironmentURL
+}
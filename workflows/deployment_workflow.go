@@ -8,6 +8,9 @@ import (
 	"go.temporal.io/sdk/workflow"
 
 	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+	"github.com/imranansari/gh-deploy-wf/payload"
 )
 
 const (
@@ -17,23 +20,34 @@ const (
 // DeploymentWorkflowInput represents the input for the GitHub deployment workflow
 type DeploymentWorkflowInput struct {
 	// GitHub Repository Information
+	GithubHost  string `json:"github_host,omitempty"` // Registered host name, empty routes by owner
 	GithubOwner string `json:"github_owner"`
 	GithubRepo  string `json:"github_repo"`
 	CommitSHA   string `json:"commit_sha"`
 	
 	// Deployment Configuration
 	Environment   string `json:"environment"`
+	Task          string `json:"task,omitempty"` // deploy (default), deploy:migrations, deploy:config, deploy:rollback or deploy:<name>
 	Description   string `json:"description,omitempty"`
 	IsTransient   bool   `json:"is_transient"`
+	PullRequest   int    `json:"pull_request,omitempty"` // Pull request of a transient deployment, torn down when it closes
 	
 	// External System Integration
 	HarnessPipelineID  string `json:"harness_pipeline_id,omitempty"`
 	HarnessExecutionID string `json:"harness_execution_id,omitempty"`
-	
-	// Deployment Metadata
-	LogURL         string            `json:"log_url,omitempty"`
-	EnvironmentURL string            `json:"environment_url,omitempty"`
-	Payload        map[string]string `json:"payload,omitempty"`
+	HarnessStageID     string `json:"harness_stage_id,omitempty"`
+	TriggeredBy        string `json:"triggered_by,omitempty"` // Actor who started the deployment
+	
+	// Deployment Metadata, stored in the versioned deployment payload
+	LogURL         string             `json:"log_url,omitempty"`
+	EnvironmentURL string             `json:"environment_url,omitempty"`
+	Artifacts      []payload.Artifact `json:"artifacts,omitempty"`
+	Services       []string           `json:"services,omitempty"`
+	Links          map[string]string  `json:"links,omitempty"` // Named links, e.g. dashboard
+	Payload        map[string]string  `json:"payload,omitempty"`
+	
+	// Post-deploy checks run instead of the environment's verification in environments.yaml
+	Verification *config.VerificationPolicy `json:"verification,omitempty"`
 }
 
 // DeploymentWorkflowResult represents the result of the deployment workflow
@@ -45,6 +59,18 @@ type DeploymentWorkflowResult struct {
 	CompletedAt    string `json:"completed_at"`
 	TotalDuration  string `json:"total_duration"`
 	StatusUpdates  int    `json:"status_updates"`
+	
+	// Pull requests containing the commit, open ones first
+	PullRequests []payload.PullRequestContext `json:"pull_requests,omitempty"`
+	
+	// Commits, authors and pull requests since the environment's last successful deployment
+	Changelog *activities.DeploymentChangelog `json:"changelog,omitempty"`
+	
+	// Failed post-deploy verification, nil when it passed or none is configured
+	Verification *activities.VerifyDeploymentResult `json:"verification,omitempty"`
+	
+	// Tag and release created per the repository's release policy
+	Release *activities.CreateReleaseResult `json:"release,omitempty"`
 }
 
 // DeploymentStatusUpdate represents a status update for the deployment
@@ -69,6 +95,13 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 	
 	startTime := workflow.Now(ctx)
 	
+	// Expose the changelog while the deployment is still running
+	if err := workflow.SetQueryHandler(ctx, ChangelogQuery, func() (*activities.DeploymentChangelog, error) {
+		return result.Changelog, nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register %s query handler: %w", ChangelogQuery, err)
+	}
+	
 	// Configure activity options with comprehensive retry logging
 	activityOptions := workflow.ActivityOptions{
 		StartToCloseTimeout: 2 * time.Minute,
@@ -83,6 +116,15 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
 	
+	// The environment's deployment policy may tune retries and status descriptions
+	policy := deploymentPolicy(ctx, activities.GetDeploymentPolicyInput{
+		GithubHost:  input.GithubHost,
+		GithubOwner: input.GithubOwner,
+		GithubRepo:  input.GithubRepo,
+		Environment: input.Environment,
+	})
+	ctx = workflow.WithActivityOptions(ctx, withRetrySettings(activityOptions, policy))
+	
 	// Get workflow info for structured logging
 	workflowInfo := workflow.GetInfo(ctx)
 	
@@ -90,6 +132,7 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 	logger.Info("Starting GitHub deployment workflow",
 		"workflow_id", workflowInfo.WorkflowExecution.ID,
 		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"github_host", input.GithubHost,
 		"github_owner", input.GithubOwner,
 		"github_repo", input.GithubRepo,
 		"commit", input.CommitSHA,
@@ -101,15 +144,47 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 	// 1. Create GitHub deployment
 	logger.Info("Creating GitHub deployment")
 	
+	// Incident responders ask what a deployment changed, so it is recorded with it
+	result.Changelog = computeChangelog(ctx, activities.DeploymentChangelogInput{
+		GithubHost:  input.GithubHost,
+		GithubOwner: input.GithubOwner,
+		GithubRepo:  input.GithubRepo,
+		Environment: input.Environment,
+		CommitSHA:   input.CommitSHA,
+	})
+	
+	// Pull request context makes previews discoverable from the deployment and its PR
+	result.PullRequests = resolvePullRequests(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+	pullRequest, description := input.PullRequest, input.Description
+	if pull := primaryPullRequest(result.PullRequests, input.PullRequest); pull != nil {
+		// A transient deployment of an open PR's commit is torn down with the PR
+		if pullRequest == 0 && input.IsTransient && pull.State == "open" {
+			pullRequest = pull.Number
+		}
+		if description == "" {
+			description = pullRequestDescription(pull, input.IsTransient)
+		}
+	}
+	
 	createInput := activities.CreateDeploymentInput{
+		GithubHost:         input.GithubHost,
 		GithubOwner:        input.GithubOwner,
 		GithubRepo:         input.GithubRepo,
 		CommitSHA:          input.CommitSHA,
 		Environment:        input.Environment,
-		Description:        input.Description,
+		Task:               input.Task,
+		Description:        description,
 		IsTransient:        input.IsTransient,
+		PullRequest:        pullRequest,
+		PullRequests:       result.PullRequests,
+		Changelog:          result.Changelog.Summary(),
 		HarnessExecutionID: input.HarnessExecutionID,
 		HarnessPipelineID:  input.HarnessPipelineID,
+		HarnessStageID:     input.HarnessStageID,
+		TriggeredBy:        input.TriggeredBy,
+		Artifacts:          input.Artifacts,
+		Services:           input.Services,
+		Links:              input.Links,
 		Payload:            input.Payload,
 	}
 	
@@ -137,18 +212,32 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 		"commit", input.CommitSHA,
 		"environment", deploymentResult.Environment)
 	
-	// 2. Update to initial status (queued/in_progress)
+	// Harness events often omit the URLs, they are predictable from the deployment
+	input.LogURL, input.EnvironmentURL = renderDeploymentURLs(ctx, activities.RenderDeploymentURLsInput{
+		GithubHost:     input.GithubHost,
+		GithubOwner:    input.GithubOwner,
+		GithubRepo:     input.GithubRepo,
+		Environment:    input.Environment,
+		DeploymentID:   deploymentResult.DeploymentID,
+		LogURL:         input.LogURL,
+		EnvironmentURL: input.EnvironmentURL,
+	})
+	
+	// 2. Update to initial status (queued/in_progress), a rendered log URL means the run has started
 	initialStatus := "queued"
 	if input.LogURL != "" {
 		initialStatus = "in_progress"
 	}
 	
 	updateInput := activities.UpdateDeploymentStatusInput{
+		GithubHost:     input.GithubHost,
 		GithubOwner:    input.GithubOwner,
 		GithubRepo:     input.GithubRepo,
 		DeploymentID:   deploymentResult.DeploymentID,
+		Environment:    input.Environment,
+		Task:           input.Task,
 		State:          initialStatus,
-		Description:    getInitialStatusDescription(initialStatus, input.Environment),
+		Description:    policy.StatusDescription(initialStatus, getInitialStatusDescription(initialStatus, input.Environment)),
 		LogURL:         input.LogURL,
 		EnvironmentURL: "", // No environment URL yet
 	}
@@ -173,18 +262,39 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 			"github_owner", input.GithubOwner,
 			"github_repo", input.GithubRepo,
 			"workflow_id", workflowInfo.WorkflowExecution.ID)
+		syncPullRequestComment(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+		syncCheckRun(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA, deploymentResult.DeploymentID, nil)
 	}
 	
-	// 3. For MVP, immediately mark as success
+	// 3. For MVP, immediately mark as success once the environment passes verification
 	// In full implementation, this would wait for signals or external updates
+	finalState := "success"
+	finalDescription := policy.StatusDescription(finalState, fmt.Sprintf("Successfully deployed to %s environment", input.Environment))
+	var failureDetails []githubClient.CheckAnnotation
+	
+	result.Verification = verifyDeployment(ctx, activities.VerifyDeploymentInput{
+		GithubOwner:    input.GithubOwner,
+		GithubRepo:     input.GithubRepo,
+		Environment:    input.Environment,
+		EnvironmentURL: input.EnvironmentURL,
+		CommitSHA:      input.CommitSHA,
+		Policy:         input.Verification,
+	})
+	if result.Verification != nil {
+		finalState = "failure"
+		finalDescription, failureDetails = verificationFailure(result.Verification)
+	}
 	
-	// Update to success status
+	// Update to final status
 	finalUpdateInput := activities.UpdateDeploymentStatusInput{
+		GithubHost:     input.GithubHost,
 		GithubOwner:    input.GithubOwner,
 		GithubRepo:     input.GithubRepo,
 		DeploymentID:   deploymentResult.DeploymentID,
-		State:          "success",
-		Description:    fmt.Sprintf("Successfully deployed to %s environment", input.Environment),
+		Environment:    input.Environment,
+		Task:           input.Task,
+		State:          finalState,
+		Description:    finalDescription,
 		LogURL:         input.LogURL,
 		EnvironmentURL: input.EnvironmentURL,
 	}
@@ -195,7 +305,7 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 		logger.Error("Failed to update final deployment status",
 			"error", err,
 			"deployment_id", deploymentResult.DeploymentID,
-			"target_status", "success",
+			"target_status", finalState,
 			"github_owner", input.GithubOwner,
 			"github_repo", input.GithubRepo,
 			"workflow_id", workflowInfo.WorkflowExecution.ID,
@@ -203,14 +313,20 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 		result.FinalStatus = "error"
 	} else {
 		result.StatusUpdates++
-		result.FinalStatus = "success"
+		result.FinalStatus = finalState
 		result.EnvironmentURL = input.EnvironmentURL
-		logger.Info("Updated deployment to success status",
+		logger.Info("Updated deployment to final status",
+			"status", finalState,
 			"deployment_id", deploymentResult.DeploymentID,
 			"environment_url", input.EnvironmentURL,
 			"github_owner", input.GithubOwner,
 			"github_repo", input.GithubRepo,
 			"workflow_id", workflowInfo.WorkflowExecution.ID)
+		syncPullRequestComment(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+		syncCheckRun(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA, deploymentResult.DeploymentID, failureDetails)
+		if finalState == "success" {
+			result.Release = createRelease(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, deploymentResult.DeploymentID, input.LogURL)
+		}
 	}
 	
 	// Calculate final metrics
@@ -253,16 +369,28 @@ func GitHubDeploymentWorkflow(ctx workflow.Context, input DeploymentWorkflowInpu
 // DeploymentUpdateInput represents input for updating an existing deployment's status
 type DeploymentUpdateInput struct {
 	// GitHub Repository Information (to find deployment)
+	GithubHost  string `json:"github_host,omitempty"` // Registered host name, empty routes by owner
 	GithubOwner string `json:"github_owner"`
 	GithubRepo  string `json:"github_repo"`
 	CommitSHA   string `json:"commit_sha"`
 	Environment string `json:"environment"`
+	Task        string `json:"task,omitempty"` // Task the deployment was created with, empty matches the application tasks
+	
+	// Correlation with the deployment that was created, needed when a commit is redeployed
+	HarnessExecutionID string            `json:"harness_execution_id,omitempty"`
+	Correlation        map[string]string `json:"correlation,omitempty"` // Deployment payload values that must match
 	
 	// Status Update Information
 	State          string `json:"state"`
 	Description    string `json:"description"`
 	LogURL         string `json:"log_url,omitempty"`
 	EnvironmentURL string `json:"environment_url,omitempty"`
+	
+	// Failure details shown on the deploy/<environment> check run
+	FailureDetails []githubClient.CheckAnnotation `json:"failure_details,omitempty"`
+	
+	// Post-deploy checks run before success instead of the environment's verification in environments.yaml
+	Verification *config.VerificationPolicy `json:"verification,omitempty"`
 }
 
 // DeploymentUpdateResult represents the result of a deployment status update
@@ -273,6 +401,12 @@ type DeploymentUpdateResult struct {
 	UpdatedAt      string `json:"updated_at"`
 	LogURL         string `json:"log_url,omitempty"`
 	EnvironmentURL string `json:"environment_url,omitempty"`
+	
+	// Failed post-deploy verification that turned a success into a failure
+	Verification *activities.VerifyDeploymentResult `json:"verification,omitempty"`
+	
+	// Tag and release created when the update reported success
+	Release *activities.CreateReleaseResult `json:"release,omitempty"`
 }
 
 // UpdateDeploymentWorkflow updates an existing GitHub deployment status based on cloud events
@@ -294,6 +428,16 @@ func UpdateDeploymentWorkflow(ctx workflow.Context, input DeploymentUpdateInput)
 	}
 	ctx = workflow.WithActivityOptions(ctx, activityOptions)
 	
+	// The environment's deployment policy may tune retries, its status descriptions
+	// apply in the activity when the event carries none
+	policy := deploymentPolicy(ctx, activities.GetDeploymentPolicyInput{
+		GithubHost:  input.GithubHost,
+		GithubOwner: input.GithubOwner,
+		GithubRepo:  input.GithubRepo,
+		Environment: input.Environment,
+	})
+	ctx = workflow.WithActivityOptions(ctx, withRetrySettings(activityOptions, policy))
+	
 	// Get workflow info for structured logging
 	workflowInfo := workflow.GetInfo(ctx)
 	
@@ -301,6 +445,7 @@ func UpdateDeploymentWorkflow(ctx workflow.Context, input DeploymentUpdateInput)
 	logger.Info("Starting deployment status update workflow",
 		"workflow_id", workflowInfo.WorkflowExecution.ID,
 		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"github_host", input.GithubHost,
 		"github_owner", input.GithubOwner,
 		"github_repo", input.GithubRepo,
 		"commit", input.CommitSHA,
@@ -313,10 +458,14 @@ func UpdateDeploymentWorkflow(ctx workflow.Context, input DeploymentUpdateInput)
 	logger.Info("Finding existing GitHub deployment")
 	
 	findInput := activities.FindDeploymentInput{
-		GithubOwner: input.GithubOwner,
-		GithubRepo:  input.GithubRepo,
-		CommitSHA:   input.CommitSHA,
-		Environment: input.Environment,
+		GithubHost:         input.GithubHost,
+		GithubOwner:        input.GithubOwner,
+		GithubRepo:         input.GithubRepo,
+		CommitSHA:          input.CommitSHA,
+		Environment:        input.Environment,
+		Task:               input.Task,
+		HarnessExecutionID: input.HarnessExecutionID,
+		Correlation:        input.Correlation,
 	}
 	
 	var deploymentID int64
@@ -341,13 +490,45 @@ func UpdateDeploymentWorkflow(ctx workflow.Context, input DeploymentUpdateInput)
 		"commit", input.CommitSHA,
 		"environment", input.Environment)
 	
-	// 2. Update deployment status
+	// Harness events often omit the URLs, they are predictable from the deployment
+	input.LogURL, input.EnvironmentURL = renderDeploymentURLs(ctx, activities.RenderDeploymentURLsInput{
+		GithubHost:     input.GithubHost,
+		GithubOwner:    input.GithubOwner,
+		GithubRepo:     input.GithubRepo,
+		Environment:    input.Environment,
+		DeploymentID:   deploymentID,
+		LogURL:         input.LogURL,
+		EnvironmentURL: input.EnvironmentURL,
+	})
+	
+	// 2. Verify the environment before reporting success
+	state, description, failureDetails := input.State, input.Description, input.FailureDetails
+	var verification *activities.VerifyDeploymentResult
+	if input.State == "success" {
+		verification = verifyDeployment(ctx, activities.VerifyDeploymentInput{
+			GithubOwner:    input.GithubOwner,
+			GithubRepo:     input.GithubRepo,
+			Environment:    input.Environment,
+			EnvironmentURL: input.EnvironmentURL,
+			CommitSHA:      input.CommitSHA,
+			Policy:         input.Verification,
+		})
+		if verification != nil {
+			state = "failure"
+			description, failureDetails = verificationFailure(verification)
+		}
+	}
+	
+	// 3. Update deployment status
 	updateInput := activities.UpdateDeploymentStatusInput{
+		GithubHost:     input.GithubHost,
 		GithubOwner:    input.GithubOwner,
 		GithubRepo:     input.GithubRepo,
 		DeploymentID:   deploymentID,
-		State:          input.State,
-		Description:    input.Description,
+		Environment:    input.Environment,
+		Task:           input.Task,
+		State:          state,
+		Description:    description,
 		LogURL:         input.LogURL,
 		EnvironmentURL: input.EnvironmentURL,
 	}
@@ -358,29 +539,39 @@ func UpdateDeploymentWorkflow(ctx workflow.Context, input DeploymentUpdateInput)
 		logger.Error("Failed to update deployment status",
 			"error", err,
 			"deployment_id", deploymentID,
-			"target_state", input.State,
+			"target_state", state,
 			"github_owner", input.GithubOwner,
 			"github_repo", input.GithubRepo,
 			"workflow_id", workflowInfo.WorkflowExecution.ID,
 			"activity_retry_count", workflow.GetInfo(ctx).Attempt)
 		return nil, fmt.Errorf("failed to update deployment %d status to %s for %s/%s: %w", 
-			deploymentID, input.State, input.GithubOwner, input.GithubRepo, err)
+			deploymentID, state, input.GithubOwner, input.GithubRepo, err)
 	}
 	
+	// 4. Refresh the pull request comment and check run
+	syncPullRequestComment(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA)
+	syncCheckRun(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, input.CommitSHA, deploymentID, failureDetails)
+	
 	// Create result object
 	result := &DeploymentUpdateResult{
 		DeploymentID:   deploymentID,
-		UpdatedStatus:  input.State,
+		UpdatedStatus:  state,
 		Environment:    input.Environment,
 		UpdatedAt:      workflow.Now(ctx).Format(time.RFC3339),
 		LogURL:         input.LogURL,
 		EnvironmentURL: input.EnvironmentURL,
+		Verification:   verification,
+	}
+	
+	// 5. Tag and release successful deployments
+	if state == "success" {
+		result.Release = createRelease(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, deploymentID, input.LogURL)
 	}
 	
 	logger.Info("Successfully updated deployment status",
 		"workflow_id", workflowInfo.WorkflowExecution.ID,
 		"deployment_id", deploymentID,
-		"new_state", input.State,
+		"new_state", state,
 		"github_owner", input.GithubOwner,
 		"github_repo", input.GithubRepo,
 		"commit", input.CommitSHA,
@@ -0,0 +1,125 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// ReconcileEnvironmentsInput represents the input for the environment reconcile workflow
+type ReconcileEnvironmentsInput struct {
+	// Parsed environments.yaml (see config.LoadEnvironmentsSpec)
+	Spec config.EnvironmentsSpec `json:"spec"`
+
+	// DryRun reports drift without changing any repository
+	DryRun bool `json:"dry_run"`
+}
+
+// EnvironmentReconcileOutcome is the result for one repository environment
+type EnvironmentReconcileOutcome struct {
+	GithubOwner string                             `json:"github_owner"`
+	GithubRepo  string                             `json:"github_repo"`
+	Environment string                             `json:"environment"`
+	Result      *activities.ApplyEnvironmentResult `json:"result,omitempty"`
+	Error       string                             `json:"error,omitempty"`
+}
+
+// ReconcileEnvironmentsResult represents the result of the environment reconcile workflow
+type ReconcileEnvironmentsResult struct {
+	DryRun    bool                          `json:"dry_run"`
+	Applied   int                           `json:"applied"`
+	Changed   int                           `json:"changed"`
+	Failed    int                           `json:"failed"`
+	Outcomes  []EnvironmentReconcileOutcome `json:"outcomes"`
+	Completed string                        `json:"completed_at"`
+}
+
+// ReconcileEnvironmentsWorkflow applies the declared environment settings to every listed repository
+// A failing repository is recorded and does not stop the others
+func ReconcileEnvironmentsWorkflow(ctx workflow.Context, input ReconcileEnvironmentsInput) (*ReconcileEnvironmentsResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 2 * time.Minute,
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        3,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	workflowInfo := workflow.GetInfo(ctx)
+
+	logger.Info("Starting environment reconcile workflow",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"repository_targets", len(input.Spec.Repositories),
+		"environments", len(input.Spec.Environments),
+		"dry_run", input.DryRun)
+
+	result := &ReconcileEnvironmentsResult{DryRun: input.DryRun}
+
+	for _, target := range input.Spec.Repositories {
+		for _, repo := range target.Repos {
+			for _, environment := range input.Spec.EnvironmentsFor(target) {
+				outcome := EnvironmentReconcileOutcome{
+					GithubOwner: target.Owner,
+					GithubRepo:  repo,
+					Environment: environment.Name,
+				}
+
+				applyInput := activities.ApplyEnvironmentInput{
+					GithubHost:  target.GithubHost,
+					GithubOwner: target.Owner,
+					GithubRepo:  repo,
+					Environment: environment,
+					DryRun:      input.DryRun,
+				}
+
+				var applyResult activities.ApplyEnvironmentResult
+				if err := workflow.ExecuteActivity(ctx, "ApplyGitHubEnvironment", applyInput).Get(ctx, &applyResult); err != nil {
+					logger.Error("Failed to apply GitHub environment",
+						"error", err,
+						"github_owner", target.Owner,
+						"github_repo", repo,
+						"environment", environment.Name,
+						"workflow_id", workflowInfo.WorkflowExecution.ID)
+					outcome.Error = err.Error()
+					result.Failed++
+				} else {
+					outcome.Result = &applyResult
+					result.Applied++
+					if applyResult.Changed {
+						result.Changed++
+					}
+				}
+
+				result.Outcomes = append(result.Outcomes, outcome)
+			}
+		}
+	}
+
+	result.Completed = workflow.Now(ctx).Format(time.RFC3339)
+
+	logger.Info("Environment reconcile workflow completed",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"applied", result.Applied,
+		"changed", result.Changed,
+		"failed", result.Failed,
+		"dry_run", input.DryRun)
+
+	if result.Applied == 0 && result.Failed > 0 {
+		return result, fmt.Errorf("failed to apply any of %d environments", result.Failed)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+)
+
+// syncPullRequestComment refreshes the sticky deployment status comment on the commit's open PRs
+// The comment is informational, so failures are logged and never fail the deployment
+func syncPullRequestComment(ctx workflow.Context, host, owner, repo, sha string) {
+	if !stepAdded(ctx, changePullRequestComment) {
+		return
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, reportingActivityOptions())
+
+	input := activities.UpdatePRCommentInput{
+		GithubHost:  host,
+		GithubOwner: owner,
+		GithubRepo:  repo,
+		CommitSHA:   sha,
+	}
+	if err := workflow.ExecuteActivity(ctx, "UpdatePullRequestDeploymentComment", input).Get(ctx, nil); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to update pull request deployment comment",
+			"error", err,
+			"github_owner", owner,
+			"github_repo", repo,
+			"commit", sha)
+	}
+}
+
+// reportingActivityOptions are used for best-effort mirrors of the deployment (comments, check runs)
+// They retry briefly so a GitHub hiccup never holds up the deployment itself
+func reportingActivityOptions() workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        10 * time.Second,
+			MaximumAttempts:        2,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	}
+}
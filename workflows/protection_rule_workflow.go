@@ -0,0 +1,140 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+)
+
+// DeploymentGateInput represents a deployment_protection_rule webhook waiting for this App
+type DeploymentGateInput struct {
+	GithubHost   string `json:"github_host,omitempty"` // Registered host name, empty routes by owner
+	GithubOwner  string `json:"github_owner"`
+	GithubRepo   string `json:"github_repo"`
+	Environment  string `json:"environment"`
+	Ref          string `json:"ref"`
+	CommitSHA    string `json:"commit_sha"`
+	DeploymentID int64  `json:"deployment_id"`
+	Event        string `json:"event,omitempty"` // What triggered the deployment, e.g. push
+
+	// deployment_callback_url from the webhook
+	CallbackURL string `json:"callback_url"`
+
+	// Gate policy of the environment from environments.yaml, nil approves
+	Policy *config.GatePolicy `json:"policy,omitempty"`
+}
+
+// DeploymentGateResult represents the decision sent back to GitHub
+type DeploymentGateResult struct {
+	DeploymentID int64    `json:"deployment_id"`
+	Environment  string   `json:"environment"`
+	State        string   `json:"state"`
+	Reasons      []string `json:"reasons,omitempty"`
+	ReviewedAt   string   `json:"reviewed_at"`
+}
+
+// DeploymentProtectionRuleWorkflow evaluates the environment's gate policy for a pending
+// deployment and approves or rejects it through the protection rule callback
+func DeploymentProtectionRuleWorkflow(ctx workflow.Context, input DeploymentGateInput) (*DeploymentGateResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 1 * time.Minute,
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        3,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	workflowInfo := workflow.GetInfo(ctx)
+
+	logger.Info("Starting deployment protection rule workflow",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"github_host", input.GithubHost,
+		"github_owner", input.GithubOwner,
+		"github_repo", input.GithubRepo,
+		"environment", input.Environment,
+		"ref", input.Ref,
+		"commit", input.CommitSHA,
+		"deployment_id", input.DeploymentID,
+		"event", input.Event)
+
+	// 1. Evaluate the gate policy
+	evaluateInput := activities.EvaluateGateInput{
+		GithubHost:  input.GithubHost,
+		GithubOwner: input.GithubOwner,
+		GithubRepo:  input.GithubRepo,
+		Environment: input.Environment,
+		Ref:         input.Ref,
+		CommitSHA:   input.CommitSHA,
+		Policy:      input.Policy,
+	}
+
+	var decision activities.GateDecision
+	if err := workflow.ExecuteActivity(ctx, "EvaluateDeploymentGate", evaluateInput).Get(ctx, &decision); err != nil {
+		logger.Error("Failed to evaluate deployment gate",
+			"error", err,
+			"github_owner", input.GithubOwner,
+			"github_repo", input.GithubRepo,
+			"environment", input.Environment,
+			"workflow_id", workflowInfo.WorkflowExecution.ID)
+		// Leave the deployment waiting rather than approving on an evaluation error
+		return nil, fmt.Errorf("failed to evaluate gate for deployment %d to %s: %w", input.DeploymentID, input.Environment, err)
+	}
+
+	state := githubClient.ProtectionRuleRejected
+	if decision.Approved {
+		state = githubClient.ProtectionRuleApproved
+	}
+
+	// 2. Report the decision to GitHub
+	reviewInput := activities.ReviewProtectionRuleInput{
+		GithubHost:  input.GithubHost,
+		GithubOwner: input.GithubOwner,
+		GithubRepo:  input.GithubRepo,
+		Environment: input.Environment,
+		CallbackURL: input.CallbackURL,
+		State:       state,
+		Comment:     decision.Comment,
+	}
+
+	if err := workflow.ExecuteActivity(ctx, "ReviewDeploymentProtectionRule", reviewInput).Get(ctx, nil); err != nil {
+		logger.Error("Failed to review deployment protection rule",
+			"error", err,
+			"deployment_id", input.DeploymentID,
+			"state", state,
+			"github_owner", input.GithubOwner,
+			"github_repo", input.GithubRepo,
+			"workflow_id", workflowInfo.WorkflowExecution.ID)
+		return nil, fmt.Errorf("failed to %s deployment %d to %s: %w", state, input.DeploymentID, input.Environment, err)
+	}
+
+	result := &DeploymentGateResult{
+		DeploymentID: input.DeploymentID,
+		Environment:  input.Environment,
+		State:        state,
+		Reasons:      decision.Reasons,
+		ReviewedAt:   workflow.Now(ctx).Format(time.RFC3339),
+	}
+
+	logger.Info("Deployment protection rule workflow completed",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"deployment_id", input.DeploymentID,
+		"environment", input.Environment,
+		"state", state,
+		"reasons", decision.Reasons)
+
+	return result, nil
+}
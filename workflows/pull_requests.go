@@ -0,0 +1,59 @@
+package workflows
+
+import (
+	"fmt"
+
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/payload"
+)
+
+// resolvePullRequests looks up the pull requests containing the commit, open ones first
+// The context is informational, so failures are logged and the deployment goes ahead without it
+func resolvePullRequests(ctx workflow.Context, host, owner, repo, sha string) []payload.PullRequestContext {
+	if !stepAdded(ctx, changePullRequestContext) {
+		return nil
+	}
+
+	ctx = workflow.WithActivityOptions(ctx, reportingActivityOptions())
+
+	input := activities.ResolvePullRequestsInput{
+		GithubHost:  host,
+		GithubOwner: owner,
+		GithubRepo:  repo,
+		CommitSHA:   sha,
+	}
+	var pulls []payload.PullRequestContext
+	if err := workflow.ExecuteActivity(ctx, "ResolveCommitPullRequests", input).Get(ctx, &pulls); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to resolve pull requests of commit",
+			"error", err,
+			"github_owner", owner,
+			"github_repo", repo,
+			"commit", sha)
+		return nil
+	}
+	return pulls
+}
+
+// primaryPullRequest returns the pull request a deployment is for: the given number when it
+// contains the commit, otherwise the first open one or the newest, nil when there is none
+func primaryPullRequest(pulls []payload.PullRequestContext, number int) *payload.PullRequestContext {
+	for i := range pulls {
+		if number == 0 || pulls[i].Number == number {
+			return &pulls[i]
+		}
+	}
+	return nil
+}
+
+// pullRequestDescription is the default deployment description for a pull request's commit
+func pullRequestDescription(pull *payload.PullRequestContext, transient bool) string {
+	if transient {
+		return fmt.Sprintf("PR #%d preview", pull.Number)
+	}
+	if pull.HeadBranch != "" {
+		return fmt.Sprintf("PR #%d from %s", pull.Number, pull.HeadBranch)
+	}
+	return fmt.Sprintf("PR #%d", pull.Number)
+}
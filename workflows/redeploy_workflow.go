@@ -0,0 +1,226 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+)
+
+// Actions of the redeploy workflow
+const (
+	// RedeployActionRemark re-posts the deployment's last active status, e.g. to make it current again
+	RedeployActionRemark = "remark"
+
+	// RedeployActionRedeploy creates a fresh deployment of the same ref and payload
+	RedeployActionRedeploy = "redeploy"
+
+	// RedeployActionInactive decommissions the deployment
+	RedeployActionInactive = "inactive"
+)
+
+// RedeployWorkflowInput selects an existing deployment and what to do with it
+type RedeployWorkflowInput struct {
+	GithubHost  string `json:"github_host,omitempty"` // Registered host name, empty routes by owner
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+
+	// Deployment to act on, 0 selects the newest deployment to Environment
+	DeploymentID int64  `json:"deployment_id,omitempty"`
+	Environment  string `json:"environment,omitempty"`
+
+	// remark, redeploy or inactive
+	Action string `json:"action"`
+
+	// Status posted on a redeployment, defaults to the source's last active state
+	State string `json:"state,omitempty"`
+
+	// Overrides the re-posted or new status description
+	Description string `json:"description,omitempty"`
+}
+
+// RedeployWorkflowResult represents the result of the redeploy workflow
+type RedeployWorkflowResult struct {
+	Action             string `json:"action"`
+	SourceDeploymentID int64  `json:"source_deployment_id"`
+	DeploymentID       int64  `json:"deployment_id"` // The new deployment for redeploy, the source otherwise
+	Environment        string `json:"environment"`
+	State              string `json:"state"`
+	Changed            bool   `json:"changed"`
+	CompletedAt        string `json:"completed_at"`
+}
+
+// RedeployWorkflow fixes up the GitHub view of an existing deployment after an incident:
+// it re-posts its last status, copies it as a fresh deployment, or marks it inactive
+func RedeployWorkflow(ctx workflow.Context, input RedeployWorkflowInput) (*RedeployWorkflowResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 2 * time.Minute,
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        3,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	workflowInfo := workflow.GetInfo(ctx)
+
+	logger.Info("Starting redeploy workflow",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"github_host", input.GithubHost,
+		"github_owner", input.GithubOwner,
+		"github_repo", input.GithubRepo,
+		"deployment_id", input.DeploymentID,
+		"environment", input.Environment,
+		"action", input.Action)
+
+	switch input.Action {
+	case RedeployActionRemark, RedeployActionRedeploy, RedeployActionInactive:
+	default:
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("unknown action %q (valid: %s, %s, %s)", input.Action, RedeployActionRemark, RedeployActionRedeploy, RedeployActionInactive),
+			"ValidationError", nil)
+	}
+
+	// 1. Resolve the deployment
+	getInput := activities.GetDeploymentInput{
+		GithubHost:   input.GithubHost,
+		GithubOwner:  input.GithubOwner,
+		GithubRepo:   input.GithubRepo,
+		DeploymentID: input.DeploymentID,
+		Environment:  input.Environment,
+	}
+
+	var source activities.DeploymentSnapshot
+	if err := workflow.ExecuteActivity(ctx, "GetGitHubDeployment", getInput).Get(ctx, &source); err != nil {
+		logger.Error("Failed to get GitHub deployment",
+			"error", err,
+			"github_owner", input.GithubOwner,
+			"github_repo", input.GithubRepo,
+			"deployment_id", input.DeploymentID,
+			"environment", input.Environment,
+			"workflow_id", workflowInfo.WorkflowExecution.ID)
+		return nil, fmt.Errorf("failed to get deployment for %s/%s: %w", input.GithubOwner, input.GithubRepo, err)
+	}
+
+	result := &RedeployWorkflowResult{
+		Action:             input.Action,
+		SourceDeploymentID: source.DeploymentID,
+		DeploymentID:       source.DeploymentID,
+		Environment:        source.Environment,
+	}
+
+	// 2. Act on it
+	switch input.Action {
+	case RedeployActionInactive:
+		markInput := activities.MarkInactiveInput{
+			GithubHost:   input.GithubHost,
+			GithubOwner:  input.GithubOwner,
+			GithubRepo:   input.GithubRepo,
+			DeploymentID: source.DeploymentID,
+			Description:  input.Description,
+		}
+		if err := workflow.ExecuteActivity(ctx, "MarkGitHubDeploymentInactive", markInput).Get(ctx, &result.Changed); err != nil {
+			return nil, fmt.Errorf("failed to mark deployment %d inactive: %w", source.DeploymentID, err)
+		}
+		result.State = "inactive"
+
+	case RedeployActionRemark:
+		status := source.LastActiveStatus
+		if status == nil {
+			return nil, temporal.NewNonRetryableApplicationError(
+				fmt.Sprintf("deployment %d has no status to re-post", source.DeploymentID), "ValidationError", nil)
+		}
+		description := status.Description
+		if input.Description != "" {
+			description = input.Description
+		}
+		updateInput := activities.UpdateDeploymentStatusInput{
+			GithubHost:     input.GithubHost,
+			GithubOwner:    input.GithubOwner,
+			GithubRepo:     input.GithubRepo,
+			DeploymentID:   source.DeploymentID,
+			Environment:    source.Environment,
+			Task:           source.Task,
+			State:          status.State,
+			Description:    description,
+			LogURL:         status.LogURL,
+			EnvironmentURL: status.EnvironmentURL,
+		}
+		if err := workflow.ExecuteActivity(ctx, "UpdateGitHubDeploymentStatus", updateInput).Get(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to re-post status %s on deployment %d: %w", status.State, source.DeploymentID, err)
+		}
+		result.State = status.State
+		result.Changed = true
+
+	case RedeployActionRedeploy:
+		redeployInput := activities.RedeployDeploymentInput{
+			GithubHost:   input.GithubHost,
+			GithubOwner:  input.GithubOwner,
+			GithubRepo:   input.GithubRepo,
+			DeploymentID: source.DeploymentID,
+			Description:  input.Description,
+		}
+		var created activities.CreateDeploymentResult
+		if err := workflow.ExecuteActivity(ctx, "RedeployGitHubDeployment", redeployInput).Get(ctx, &created); err != nil {
+			return nil, fmt.Errorf("failed to redeploy deployment %d: %w", source.DeploymentID, err)
+		}
+		result.DeploymentID = created.DeploymentID
+		result.Changed = true
+
+		state, description := "success", fmt.Sprintf("Redeployed from deployment %d", source.DeploymentID)
+		updateInput := activities.UpdateDeploymentStatusInput{
+			GithubHost:   input.GithubHost,
+			GithubOwner:  input.GithubOwner,
+			GithubRepo:   input.GithubRepo,
+			DeploymentID: created.DeploymentID,
+			Environment:  source.Environment,
+			Task:         created.Task,
+		}
+		if status := source.LastActiveStatus; status != nil {
+			state = status.State
+			updateInput.LogURL = status.LogURL
+			updateInput.EnvironmentURL = status.EnvironmentURL
+		}
+		if input.State != "" {
+			state = input.State
+		}
+		if input.Description != "" {
+			description = input.Description
+		}
+		updateInput.State = state
+		updateInput.Description = description
+
+		if err := workflow.ExecuteActivity(ctx, "UpdateGitHubDeploymentStatus", updateInput).Get(ctx, nil); err != nil {
+			return nil, fmt.Errorf("failed to update redeployment %d status to %s: %w", created.DeploymentID, state, err)
+		}
+		result.State = state
+	}
+
+	// 3. Refresh the pull request comment and check run
+	syncPullRequestComment(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, source.SHA)
+	syncCheckRun(ctx, input.GithubHost, input.GithubOwner, input.GithubRepo, source.SHA, result.DeploymentID, nil)
+
+	result.CompletedAt = workflow.Now(ctx).Format(time.RFC3339)
+
+	logger.Info("Redeploy workflow completed",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"action", result.Action,
+		"source_deployment_id", result.SourceDeploymentID,
+		"deployment_id", result.DeploymentID,
+		"state", result.State,
+		"changed", result.Changed,
+		"github_owner", input.GithubOwner,
+		"github_repo", input.GithubRepo)
+
+	return result, nil
+}
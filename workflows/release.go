@@ -0,0 +1,55 @@
+package workflows
+
+import (
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+)
+
+// createRelease tags and releases a successful deployment when the repository has a release policy
+// A failed release is logged and returned as nil, the deployment already succeeded
+func createRelease(ctx workflow.Context, host, owner, repo string, deploymentID int64, logURL string) *activities.CreateReleaseResult {
+	if !stepAdded(ctx, changeRelease) {
+		return nil
+	}
+
+	// Comparing long histories and creating the tag take longer than a status update
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        5 * time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        time.Minute,
+			MaximumAttempts:        5,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	})
+
+	input := activities.CreateReleaseInput{
+		GithubHost:   host,
+		GithubOwner:  owner,
+		GithubRepo:   repo,
+		DeploymentID: deploymentID,
+		LogURL:       logURL,
+	}
+	var result *activities.CreateReleaseResult
+	if err := workflow.ExecuteActivity(ctx, "CreateDeploymentRelease", input).Get(ctx, &result); err != nil {
+		workflow.GetLogger(ctx).Warn("Failed to release deployment",
+			"error", err,
+			"deployment_id", deploymentID,
+			"github_owner", owner,
+			"github_repo", repo)
+		return nil
+	}
+	if result.Skipped == "" {
+		workflow.GetLogger(ctx).Info("Released deployment",
+			"deployment_id", deploymentID,
+			"tag", result.Tag,
+			"release_url", result.ReleaseURL)
+	}
+	return result
+}
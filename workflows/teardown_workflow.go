@@ -0,0 +1,270 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/config"
+)
+
+// PullRequestTeardownInput represents a closed pull request whose transient environments are torn down
+type PullRequestTeardownInput struct {
+	GithubHost  string `json:"github_host,omitempty"` // Registered host name, empty routes by owner
+	GithubOwner string `json:"github_owner"`
+	GithubRepo  string `json:"github_repo"`
+	PullRequest int    `json:"pull_request"`
+	Merged      bool   `json:"merged"`
+
+	// Delete the deployments after marking them inactive
+	DeleteDeployments bool `json:"delete_deployments"`
+
+	// Delete the pr-<n> GitHub environment
+	DeleteEnvironment bool `json:"delete_environment"`
+}
+
+// PullRequestTeardownResult represents the result of the teardown workflow
+type PullRequestTeardownResult struct {
+	PullRequest        int      `json:"pull_request"`
+	Deactivated        []int64  `json:"deactivated"`
+	Deleted            []int64  `json:"deleted,omitempty"`
+	Environments       []string `json:"environments"`
+	EnvironmentDeleted bool     `json:"environment_deleted"`
+	EventEmitted       bool     `json:"event_emitted"`
+	CompletedAt        string   `json:"completed_at"`
+}
+
+// TeardownWorkflowID is shared by the webhook and the periodic scan so a pull request is torn down once
+func TeardownWorkflowID(owner, repo string, pullRequest int) string {
+	return fmt.Sprintf("pr-teardown-%s-%s-%d", owner, repo, pullRequest)
+}
+
+// PullRequestTeardownWorkflow marks a closed pull request's deployments inactive, optionally deletes them
+// and its pr-<n> environment, and emits a teardown event for the infrastructure
+func PullRequestTeardownWorkflow(ctx workflow.Context, input PullRequestTeardownInput) (*PullRequestTeardownResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute, // Repositories can have thousands of deployments
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        3,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	workflowInfo := workflow.GetInfo(ctx)
+
+	logger.Info("Starting pull request teardown workflow",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"github_host", input.GithubHost,
+		"github_owner", input.GithubOwner,
+		"github_repo", input.GithubRepo,
+		"pull_request", input.PullRequest,
+		"merged", input.Merged,
+		"delete_deployments", input.DeleteDeployments,
+		"delete_environment", input.DeleteEnvironment)
+
+	if input.PullRequest <= 0 {
+		return nil, temporal.NewNonRetryableApplicationError(
+			fmt.Sprintf("invalid pull request number %d", input.PullRequest), "ValidationError", nil)
+	}
+
+	result := &PullRequestTeardownResult{PullRequest: input.PullRequest}
+
+	// 1. Deactivate (and delete) the pull request's deployments
+	teardownInput := activities.TeardownDeploymentsInput{
+		GithubHost:        input.GithubHost,
+		GithubOwner:       input.GithubOwner,
+		GithubRepo:        input.GithubRepo,
+		PullRequest:       input.PullRequest,
+		DeleteDeployments: input.DeleteDeployments,
+	}
+
+	var teardown activities.TeardownDeploymentsResult
+	if err := workflow.ExecuteActivity(ctx, "TeardownPullRequestDeployments", teardownInput).Get(ctx, &teardown); err != nil {
+		logger.Error("Failed to tear down pull request deployments",
+			"error", err,
+			"github_owner", input.GithubOwner,
+			"github_repo", input.GithubRepo,
+			"pull_request", input.PullRequest,
+			"workflow_id", workflowInfo.WorkflowExecution.ID)
+		return nil, fmt.Errorf("failed to tear down deployments of %s/%s#%d: %w", input.GithubOwner, input.GithubRepo, input.PullRequest, err)
+	}
+	result.Deactivated = teardown.Deactivated
+	result.Deleted = teardown.Deleted
+	result.Environments = teardown.Environments
+
+	// 2. Delete the pr-<n> environment
+	if input.DeleteEnvironment {
+		deleteInput := activities.DeleteEnvironmentInput{
+			GithubHost:  input.GithubHost,
+			GithubOwner: input.GithubOwner,
+			GithubRepo:  input.GithubRepo,
+			Environment: config.PREnvironment(input.PullRequest),
+		}
+		if err := workflow.ExecuteActivity(ctx, "DeleteGitHubEnvironment", deleteInput).Get(ctx, &result.EnvironmentDeleted); err != nil {
+			// The deployments are already inactive, the scan also picks up closed pull requests' leftover environments
+			logger.Warn("Failed to delete pull request environment",
+				"error", err,
+				"environment", deleteInput.Environment,
+				"github_owner", input.GithubOwner,
+				"github_repo", input.GithubRepo,
+				"workflow_id", workflowInfo.WorkflowExecution.ID)
+		}
+	}
+
+	// 3. Tell the infrastructure, unless there was nothing to tear down
+	if len(result.Deactivated) > 0 || len(result.Deleted) > 0 || result.EnvironmentDeleted {
+		eventInput := activities.EmitTeardownEventInput{
+			EventID: workflowInfo.WorkflowExecution.ID + "/" + workflowInfo.WorkflowExecution.RunID,
+			Event: activities.TeardownEvent{
+				GithubHost:    input.GithubHost,
+				GithubOwner:   input.GithubOwner,
+				GithubRepo:    input.GithubRepo,
+				PullRequest:   input.PullRequest,
+				Merged:        input.Merged,
+				Environments:  result.Environments,
+				DeploymentIDs: result.Deactivated,
+			},
+		}
+		if err := workflow.ExecuteActivity(ctx, "EmitTeardownEvent", eventInput).Get(ctx, nil); err != nil {
+			logger.Error("Failed to emit teardown event",
+				"error", err,
+				"github_owner", input.GithubOwner,
+				"github_repo", input.GithubRepo,
+				"pull_request", input.PullRequest,
+				"workflow_id", workflowInfo.WorkflowExecution.ID)
+			return nil, fmt.Errorf("failed to emit teardown event for %s/%s#%d: %w", input.GithubOwner, input.GithubRepo, input.PullRequest, err)
+		}
+		result.EventEmitted = true
+	}
+
+	result.CompletedAt = workflow.Now(ctx).Format(time.RFC3339)
+
+	logger.Info("Pull request teardown workflow completed",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"pull_request", input.PullRequest,
+		"deactivated", len(result.Deactivated),
+		"deleted", len(result.Deleted),
+		"environments", result.Environments,
+		"environment_deleted", result.EnvironmentDeleted,
+		"event_emitted", result.EventEmitted)
+
+	return result, nil
+}
+
+// TransientEnvironmentScanInput represents the repositories scanned for closed pull requests
+type TransientEnvironmentScanInput struct {
+	Repositories      []config.RepositoryTarget `json:"repositories"`
+	DeleteDeployments bool                      `json:"delete_deployments"`
+	DeleteEnvironment bool                      `json:"delete_environment"`
+}
+
+// TransientEnvironmentScanResult represents the result of one scan
+type TransientEnvironmentScanResult struct {
+	Scanned   int      `json:"scanned"`
+	TornDown  []string `json:"torn_down"`
+	Failed    []string `json:"failed,omitempty"`
+	Completed string   `json:"completed_at"`
+}
+
+// TransientEnvironmentScanWorkflow tears down transient environments of closed pull requests
+// whose webhook was missed. It runs on a cron schedule and starts one teardown per pull request
+func TransientEnvironmentScanWorkflow(ctx workflow.Context, input TransientEnvironmentScanInput) (*TransientEnvironmentScanResult, error) {
+	logger := workflow.GetLogger(ctx)
+
+	activityOptions := workflow.ActivityOptions{
+		StartToCloseTimeout: 5 * time.Minute,
+		HeartbeatTimeout:    30 * time.Second,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        3,
+			NonRetryableErrorTypes: []string{"ValidationError", "AuthenticationError"},
+		},
+	}
+	ctx = workflow.WithActivityOptions(ctx, activityOptions)
+
+	workflowInfo := workflow.GetInfo(ctx)
+
+	logger.Info("Starting transient environment scan workflow",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"run_id", workflowInfo.WorkflowExecution.RunID,
+		"repository_targets", len(input.Repositories))
+
+	result := &TransientEnvironmentScanResult{}
+
+	for _, target := range input.Repositories {
+		for _, repo := range target.Repos {
+			result.Scanned++
+
+			findInput := activities.FindClosedPullRequestsInput{
+				GithubHost:  target.GithubHost,
+				GithubOwner: target.Owner,
+				GithubRepo:  repo,
+
+				IncludeEnvironments: input.DeleteEnvironment,
+			}
+
+			var closed []activities.ClosedPullRequest
+			if err := workflow.ExecuteActivity(ctx, "FindClosedPullRequests", findInput).Get(ctx, &closed); err != nil {
+				logger.Error("Failed to scan repository for closed pull requests",
+					"error", err,
+					"github_owner", target.Owner,
+					"github_repo", repo,
+					"workflow_id", workflowInfo.WorkflowExecution.ID)
+				result.Failed = append(result.Failed, fmt.Sprintf("%s/%s", target.Owner, repo))
+				continue
+			}
+
+			for _, pull := range closed {
+				name := fmt.Sprintf("%s/%s#%d", target.Owner, repo, pull.Number)
+				childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+					WorkflowID:            TeardownWorkflowID(target.Owner, repo, pull.Number),
+					WorkflowIDReusePolicy: enumspb.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE,
+					ParentClosePolicy:     enumspb.PARENT_CLOSE_POLICY_ABANDON,
+				})
+				teardownInput := PullRequestTeardownInput{
+					GithubHost:        target.GithubHost,
+					GithubOwner:       target.Owner,
+					GithubRepo:        repo,
+					PullRequest:       pull.Number,
+					Merged:            pull.Merged,
+					DeleteDeployments: input.DeleteDeployments,
+					DeleteEnvironment: input.DeleteEnvironment,
+				}
+				if err := workflow.ExecuteChildWorkflow(childCtx, PullRequestTeardownWorkflow, teardownInput).Get(ctx, nil); err != nil {
+					// Also reached when the webhook's teardown of the same pull request is still running
+					logger.Warn("Pull request teardown did not complete",
+						"error", err,
+						"pull_request", name,
+						"workflow_id", workflowInfo.WorkflowExecution.ID)
+					result.Failed = append(result.Failed, name)
+					continue
+				}
+				result.TornDown = append(result.TornDown, name)
+			}
+		}
+	}
+
+	result.Completed = workflow.Now(ctx).Format(time.RFC3339)
+
+	logger.Info("Transient environment scan workflow completed",
+		"workflow_id", workflowInfo.WorkflowExecution.ID,
+		"scanned", result.Scanned,
+		"torn_down", len(result.TornDown),
+		"failed", len(result.Failed))
+
+	return result, nil
+}
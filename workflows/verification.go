@@ -0,0 +1,67 @@
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+
+	"github.com/imranansari/gh-deploy-wf/activities"
+	"github.com/imranansari/gh-deploy-wf/config"
+	githubClient "github.com/imranansari/gh-deploy-wf/github"
+)
+
+// verifyDeployment runs the post-deploy HTTP checks before success is posted
+// Returns nil when the deployment may be marked successful, otherwise the failed result
+// A verification that can't run at all counts as failed, success must be earned
+func verifyDeployment(ctx workflow.Context, input activities.VerifyDeploymentInput) *activities.VerifyDeploymentResult {
+	if !stepAdded(ctx, changeVerification) {
+		return nil
+	}
+
+	// The activity polls until its own deadline and heartbeats every check
+	ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: config.MaxVerificationTimeout + time.Minute,
+		HeartbeatTimeout:    time.Minute,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:        time.Second,
+			BackoffCoefficient:     2.0,
+			MaximumInterval:        30 * time.Second,
+			MaximumAttempts:        2,
+			NonRetryableErrorTypes: []string{"ValidationError"},
+		},
+	})
+
+	logger := workflow.GetLogger(ctx)
+
+	var result *activities.VerifyDeploymentResult
+	if err := workflow.ExecuteActivity(ctx, "VerifyDeployment", input).Get(ctx, &result); err != nil {
+		logger.Error("Deployment verification could not run",
+			"error", err,
+			"environment", input.Environment,
+			"environment_url", input.EnvironmentURL)
+		return &activities.VerifyDeploymentResult{Output: fmt.Sprintf("verification could not run: %v", err)}
+	}
+	if result.Skipped != "" || result.Passed {
+		return nil
+	}
+
+	logger.Warn("Deployment verification failed",
+		"environment", input.Environment,
+		"environment_url", input.EnvironmentURL,
+		"rounds", result.Rounds,
+		"output", result.Output)
+	return result
+}
+
+// verificationFailure describes a failed verification as a status description and check run details
+func verificationFailure(result *activities.VerifyDeploymentResult) (string, []githubClient.CheckAnnotation) {
+	description := "Verification failed: " + result.Output
+	details := []githubClient.CheckAnnotation{{
+		Level:   "failure",
+		Title:   "Post-deploy verification failed",
+		Message: result.Output,
+	}}
+	return description, details
+}
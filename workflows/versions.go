@@ -0,0 +1,24 @@
+package workflows
+
+import "go.temporal.io/sdk/workflow"
+
+// Change IDs of the steps added to the deployment workflows after their first release
+// Never rename or reuse one, running workflows have it recorded in their history
+const (
+	changePullRequestComment = "pr-deployment-comment"
+	changeCheckRun           = "deployment-check-run"
+	changeRelease            = "deployment-release"
+	changeVerification       = "deployment-verification"
+	changePullRequestContext = "deployment-pr-context"
+	changeChangelog          = "deployment-changelog"
+	changeDeploymentPolicy   = "deployment-policy"
+	changeURLTemplates       = "deployment-url-templates"
+)
+
+// stepAdded reports whether the workflow runs a step that was added under changeID
+// A workflow started before the step existed has no marker in its history and must replay
+// without the step's activities, otherwise replay fails with a nondeterminism error.
+// Every new execution records the marker and runs the step.
+func stepAdded(ctx workflow.Context, changeID string) bool {
+	return workflow.GetVersion(ctx, changeID, workflow.DefaultVersion, 1) != workflow.DefaultVersion
+}